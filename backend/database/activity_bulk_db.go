@@ -0,0 +1,176 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"backend/models"
+	"backend/utils"
+)
+
+var (
+	// ErrBulkSelectorEmpty 未提供任何筛选条件（避免误操作全部记录）
+	ErrBulkSelectorEmpty = errors.New("bulk selector is empty")
+	// ErrActivityNotOwned 部分记录不存在或不属于当前用户
+	ErrActivityNotOwned = errors.New("activity not found or not owned")
+)
+
+// 预览时最多返回的记录数
+const bulkPreviewLimit = 200
+
+// BulkDeleteActivities 批量删除记录（移入回收站并写入墓碑），preview 时只返回将删除的记录
+func BulkDeleteActivities(userID int, sel models.ActivityBulkSelector, preview bool) (*models.ActivityBulkResult, error) {
+	deletedAt := utils.NowUTC().Format(syncTimeLayout)
+	return runBulkActivities(userID, sel, preview, func(tx *sql.Tx, a *models.HealthActivity) (bool, error) {
+		return true, deleteActivityTx(tx, userID, a.ID, a.UUID, deletedAt, "")
+	})
+}
+
+// BulkRetagActivities 批量修改标签，已是目标标签的记录不计入 affected
+func BulkRetagActivities(userID int, sel models.ActivityBulkSelector, tag string, preview bool) (*models.ActivityBulkResult, error) {
+	return runBulkActivities(userID, sel, preview, func(tx *sql.Tx, a *models.HealthActivity) (bool, error) {
+		if a.Tag == tag {
+			return false, nil
+		}
+		a.Tag = tag
+		return true, updateBulkActivityTx(tx, a)
+	})
+}
+
+// BulkShiftActivities 批量平移记录的日期和时间，并重新计算星期
+func BulkShiftActivities(userID int, sel models.ActivityBulkSelector, shift time.Duration, preview bool) (*models.ActivityBulkResult, error) {
+	return runBulkActivities(userID, sel, preview, func(tx *sql.Tx, a *models.HealthActivity) (bool, error) {
+		layout := "2006-01-02 15:04:05"
+		recordTime := a.RecordTime
+		if len(recordTime) == 5 {
+			layout = "2006-01-02 15:04"
+		}
+		t, err := time.ParseInLocation(layout, a.RecordDate+" "+recordTime, utils.GetShanghaiTZ())
+		if err != nil {
+			// 无法解析的记录跳过，不影响其他记录
+			return false, nil
+		}
+		shifted := t.Add(shift).Format(layout)
+		a.RecordDate, a.RecordTime = shifted[:10], shifted[11:]
+		a.WeekDay = utils.WeekDay(a.RecordDate)
+		return true, updateBulkActivityTx(tx, a)
+	})
+}
+
+// runBulkActivities 在一个事务中选出记录并逐条执行 apply；预览时回滚事务，因此预览结果与实际执行完全一致
+func runBulkActivities(userID int, sel models.ActivityBulkSelector, preview bool, apply func(tx *sql.Tx, a *models.HealthActivity) (bool, error)) (*models.ActivityBulkResult, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	activities, err := selectBulkActivities(tx, userID, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ActivityBulkResult{Preview: preview, Matched: len(activities)}
+	for i := range activities {
+		changed, err := apply(tx, &activities[i])
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			continue
+		}
+		result.Affected++
+		if preview && len(result.Activities) < bulkPreviewLimit {
+			result.Activities = append(result.Activities, activities[i])
+		}
+	}
+
+	if preview {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// selectBulkActivities 按筛选条件查询用户的记录；按ID选择时任一ID不属于该用户则整体失败
+func selectBulkActivities(tx *sql.Tx, userID int, sel models.ActivityBulkSelector) ([]models.HealthActivity, error) {
+	where := []string{"user_id = ?"}
+	args := []interface{}{userID}
+
+	if len(sel.IDs) > 0 {
+		unique := make(map[int]bool)
+		placeholders := make([]string, 0, len(sel.IDs))
+		idArgs := make([]interface{}, 0, len(sel.IDs))
+		for _, id := range sel.IDs {
+			if unique[id] {
+				continue
+			}
+			unique[id] = true
+			placeholders = append(placeholders, "?")
+			idArgs = append(idArgs, id)
+		}
+		inClause := "id IN (" + strings.Join(placeholders, ", ") + ")"
+
+		var owned int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM health_activities WHERE user_id = ? AND "+inClause, append([]interface{}{userID}, idArgs...)...).Scan(&owned); err != nil {
+			return nil, err
+		}
+		if owned != len(unique) {
+			return nil, ErrActivityNotOwned
+		}
+		where = append(where, inClause)
+		args = append(args, idArgs...)
+	}
+	if sel.From != "" {
+		where = append(where, "record_date >= ?")
+		args = append(args, sel.From)
+	}
+	if sel.To != "" {
+		where = append(where, "record_date <= ?")
+		args = append(args, sel.To)
+	}
+	if sel.Tag != "" {
+		where = append(where, "COALESCE(tag, 'manual') = ?")
+		args = append(args, sel.Tag)
+	}
+	if len(where) == 1 {
+		return nil, ErrBulkSelectorEmpty
+	}
+
+	rows, err := tx.Query(
+		"SELECT "+activityColumns+" FROM health_activities WHERE "+strings.Join(where, " AND ")+" ORDER BY record_date ASC, record_time ASC",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []models.HealthActivity
+	for rows.Next() {
+		a, err := scanActivity(rows)
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, *a)
+	}
+	return activities, rows.Err()
+}
+
+// updateBulkActivityTx 保存批量修改后的记录，并更新同步序号供离线客户端拉取
+func updateBulkActivityTx(tx *sql.Tx, a *models.HealthActivity) error {
+	seq, err := nextActivitySeq(tx)
+	if err != nil {
+		return err
+	}
+	a.UpdatedAt = utils.NowUTC().Format(syncTimeLayout)
+	_, err = tx.Exec(
+		"UPDATE health_activities SET record_date = ?, record_time = ?, week_day = ?, tag = ?, updated_at = ?, sync_seq = ?, sync_key = '' WHERE id = ? AND user_id = ?",
+		a.RecordDate, a.RecordTime, a.WeekDay, a.Tag, a.UpdatedAt, seq, a.ID, a.UserID,
+	)
+	return err
+}
@@ -0,0 +1,578 @@
+package database
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// syncTimeLayout 同步时间戳格式（UTC、定长，可直接按字符串比较先后）
+const syncTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// activityColumns 查询健康活动记录时使用的列（与 scanActivity 对应）
+const activityColumns = "id, COALESCE(uuid, ''), user_id, record_date, record_time, week_day, duration, COALESCE(remark, ''), COALESCE(tag, 'manual'), created_at, COALESCE(updated_at, '')"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// InitActivitySyncTable 为健康活动表添加同步字段，并创建墓碑表和幂等记录表
+func InitActivitySyncTable() error {
+	// 迁移：添加同步相关列（旧库兼容，忽略 "duplicate column" 错误）
+	_, _ = DB.Exec("ALTER TABLE health_activities ADD COLUMN uuid TEXT")
+	_, _ = DB.Exec("ALTER TABLE health_activities ADD COLUMN updated_at TEXT")
+	_, _ = DB.Exec("ALTER TABLE health_activities ADD COLUMN sync_seq INTEGER DEFAULT 0")
+	_, _ = DB.Exec("ALTER TABLE health_activities ADD COLUMN sync_key TEXT DEFAULT ''")
+
+	statements := []string{
+		// 全局递增的变更序号
+		`CREATE TABLE IF NOT EXISTS sync_counters (
+			name TEXT PRIMARY KEY,
+			value INTEGER NOT NULL
+		);`,
+		`INSERT OR IGNORE INTO sync_counters (name, value) VALUES ('activities', 0);`,
+		// 删除墓碑（客户端据此删除本地记录）
+		`CREATE TABLE IF NOT EXISTS activity_tombstones (
+			user_id INTEGER NOT NULL,
+			uuid TEXT NOT NULL,
+			deleted_at TEXT NOT NULL,
+			sync_key TEXT DEFAULT '',
+			sync_seq INTEGER NOT NULL,
+			PRIMARY KEY (user_id, uuid)
+		);`,
+		// 已处理的修改（按幂等键去重）
+		`CREATE TABLE IF NOT EXISTS activity_sync_mutations (
+			user_id INTEGER NOT NULL,
+			idempotency_key TEXT NOT NULL,
+			uuid TEXT,
+			status TEXT NOT NULL,
+			message TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, idempotency_key)
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_activity_user_uuid ON health_activities(user_id, uuid);`,
+		`CREATE INDEX IF NOT EXISTS idx_activity_user_seq ON health_activities(user_id, sync_seq);`,
+		`CREATE INDEX IF NOT EXISTS idx_tombstone_user_seq ON activity_tombstones(user_id, sync_seq);`,
+	}
+	for _, stmt := range statements {
+		if _, err := DB.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	return backfillActivitySyncFields()
+}
+
+// backfillActivitySyncFields 为旧数据补齐 uuid 和同步序号
+func backfillActivitySyncFields() error {
+	rows, err := DB.Query("SELECT id FROM health_activities WHERE uuid IS NULL OR uuid = '' OR COALESCE(sync_seq, 0) = 0")
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		seq, err := nextActivitySeq(tx)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"UPDATE health_activities SET uuid = COALESCE(NULLIF(uuid, ''), ?), sync_seq = ? WHERE id = ?",
+			utils.NewUUID(), seq, id,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// nextActivitySeq 在事务中获取下一个变更序号
+func nextActivitySeq(tx *sql.Tx) (int64, error) {
+	var seq int64
+	err := tx.QueryRow("UPDATE sync_counters SET value = value + 1 WHERE name = 'activities' RETURNING value").Scan(&seq)
+	return seq, err
+}
+
+// scanActivity 扫描一条健康活动记录，created_at 转换为东八区显示
+func scanActivity(s rowScanner) (*models.HealthActivity, error) {
+	var a models.HealthActivity
+	var createdAt string
+	err := s.Scan(&a.ID, &a.UUID, &a.UserID, &a.RecordDate, &a.RecordTime, &a.WeekDay, &a.Duration, &a.Remark, &a.Tag, &createdAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	a.CreatedAt = utils.UTCToShanghai(createdAt)
+	return &a, nil
+}
+
+// GetUserActivityPoints 获取用户全部活动时间点，按记录时间升序
+func GetUserActivityPoints(userID int) ([]models.ActivityPoint, error) {
+	return queryActivityPoints(
+		"SELECT record_date, record_time, duration, COALESCE(tag, 'manual') FROM health_activities WHERE user_id = ? ORDER BY record_date ASC, record_time ASC",
+		userID,
+	)
+}
+
+// GetUserActivityPointsBetween 获取用户在日期范围内（含首尾，YYYY-MM-DD）的活动时间点，按记录时间升序
+func GetUserActivityPointsBetween(userID int, from, to string) ([]models.ActivityPoint, error) {
+	return queryActivityPoints(
+		"SELECT record_date, record_time, duration, COALESCE(tag, 'manual') FROM health_activities WHERE user_id = ? AND record_date >= ? AND record_date <= ? ORDER BY record_date ASC, record_time ASC",
+		userID, from, to,
+	)
+}
+
+func queryActivityPoints(query string, args ...interface{}) ([]models.ActivityPoint, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []models.ActivityPoint
+	for rows.Next() {
+		var p models.ActivityPoint
+		if err := rows.Scan(&p.RecordDate, &p.RecordTime, &p.Duration, &p.Tag); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// GetUserActivities 获取用户最近的健康活动记录
+func GetUserActivities(userID, limit int) ([]models.HealthActivity, error) {
+	rows, err := DB.Query(
+		"SELECT "+activityColumns+" FROM health_activities WHERE user_id = ? ORDER BY record_date DESC, record_time DESC LIMIT ?",
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []models.HealthActivity
+	for rows.Next() {
+		activity, err := scanActivity(rows)
+		if err != nil {
+			continue
+		}
+		activities = append(activities, *activity)
+	}
+	return activities, nil
+}
+
+// GetUserActivitiesBetween 获取用户在日期范围内（含首尾，YYYY-MM-DD）的健康活动记录，按记录时间升序
+func GetUserActivitiesBetween(userID int, from, to string) ([]models.HealthActivity, error) {
+	rows, err := DB.Query(
+		"SELECT "+activityColumns+" FROM health_activities WHERE user_id = ? AND record_date >= ? AND record_date <= ? ORDER BY record_date ASC, record_time ASC",
+		userID, from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activities := []models.HealthActivity{}
+	for rows.Next() {
+		activity, err := scanActivity(rows)
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, *activity)
+	}
+	return activities, rows.Err()
+}
+
+// GetActivityOwner 获取记录所属用户ID
+func GetActivityOwner(id int) (int, error) {
+	var ownerID int
+	err := DB.QueryRow("SELECT user_id FROM health_activities WHERE id = ?", id).Scan(&ownerID)
+	return ownerID, err
+}
+
+// CreateActivity 创建健康活动记录（UUID 为空时由服务端生成），并记录变更序号
+func CreateActivity(a *models.HealthActivity) error {
+	if a.UUID == "" {
+		a.UUID = utils.NewUUID()
+	}
+	createdAt := utils.NowUTCString()
+	updatedAt := utils.NowUTC().Format(syncTimeLayout)
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	seq, err := nextActivitySeq(tx)
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec(
+		"INSERT INTO health_activities (uuid, user_id, record_date, record_time, week_day, duration, remark, tag, created_at, updated_at, sync_seq, sync_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, '')",
+		a.UUID, a.UserID, a.RecordDate, a.RecordTime, a.WeekDay, a.Duration, a.Remark, a.Tag, createdAt, updatedAt, seq,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM activity_tombstones WHERE user_id = ? AND uuid = ?", a.UserID, a.UUID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	id, _ := result.LastInsertId()
+	a.ID = int(id)
+	a.CreatedAt = utils.UTCToShanghai(createdAt)
+	a.UpdatedAt = updatedAt
+	return nil
+}
+
+// DeleteActivity 删除健康活动记录（移入回收站），并留下墓碑供离线客户端同步
+func DeleteActivity(id, userID int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var uuid string
+	err = tx.QueryRow("SELECT COALESCE(uuid, '') FROM health_activities WHERE id = ? AND user_id = ?", id, userID).Scan(&uuid)
+	if err != nil {
+		return err
+	}
+	if err := deleteActivityTx(tx, userID, id, uuid, utils.NowUTC().Format(syncTimeLayout), ""); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteActivityTx 在事务中把记录移入回收站并写入墓碑
+func deleteActivityTx(tx *sql.Tx, userID, id int, uuid, deletedAt, syncKey string) error {
+	if err := moveToTrashTx(tx, userID, models.TrashTypeActivity, id, nil); err != nil {
+		return err
+	}
+	if uuid == "" {
+		return nil
+	}
+	seq, err := nextActivitySeq(tx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT OR REPLACE INTO activity_tombstones (user_id, uuid, deleted_at, sync_key, sync_seq) VALUES (?, ?, ?, ?, ?)",
+		userID, uuid, deletedAt, syncKey, seq,
+	)
+	return err
+}
+
+// ApplyActivitySync 在一个事务中依次应用客户端修改，然后返回游标之后的变更
+func ApplyActivitySync(userID int, req *models.ActivitySyncRequest) (*models.ActivitySyncData, bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	results := make([]models.ActivitySyncResult, 0, len(req.Mutations))
+	changed := false
+	for _, m := range req.Mutations {
+		result, err := applyActivityMutation(tx, userID, m)
+		if err != nil {
+			return nil, false, err
+		}
+		if result.Status == "applied" && !result.Replayed {
+			changed = true
+		}
+		results = append(results, *result)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	changes, hasMore, err := GetActivityChanges(userID, req.Cursor, req.Limit)
+	if err != nil {
+		return nil, false, err
+	}
+	cursor := req.Cursor
+	if len(changes) > 0 {
+		cursor = changes[len(changes)-1].Seq
+	}
+	return &models.ActivitySyncData{
+		Cursor:  cursor,
+		HasMore: hasMore,
+		Results: results,
+		Changes: changes,
+	}, changed, nil
+}
+
+// syncVersion 参与冲突判定的版本：先比较修改时间，时间相同时删除优先，再按幂等键排序
+type syncVersion struct {
+	at     string
+	delete bool
+	key    string
+}
+
+func (v syncVersion) newerThan(o syncVersion) bool {
+	if v.at != o.at {
+		return v.at > o.at
+	}
+	if v.delete != o.delete {
+		return v.delete
+	}
+	return v.key > o.key
+}
+
+// applyActivityMutation 应用单个修改；重复的幂等键直接返回首次处理结果
+func applyActivityMutation(tx *sql.Tx, userID int, m models.ActivitySyncMutation) (*models.ActivitySyncResult, error) {
+	key := strings.TrimSpace(m.IdempotencyKey)
+	if key == "" {
+		return &models.ActivitySyncResult{UUID: m.UUID, Status: "rejected", Message: "缺少幂等键"}, nil
+	}
+
+	var prev models.ActivitySyncResult
+	var prevMessage sql.NullString
+	err := tx.QueryRow(
+		"SELECT COALESCE(uuid, ''), status, message FROM activity_sync_mutations WHERE user_id = ? AND idempotency_key = ?",
+		userID, key,
+	).Scan(&prev.UUID, &prev.Status, &prevMessage)
+	if err == nil {
+		prev.IdempotencyKey = key
+		prev.Message = prevMessage.String
+		prev.Replayed = true
+		return &prev, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	result, err := evaluateActivityMutation(tx, userID, key, m)
+	if err != nil {
+		return nil, err
+	}
+	result.IdempotencyKey = key
+
+	_, err = tx.Exec(
+		"INSERT INTO activity_sync_mutations (user_id, idempotency_key, uuid, status, message, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, key, result.UUID, result.Status, result.Message, utils.NowUTCString(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func evaluateActivityMutation(tx *sql.Tx, userID int, key string, m models.ActivitySyncMutation) (*models.ActivitySyncResult, error) {
+	uuid := utils.NormalizeUUID(m.UUID)
+	if uuid == "" {
+		return &models.ActivitySyncResult{UUID: m.UUID, Status: "rejected", Message: "无效的 uuid"}, nil
+	}
+	rejected := func(msg string) (*models.ActivitySyncResult, error) {
+		return &models.ActivitySyncResult{UUID: uuid, Status: "rejected", Message: msg}, nil
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, m.UpdatedAt)
+	if err != nil {
+		return rejected("updated_at 格式错误，应为 RFC3339")
+	}
+	// 客户端时钟超前时按服务端当前时间处理，避免未来时间的修改永远胜出
+	now := utils.NowUTC()
+	if at.After(now) {
+		at = now
+	}
+	incoming := syncVersion{at: at.UTC().Format(syncTimeLayout), delete: m.Op == "delete", key: key}
+
+	// 当前记录
+	current, err := scanActivity(tx.QueryRow(
+		"SELECT "+activityColumns+" FROM health_activities WHERE user_id = ? AND uuid = ?",
+		userID, uuid,
+	))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	var currentVersion syncVersion
+	if current != nil {
+		var currentKey string
+		if err := tx.QueryRow("SELECT COALESCE(sync_key, '') FROM health_activities WHERE id = ?", current.ID).Scan(&currentKey); err != nil {
+			return nil, err
+		}
+		currentVersion = syncVersion{at: current.UpdatedAt, key: currentKey}
+	}
+
+	// 墓碑
+	var tomb *syncVersion
+	var tombAt, tombKey string
+	err = tx.QueryRow(
+		"SELECT deleted_at, COALESCE(sync_key, '') FROM activity_tombstones WHERE user_id = ? AND uuid = ?",
+		userID, uuid,
+	).Scan(&tombAt, &tombKey)
+	if err == nil {
+		tomb = &syncVersion{at: tombAt, delete: true, key: tombKey}
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	conflict := func(msg string) (*models.ActivitySyncResult, error) {
+		return &models.ActivitySyncResult{UUID: uuid, Status: "conflict", Message: msg, Current: current}, nil
+	}
+	applied := &models.ActivitySyncResult{UUID: uuid, Status: "applied"}
+
+	switch m.Op {
+	case "upsert":
+		d := m.Data
+		if d == nil || d.RecordDate == "" || d.RecordTime == "" {
+			return rejected("记录日期和时间不能为空")
+		}
+		if d.Duration <= 0 {
+			return rejected("持续时间必须大于0")
+		}
+		weekDay := utils.WeekDay(d.RecordDate)
+		if weekDay == "" {
+			return rejected("日期格式错误，应为 YYYY-MM-DD")
+		}
+		tag := d.Tag
+		if tag != "auto" && tag != "manual" {
+			tag = "manual"
+		}
+
+		if current != nil {
+			if !incoming.newerThan(currentVersion) {
+				return conflict("服务端已有更新的修改")
+			}
+			seq, err := nextActivitySeq(tx)
+			if err != nil {
+				return nil, err
+			}
+			_, err = tx.Exec(
+				"UPDATE health_activities SET record_date = ?, record_time = ?, week_day = ?, duration = ?, remark = ?, tag = ?, updated_at = ?, sync_key = ?, sync_seq = ? WHERE id = ?",
+				d.RecordDate, d.RecordTime, weekDay, d.Duration, d.Remark, tag, incoming.at, key, seq, current.ID,
+			)
+			return applied, err
+		}
+		if tomb != nil {
+			if !incoming.newerThan(*tomb) {
+				return conflict("记录已被删除")
+			}
+			if _, err := tx.Exec("DELETE FROM activity_tombstones WHERE user_id = ? AND uuid = ?", userID, uuid); err != nil {
+				return nil, err
+			}
+		}
+		seq, err := nextActivitySeq(tx)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO health_activities (uuid, user_id, record_date, record_time, week_day, duration, remark, tag, created_at, updated_at, sync_seq, sync_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			uuid, userID, d.RecordDate, d.RecordTime, weekDay, d.Duration, d.Remark, tag, utils.NowUTCString(), incoming.at, seq, key,
+		)
+		return applied, err
+
+	case "delete":
+		if current != nil {
+			if !incoming.newerThan(currentVersion) {
+				return conflict("服务端已有更新的修改")
+			}
+			return applied, deleteActivityTx(tx, userID, current.ID, uuid, incoming.at, key)
+		}
+		if tomb != nil {
+			if incoming.newerThan(*tomb) {
+				// 墓碑版本变化同样需要新序号，否则增量拉取的客户端收不到新的删除时间
+				seq, err := nextActivitySeq(tx)
+				if err != nil {
+					return nil, err
+				}
+				_, err = tx.Exec(
+					"UPDATE activity_tombstones SET deleted_at = ?, sync_key = ?, sync_seq = ? WHERE user_id = ? AND uuid = ?",
+					incoming.at, key, seq, userID, uuid,
+				)
+				return applied, err
+			}
+			return applied, nil
+		}
+		// 服务端从未见过该记录：同样留下墓碑，使更早的 upsert 之后到达时不会复活它
+		seq, err := nextActivitySeq(tx)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO activity_tombstones (user_id, uuid, deleted_at, sync_key, sync_seq) VALUES (?, ?, ?, ?, ?)",
+			userID, uuid, incoming.at, key, seq,
+		)
+		return applied, err
+	}
+
+	return rejected("不支持的操作: " + m.Op)
+}
+
+// GetActivityChanges 获取游标之后的变更（包括删除墓碑），按序号升序，最多 limit 条
+func GetActivityChanges(userID int, cursor int64, limit int) ([]models.ActivityChange, bool, error) {
+	rows, err := DB.Query(
+		"SELECT "+activityColumns+", sync_seq FROM health_activities WHERE user_id = ? AND sync_seq > ? ORDER BY sync_seq ASC LIMIT ?",
+		userID, cursor, limit+1,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	var changes []models.ActivityChange
+	for rows.Next() {
+		var a models.HealthActivity
+		var createdAt string
+		var seq int64
+		if err := rows.Scan(&a.ID, &a.UUID, &a.UserID, &a.RecordDate, &a.RecordTime, &a.WeekDay, &a.Duration, &a.Remark, &a.Tag, &createdAt, &a.UpdatedAt, &seq); err != nil {
+			rows.Close()
+			return nil, false, err
+		}
+		a.CreatedAt = utils.UTCToShanghai(createdAt)
+		changes = append(changes, models.ActivityChange{Seq: seq, UUID: a.UUID, Activity: &a})
+	}
+	rows.Close()
+
+	rows, err = DB.Query(
+		"SELECT uuid, deleted_at, sync_seq FROM activity_tombstones WHERE user_id = ? AND sync_seq > ? ORDER BY sync_seq ASC LIMIT ?",
+		userID, cursor, limit+1,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c := models.ActivityChange{Deleted: true}
+		if err := rows.Scan(&c.UUID, &c.DeletedAt, &c.Seq); err != nil {
+			return nil, false, err
+		}
+		changes = append(changes, c)
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Seq < changes[j].Seq })
+	hasMore := len(changes) > limit
+	if hasMore {
+		changes = changes[:limit]
+	}
+	if changes == nil {
+		changes = []models.ActivityChange{}
+	}
+	return changes, hasMore, nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// InitActivityReportTable 初始化月报邮件订阅表
+func InitActivityReportTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS activity_report_subscriptions (
+		user_id INTEGER PRIMARY KEY,
+		email TEXT NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		last_sent_month TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return err
+	}
+
+	log.Println("月报订阅表初始化成功")
+	return nil
+}
+
+// GetUsername 获取用户名
+func GetUsername(userID int) (string, error) {
+	var username string
+	err := DB.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username)
+	return username, err
+}
+
+// GetActivityReportSubscription 获取用户的月报订阅，未订阅返回 sql.ErrNoRows
+func GetActivityReportSubscription(userID int) (*models.ActivityReportSubscription, error) {
+	var s models.ActivityReportSubscription
+	var createdAt, updatedAt string
+	err := DB.QueryRow(
+		"SELECT user_id, email, enabled, last_sent_month, created_at, updated_at FROM activity_report_subscriptions WHERE user_id = ?",
+		userID,
+	).Scan(&s.UserID, &s.Email, &s.Enabled, &s.LastSentMonth, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.CreatedAt = utils.UTCToShanghai(createdAt)
+	s.UpdatedAt = utils.UTCToShanghai(updatedAt)
+	return &s, nil
+}
+
+// SaveActivityReportSubscription 创建或更新月报订阅（保留上次发送月份）
+func SaveActivityReportSubscription(userID int, email string, enabled bool) (*models.ActivityReportSubscription, error) {
+	now := utils.NowUTCString()
+	_, err := DB.Exec(
+		`INSERT INTO activity_report_subscriptions (user_id, email, enabled, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET email = excluded.email, enabled = excluded.enabled, updated_at = excluded.updated_at`,
+		userID, email, enabled, now, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return GetActivityReportSubscription(userID)
+}
+
+// DeleteActivityReportSubscription 取消月报订阅
+func DeleteActivityReportSubscription(userID int) error {
+	result, err := DB.Exec("DELETE FROM activity_report_subscriptions WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetDueActivityReportSubscriptions 获取尚未发送指定月份月报的已启用订阅
+func GetDueActivityReportSubscriptions(month string) ([]models.ActivityReportSubscription, error) {
+	rows, err := DB.Query(
+		"SELECT user_id, email, enabled, last_sent_month FROM activity_report_subscriptions WHERE enabled = 1 AND last_sent_month < ? ORDER BY user_id",
+		month,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []models.ActivityReportSubscription
+	for rows.Next() {
+		var s models.ActivityReportSubscription
+		if err := rows.Scan(&s.UserID, &s.Email, &s.Enabled, &s.LastSentMonth); err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// MarkActivityReportSent 记录月报已发送的月份
+func MarkActivityReportSent(userID int, month string) error {
+	_, err := DB.Exec(
+		"UPDATE activity_report_subscriptions SET last_sent_month = ? WHERE user_id = ? AND last_sent_month < ?",
+		month, userID, month,
+	)
+	return err
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// trigram 分词器要求每个检索词至少3个字符，更短的词退化为 LIKE 查询
+const ftsMinTermLength = 3
+
+const (
+	snippetOpen  = "<mark>"
+	snippetClose = "</mark>"
+	// 片段中命中词两侧保留的字符数
+	snippetContext = 12
+)
+
+// InitActivitySearchIndex 初始化备注全文索引（FTS5 trigram 分词，支持中文），通过触发器与健康活动表保持同步
+func InitActivitySearchIndex() error {
+	var exists int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'health_activities_fts'").Scan(&exists); err != nil {
+		return err
+	}
+
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS health_activities_fts USING fts5(
+			remark,
+			content = 'health_activities',
+			content_rowid = 'id',
+			tokenize = 'trigram'
+		);`,
+		`CREATE TRIGGER IF NOT EXISTS health_activities_fts_ai AFTER INSERT ON health_activities BEGIN
+			INSERT INTO health_activities_fts (rowid, remark) VALUES (new.id, COALESCE(new.remark, ''));
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS health_activities_fts_ad AFTER DELETE ON health_activities BEGIN
+			INSERT INTO health_activities_fts (health_activities_fts, rowid, remark) VALUES ('delete', old.id, COALESCE(old.remark, ''));
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS health_activities_fts_au AFTER UPDATE OF remark ON health_activities BEGIN
+			INSERT INTO health_activities_fts (health_activities_fts, rowid, remark) VALUES ('delete', old.id, COALESCE(old.remark, ''));
+			INSERT INTO health_activities_fts (rowid, remark) VALUES (new.id, COALESCE(new.remark, ''));
+		END;`,
+	}
+	for _, stmt := range statements {
+		if _, err := DB.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	// 首次创建时为已有数据建立索引
+	if exists == 0 {
+		if _, err := DB.Exec("INSERT INTO health_activities_fts (health_activities_fts) VALUES ('rebuild')"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchUserActivities 按备注搜索用户的健康活动记录（分页），返回命中片段
+func SearchUserActivities(userID int, keyword string, page, pageSize int) ([]models.ActivitySearchHit, int, error) {
+	terms := strings.Fields(keyword)
+	useFTS := len(terms) > 0
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < ftsMinTermLength {
+			useFTS = false
+			break
+		}
+	}
+	if useFTS {
+		return searchActivitiesFTS(userID, terms, page, pageSize)
+	}
+	return searchActivitiesLike(userID, terms, page, pageSize)
+}
+
+// searchActivitiesFTS 使用全文索引检索，按相关度排序
+func searchActivitiesFTS(userID int, terms []string, page, pageSize int) ([]models.ActivitySearchHit, int, error) {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	match := strings.Join(quoted, " AND ")
+
+	var total int
+	err := DB.QueryRow(
+		`SELECT COUNT(*) FROM health_activities_fts f JOIN health_activities a ON a.id = f.rowid
+		WHERE health_activities_fts MATCH ? AND a.user_id = ?`,
+		match, userID,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	rows, err := DB.Query(
+		`SELECT a.id, COALESCE(a.uuid, ''), a.user_id, a.record_date, a.record_time, a.week_day, a.duration, COALESCE(a.remark, ''), COALESCE(a.tag, 'manual'), a.created_at, COALESCE(a.updated_at, ''),
+			snippet(health_activities_fts, 0, char(1), char(2), '…', 16)
+		FROM health_activities_fts f JOIN health_activities a ON a.id = f.rowid
+		WHERE health_activities_fts MATCH ? AND a.user_id = ?
+		ORDER BY bm25(health_activities_fts), a.record_date DESC, a.record_time DESC
+		LIMIT ? OFFSET ?`,
+		match, userID, pageSize, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	hits := []models.ActivitySearchHit{}
+	for rows.Next() {
+		var hit models.ActivitySearchHit
+		var createdAt, snippet string
+		a := &hit.HealthActivity
+		if err := rows.Scan(&a.ID, &a.UUID, &a.UserID, &a.RecordDate, &a.RecordTime, &a.WeekDay, &a.Duration, &a.Remark, &a.Tag, &createdAt, &a.UpdatedAt, &snippet); err != nil {
+			continue
+		}
+		a.CreatedAt = utils.UTCToShanghai(createdAt)
+		// 先转义备注中的 HTML，再把占位符替换为高亮标签
+		hit.Snippet = strings.NewReplacer("\x01", snippetOpen, "\x02", snippetClose).Replace(html.EscapeString(snippet))
+		hits = append(hits, hit)
+	}
+	return hits, total, nil
+}
+
+// searchActivitiesLike 检索词过短时使用 LIKE 匹配，按记录时间倒序
+func searchActivitiesLike(userID int, terms []string, page, pageSize int) ([]models.ActivitySearchHit, int, error) {
+	where := "user_id = ?"
+	args := []interface{}{userID}
+	for _, term := range terms {
+		where += ` AND remark LIKE ? ESCAPE '\'`
+		args = append(args, "%"+escapeLike(term)+"%")
+	}
+
+	var total int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM health_activities WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	rows, err := DB.Query(
+		"SELECT "+activityColumns+" FROM health_activities WHERE "+where+" ORDER BY record_date DESC, record_time DESC LIMIT ? OFFSET ?",
+		append(args, pageSize, offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	hits := []models.ActivitySearchHit{}
+	for rows.Next() {
+		activity, err := scanActivity(rows)
+		if err != nil {
+			continue
+		}
+		hits = append(hits, models.ActivitySearchHit{
+			HealthActivity: *activity,
+			Snippet:        highlightSnippet(activity.Remark, terms),
+		})
+	}
+	return hits, total, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// highlightSnippet 截取第一个命中词附近的片段，并高亮所有命中词
+func highlightSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+
+	// 定位第一个命中位置
+	first := -1
+	for _, term := range terms {
+		if idx := runeIndex(lower, []rune(strings.ToLower(term))); idx >= 0 && (first < 0 || idx < first) {
+			first = idx
+		}
+	}
+	start, end := 0, len(runes)
+	if first >= 0 {
+		if first-snippetContext > 0 {
+			start = first - snippetContext
+		}
+		if first+snippetContext*2 < end {
+			end = first + snippetContext*2
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		matched := 0
+		for _, term := range terms {
+			t := []rune(strings.ToLower(term))
+			if len(t) > 0 && i+len(t) <= len(lower) && string(lower[i:i+len(t)]) == string(t) && len(t) > matched {
+				matched = len(t)
+			}
+		}
+		if matched > 0 {
+			b.WriteString(snippetOpen)
+			b.WriteString(html.EscapeString(string(runes[i : i+matched])))
+			b.WriteString(snippetClose)
+			i += matched
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func runeIndex(s, sub []rune) int {
+	if len(sub) == 0 {
+		return -1
+	}
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if string(s[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
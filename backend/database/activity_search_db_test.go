@@ -0,0 +1,124 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"backend/utils"
+)
+
+func insertSearchActivity(t *testing.T, userID int, date, remark string) int64 {
+	t.Helper()
+	res, err := DB.Exec(
+		"INSERT INTO health_activities (uuid, user_id, record_date, record_time, week_day, duration, remark, tag) VALUES (?, ?, ?, '08:00', '', 10, ?, 'manual')",
+		utils.NewUUID(), userID, date, remark,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
+
+// searchRemarks 搜索并返回命中记录的备注
+func searchRemarks(t *testing.T, userID int, keyword string) []string {
+	t.Helper()
+	hits, total, err := SearchUserActivities(userID, keyword, 1, 20)
+	if err != nil {
+		t.Fatalf("搜索 %q 失败: %v", keyword, err)
+	}
+	if total != len(hits) {
+		t.Errorf("搜索 %q 总数 = %d，返回 %d 条", keyword, total, len(hits))
+	}
+	remarks := []string{}
+	for _, h := range hits {
+		remarks = append(remarks, h.Remark)
+	}
+	return remarks
+}
+
+// 全文索引通过触发器随插入、修改备注、删除同步
+func TestActivitySearchIndexSync(t *testing.T) {
+	setupTestDB(t)
+
+	id := insertSearchActivity(t, 1, "2026-10-01", "晨跑五公里状态不错")
+	insertSearchActivity(t, 2, "2026-10-01", "晨跑五公里")
+	if got, want := searchRemarks(t, 1, "五公里"), []string{"晨跑五公里状态不错"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("插入后搜索 = %v, want %v", got, want)
+	}
+
+	if _, err := DB.Exec("UPDATE health_activities SET remark = ? WHERE id = ?", "游泳一小时", id); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchRemarks(t, 1, "五公里"); len(got) != 0 {
+		t.Errorf("修改后旧备注仍能搜到: %v", got)
+	}
+	if got, want := searchRemarks(t, 1, "游泳一"), []string{"游泳一小时"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("修改后搜索新备注 = %v, want %v", got, want)
+	}
+
+	// 修改其他字段不影响索引
+	if _, err := DB.Exec("UPDATE health_activities SET duration = 30 WHERE id = ?", id); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchRemarks(t, 1, "游泳一"); len(got) != 1 {
+		t.Errorf("修改时长后搜索 = %v", got)
+	}
+
+	if _, err := DB.Exec("DELETE FROM health_activities WHERE id = ?", id); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchRemarks(t, 1, "游泳一"); len(got) != 0 {
+		t.Errorf("删除后仍能搜到: %v", got)
+	}
+	var indexed int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM health_activities_fts WHERE health_activities_fts MATCH '\"游泳一\"'").Scan(&indexed); err != nil {
+		t.Fatal(err)
+	}
+	if indexed != 0 {
+		t.Errorf("删除后索引仍有 %d 条", indexed)
+	}
+}
+
+func TestSearchUserActivities(t *testing.T) {
+	setupTestDB(t)
+	insertSearchActivity(t, 1, "2026-10-01", "晨跑五公里")
+	insertSearchActivity(t, 1, "2026-10-02", "晚上跑步<b>很累</b>")
+	insertSearchActivity(t, 1, "2026-10-03", "折返跑 100% 完成")
+	insertSearchActivity(t, 1, "2026-10-04", "晨间 RUN")
+
+	tests := []struct {
+		name        string
+		keyword     string
+		wantRemarks []string
+		wantSnippet string // 第一条结果的片段
+	}{
+		{"全文检索", "五公里", []string{"晨跑五公里"}, "晨跑<mark>五公里</mark>"},
+		{"全文检索转义HTML", "很累<", []string{"晚上跑步<b>很累</b>"}, "晚上跑步&lt;b&gt;<mark>很累&lt;</mark>/b&gt;"},
+		{"全文检索忽略大小写", "run", []string{"晨间 RUN"}, "晨间 <mark>RUN</mark>"},
+		{"短词使用LIKE", "跑", []string{"折返跑 100% 完成", "晚上跑步<b>很累</b>", "晨跑五公里"}, ""},
+		{"多个短词同时匹配", "晨 跑", []string{"晨跑五公里"}, "<mark>晨</mark><mark>跑</mark>五公里"},
+		{"LIKE转义通配符", "0%", []string{"折返跑 100% 完成"}, "折返跑 10<mark>0%</mark> 完成"},
+		{"LIKE不把下划线当通配符", "_", []string{}, ""},
+		{"混合长短词使用LIKE", "跑 五公里", []string{"晨跑五公里"}, "晨<mark>跑</mark><mark>五公里</mark>"},
+		{"没有命中", "游泳池", []string{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, _, err := SearchUserActivities(1, tt.keyword, 1, 20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			remarks := []string{}
+			for _, h := range hits {
+				remarks = append(remarks, h.Remark)
+			}
+			if !reflect.DeepEqual(remarks, tt.wantRemarks) {
+				t.Fatalf("搜索 %q = %v, want %v", tt.keyword, remarks, tt.wantRemarks)
+			}
+			if tt.wantSnippet != "" && hits[0].Snippet != tt.wantSnippet {
+				t.Errorf("片段 = %q, want %q", hits[0].Snippet, tt.wantSnippet)
+			}
+		})
+	}
+}
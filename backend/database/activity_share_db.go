@@ -0,0 +1,211 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// InitActivityShareTable 初始化活动统计分享表和访问日志表
+func InitActivityShareTable() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS activity_share_grants (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			owner_id INTEGER NOT NULL,
+			grantee_id INTEGER,
+			token TEXT UNIQUE,
+			hide_remarks INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME,
+			revoked_at DATETIME,
+			FOREIGN KEY (owner_id) REFERENCES users(id),
+			FOREIGN KEY (grantee_id) REFERENCES users(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_activity_share_grants_owner ON activity_share_grants(owner_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_activity_share_grants_grantee ON activity_share_grants(grantee_id);`,
+		`CREATE TABLE IF NOT EXISTS activity_share_access_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			grant_id INTEGER NOT NULL,
+			viewer_id INTEGER,
+			resource TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'ok',
+			ip TEXT,
+			user_agent TEXT,
+			accessed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (grant_id) REFERENCES activity_share_grants(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_activity_share_access_logs_grant ON activity_share_access_logs(grant_id, accessed_at);`,
+	}
+	for _, stmt := range statements {
+		if _, err := DB.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	log.Println("活动统计分享表初始化成功")
+	return nil
+}
+
+// activityShareSelect 查询分享及其访问统计（与 scanActivityShare 对应）
+const activityShareSelect = `
+	SELECT g.id, g.owner_id, o.username, COALESCE(g.grantee_id, 0), COALESCE(u.username, ''), COALESCE(g.token, ''),
+		g.hide_remarks, g.created_at, COALESCE(g.expires_at, ''), COALESCE(g.revoked_at, ''),
+		(SELECT COUNT(*) FROM activity_share_access_logs l WHERE l.grant_id = g.id AND l.status = 'ok'),
+		(SELECT COUNT(*) FROM activity_share_access_logs l WHERE l.grant_id = g.id AND l.status != 'ok'),
+		COALESCE((SELECT MAX(accessed_at) FROM activity_share_access_logs l WHERE l.grant_id = g.id AND l.status = 'ok'), '')
+	FROM activity_share_grants g
+	JOIN users o ON o.id = g.owner_id
+	LEFT JOIN users u ON u.id = g.grantee_id`
+
+func scanActivityShare(s rowScanner) (*models.ActivityShareGrant, error) {
+	var g models.ActivityShareGrant
+	var createdAt, expiresAt, revokedAt, lastAccessAt string
+	err := s.Scan(&g.ID, &g.OwnerID, &g.OwnerUsername, &g.GranteeID, &g.GranteeUsername, &g.Token,
+		&g.HideRemarks, &createdAt, &expiresAt, &revokedAt, &g.AccessCount, &g.DeniedCount, &lastAccessAt)
+	if err != nil {
+		return nil, err
+	}
+	g.CreatedAt = utils.UTCToShanghai(createdAt)
+	g.ExpiresAt = utils.UTCToShanghai(expiresAt)
+	g.RevokedAt = utils.UTCToShanghai(revokedAt)
+	g.LastAccessAt = utils.UTCToShanghai(lastAccessAt)
+	return &g, nil
+}
+
+// GetUserIDByUsername 根据用户名获取用户ID
+func GetUserIDByUsername(username string) (int, error) {
+	var id int
+	err := DB.QueryRow("SELECT id FROM users WHERE username = ?", username).Scan(&id)
+	return id, err
+}
+
+// CreateActivityShare 创建统计分享：granteeID 为0时生成公开 token；expiresIn 为0表示不过期
+func CreateActivityShare(ownerID, granteeID int, hideRemarks bool, expiresIn time.Duration) (*models.ActivityShareGrant, error) {
+	var grantee, token, expiresAt interface{}
+	if granteeID > 0 {
+		grantee = granteeID
+	} else {
+		t, err := GenerateShareToken()
+		if err != nil {
+			return nil, err
+		}
+		token = t
+	}
+	if expiresIn > 0 {
+		expiresAt = utils.NowUTC().Add(expiresIn).Format("2006-01-02 15:04:05")
+	}
+
+	result, err := DB.Exec(
+		"INSERT INTO activity_share_grants (owner_id, grantee_id, token, hide_remarks, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		ownerID, grantee, token, hideRemarks, utils.NowUTCString(), expiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return GetActivityShareByID(int(id), ownerID)
+}
+
+// GetActivityShareByID 获取拥有者的某个分享
+func GetActivityShareByID(id, ownerID int) (*models.ActivityShareGrant, error) {
+	return scanActivityShare(DB.QueryRow(activityShareSelect+" WHERE g.id = ? AND g.owner_id = ?", id, ownerID))
+}
+
+// GetActivityShareByToken 通过公开 token 获取分享（不检查有效期）
+func GetActivityShareByToken(token string) (*models.ActivityShareGrant, error) {
+	return scanActivityShare(DB.QueryRow(activityShareSelect+" WHERE g.token = ?", token))
+}
+
+// GetActivityShare 获取分享（不检查拥有者、被授权用户和有效期）
+func GetActivityShare(id int) (*models.ActivityShareGrant, error) {
+	return scanActivityShare(DB.QueryRow(activityShareSelect+" WHERE g.id = ?", id))
+}
+
+// GetUserActivityShares 获取用户创建的所有分享（含已撤销）
+func GetUserActivityShares(ownerID int) ([]models.ActivityShareGrant, error) {
+	return queryActivityShares(activityShareSelect+" WHERE g.owner_id = ? ORDER BY g.id DESC", ownerID)
+}
+
+// GetReceivedActivityShares 获取授权给用户且仍有效的分享
+func GetReceivedActivityShares(granteeID int) ([]models.ActivityShareGrant, error) {
+	return queryActivityShares(
+		activityShareSelect+" WHERE g.grantee_id = ? AND g.revoked_at IS NULL AND (g.expires_at IS NULL OR g.expires_at > ?) ORDER BY g.id DESC",
+		granteeID, utils.NowUTCString(),
+	)
+}
+
+func queryActivityShares(query string, args ...interface{}) ([]models.ActivityShareGrant, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []models.ActivityShareGrant{}
+	for rows.Next() {
+		g, err := scanActivityShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, *g)
+	}
+	return grants, rows.Err()
+}
+
+// RevokeActivityShare 撤销分享（保留记录和访问日志）
+func RevokeActivityShare(id, ownerID int) error {
+	result, err := DB.Exec(
+		"UPDATE activity_share_grants SET revoked_at = ? WHERE id = ? AND owner_id = ? AND revoked_at IS NULL",
+		utils.NowUTCString(), id, ownerID,
+	)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// LogActivityShareAccess 记录一次分享访问及其结果，viewerID 为0表示公开访问
+func LogActivityShareAccess(grantID, viewerID int, resource, status, ip, userAgent string) error {
+	var viewer interface{}
+	if viewerID > 0 {
+		viewer = viewerID
+	}
+	_, err := DB.Exec(
+		"INSERT INTO activity_share_access_logs (grant_id, viewer_id, resource, status, ip, user_agent, accessed_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		grantID, viewer, resource, status, ip, userAgent, utils.NowUTCString(),
+	)
+	return err
+}
+
+// GetActivityShareAccessLogs 获取分享的访问日志（最近的在前）
+func GetActivityShareAccessLogs(grantID, limit int) ([]models.ActivityShareAccessLog, error) {
+	rows, err := DB.Query(
+		`SELECT l.id, l.grant_id, COALESCE(l.viewer_id, 0), COALESCE(u.username, ''), l.resource, l.status, COALESCE(l.ip, ''), COALESCE(l.user_agent, ''), l.accessed_at
+		FROM activity_share_access_logs l LEFT JOIN users u ON u.id = l.viewer_id
+		WHERE l.grant_id = ? ORDER BY l.id DESC LIMIT ?`,
+		grantID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []models.ActivityShareAccessLog{}
+	for rows.Next() {
+		var l models.ActivityShareAccessLog
+		var accessedAt string
+		if err := rows.Scan(&l.ID, &l.GrantID, &l.ViewerID, &l.ViewerUsername, &l.Resource, &l.Status, &l.IP, &l.UserAgent, &accessedAt); err != nil {
+			return nil, err
+		}
+		l.AccessedAt = utils.UTCToShanghai(accessedAt)
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"backend/models"
+)
+
+const (
+	syncUUIDA = "aaaaaaaa-0000-0000-0000-000000000001"
+	syncUUIDB = "aaaaaaaa-0000-0000-0000-000000000002"
+	syncUUIDC = "aaaaaaaa-0000-0000-0000-000000000003"
+	syncUUIDD = "aaaaaaaa-0000-0000-0000-000000000004"
+)
+
+// syncStep 一次同步请求中的单个修改及期望的处理结果
+type syncStep struct {
+	op         string // upsert / delete
+	uuid       string
+	at         string // 客户端修改时间
+	duration   int
+	wantStatus string
+}
+
+func syncMutation(key string, s syncStep) models.ActivitySyncMutation {
+	m := models.ActivitySyncMutation{IdempotencyKey: key, Op: s.op, UUID: s.uuid, UpdatedAt: s.at}
+	if s.op == "upsert" {
+		m.Data = &models.ActivitySyncRecord{RecordDate: "2026-01-01", RecordTime: "08:00", Duration: s.duration, Tag: "auto"}
+	}
+	return m
+}
+
+// applySyncSteps 每个修改单独提交一次同步请求，返回最后一次的结果
+func applySyncSteps(t *testing.T, userID int, prefix string, steps []syncStep) {
+	t.Helper()
+	for i, s := range steps {
+		req := &models.ActivitySyncRequest{Limit: 100, Mutations: []models.ActivitySyncMutation{syncMutation(fmt.Sprintf("%s-%d", prefix, i), s)}}
+		data, _, err := ApplyActivitySync(userID, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := data.Results[0].Status; got != s.wantStatus {
+			t.Fatalf("第 %d 个修改（%s %s）状态 = %s（%s），want %s", i+1, s.op, s.at, got, data.Results[0].Message, s.wantStatus)
+		}
+	}
+}
+
+func TestActivitySyncConflicts(t *testing.T) {
+	tests := []struct {
+		name         string
+		steps        []syncStep
+		wantDuration int    // 最终记录的时长，0 表示记录不存在
+		wantTomb     string // 最终墓碑的删除时间，空表示没有墓碑
+	}{
+		{
+			name: "较旧的修改落败",
+			steps: []syncStep{
+				{"upsert", syncUUIDA, "2026-01-02T00:00:00Z", 20, "applied"},
+				{"upsert", syncUUIDA, "2026-01-01T00:00:00Z", 10, "conflict"},
+			},
+			wantDuration: 20,
+		},
+		{
+			name: "较新的修改覆盖",
+			steps: []syncStep{
+				{"upsert", syncUUIDA, "2026-01-01T00:00:00Z", 10, "applied"},
+				{"upsert", syncUUIDA, "2026-01-02T00:00:00Z", 20, "applied"},
+			},
+			wantDuration: 20,
+		},
+		{
+			name: "较新的删除胜过较旧的修改",
+			steps: []syncStep{
+				{"upsert", syncUUIDA, "2026-01-01T00:00:00Z", 10, "applied"},
+				{"delete", syncUUIDA, "2026-01-03T00:00:00Z", 0, "applied"},
+				{"upsert", syncUUIDA, "2026-01-02T00:00:00Z", 20, "conflict"},
+			},
+			wantTomb: "2026-01-03T00:00:00.000Z",
+		},
+		{
+			name: "较旧的删除落败",
+			steps: []syncStep{
+				{"upsert", syncUUIDA, "2026-01-03T00:00:00Z", 10, "applied"},
+				{"delete", syncUUIDA, "2026-01-02T00:00:00Z", 0, "conflict"},
+			},
+			wantDuration: 10,
+		},
+		{
+			name: "时间相同时删除优先",
+			steps: []syncStep{
+				{"upsert", syncUUIDA, "2026-01-02T00:00:00Z", 10, "applied"},
+				{"delete", syncUUIDA, "2026-01-02T00:00:00Z", 0, "applied"},
+			},
+			wantTomb: "2026-01-02T00:00:00.000Z",
+		},
+		{
+			name: "删除后较新的修改复活记录",
+			steps: []syncStep{
+				{"delete", syncUUIDA, "2026-01-01T00:00:00Z", 0, "applied"},
+				{"upsert", syncUUIDA, "2026-01-02T00:00:00Z", 30, "applied"},
+			},
+			wantDuration: 30,
+		},
+		{
+			name: "较新的删除更新墓碑",
+			steps: []syncStep{
+				{"delete", syncUUIDA, "2026-01-01T00:00:00Z", 0, "applied"},
+				{"delete", syncUUIDA, "2026-01-02T00:00:00Z", 0, "applied"},
+				{"upsert", syncUUIDA, "2026-01-01T12:00:00Z", 10, "conflict"},
+			},
+			wantTomb: "2026-01-02T00:00:00.000Z",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			userID := i + 1
+			applySyncSteps(t, userID, "k", tt.steps)
+
+			var duration int
+			err := DB.QueryRow("SELECT duration FROM health_activities WHERE user_id = ? AND uuid = ?", userID, syncUUIDA).Scan(&duration)
+			if err != nil && tt.wantDuration != 0 {
+				t.Fatalf("记录不存在: %v", err)
+			}
+			if duration != tt.wantDuration {
+				t.Errorf("记录时长 = %d, want %d", duration, tt.wantDuration)
+			}
+
+			var tomb string
+			DB.QueryRow("SELECT deleted_at FROM activity_tombstones WHERE user_id = ? AND uuid = ?", userID, syncUUIDA).Scan(&tomb)
+			if tomb != tt.wantTomb {
+				t.Errorf("墓碑删除时间 = %q, want %q", tomb, tt.wantTomb)
+			}
+		})
+	}
+}
+
+func TestActivitySyncIdempotentReplay(t *testing.T) {
+	setupTestDB(t)
+	m := syncMutation("same-key", syncStep{op: "upsert", uuid: syncUUIDA, at: "2026-01-01T00:00:00Z", duration: 10})
+	for i, wantReplayed := range []bool{false, true} {
+		data, _, err := ApplyActivitySync(1, &models.ActivitySyncRequest{Limit: 100, Mutations: []models.ActivitySyncMutation{m}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := data.Results[0]
+		if r.Status != "applied" || r.Replayed != wantReplayed {
+			t.Errorf("第 %d 次提交: status=%s replayed=%v, want applied replayed=%v", i+1, r.Status, r.Replayed, wantReplayed)
+		}
+	}
+	var n int
+	DB.QueryRow("SELECT COUNT(*) FROM health_activities WHERE user_id = 1").Scan(&n)
+	if n != 1 {
+		t.Errorf("记录数 = %d, want 1", n)
+	}
+}
+
+// 较新的删除更新已有墓碑时分配新序号，增量拉取的客户端能收到新的删除时间
+func TestActivityTombstoneSeqBump(t *testing.T) {
+	setupTestDB(t)
+	applySyncSteps(t, 1, "k", []syncStep{{"delete", syncUUIDA, "2026-01-01T00:00:00Z", 0, "applied"}})
+
+	changes, _, err := GetActivityChanges(1, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || !changes[0].Deleted {
+		t.Fatalf("首次删除后的变更 = %+v", changes)
+	}
+	cursor := changes[0].Seq
+
+	applySyncSteps(t, 1, "k2", []syncStep{{"delete", syncUUIDA, "2026-01-02T00:00:00Z", 0, "applied"}})
+	changes, _, err = GetActivityChanges(1, cursor, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Seq <= cursor || changes[0].DeletedAt != "2026-01-02T00:00:00.000Z" {
+		t.Fatalf("游标 %d 之后的变更 = %+v，应包含更新后的墓碑", cursor, changes)
+	}
+}
+
+// 记录和墓碑分两次查询后按序号合并，分页时不遗漏也不重复
+func TestActivityChangesPaging(t *testing.T) {
+	setupTestDB(t)
+	applySyncSteps(t, 1, "k", []syncStep{
+		{"upsert", syncUUIDA, "2026-01-01T00:00:00Z", 10, "applied"},
+		{"upsert", syncUUIDB, "2026-01-01T00:00:00Z", 10, "applied"},
+		{"delete", syncUUIDA, "2026-01-02T00:00:00Z", 0, "applied"},
+		{"upsert", syncUUIDC, "2026-01-01T00:00:00Z", 10, "applied"},
+		{"delete", syncUUIDD, "2026-01-01T00:00:00Z", 0, "applied"},
+	})
+	// 其他用户的变更不应出现
+	applySyncSteps(t, 2, "other", []syncStep{{"upsert", syncUUIDA, "2026-01-01T00:00:00Z", 10, "applied"}})
+
+	type change struct {
+		uuid    string
+		deleted bool
+	}
+	want := []change{{syncUUIDB, false}, {syncUUIDA, true}, {syncUUIDC, false}, {syncUUIDD, true}}
+
+	for _, limit := range []int{1, 2, 3, 4, 10} {
+		t.Run(fmt.Sprintf("limit=%d", limit), func(t *testing.T) {
+			var got []change
+			var cursor int64
+			for page := 0; ; page++ {
+				if page > len(want) {
+					t.Fatal("分页未结束")
+				}
+				changes, hasMore, err := GetActivityChanges(1, cursor, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(changes) > limit {
+					t.Fatalf("返回 %d 条，超过 limit %d", len(changes), limit)
+				}
+				for _, c := range changes {
+					if c.Seq <= cursor {
+						t.Fatalf("序号未递增: %d <= %d", c.Seq, cursor)
+					}
+					cursor = c.Seq
+					got = append(got, change{c.UUID, c.Deleted})
+				}
+				if !hasMore {
+					break
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("变更 = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -10,7 +10,8 @@ import (
 // InitDB 初始化数据库连接
 func InitDB(dbPath string) error {
 	var err error
-	DB, err = sql.Open("sqlite", dbPath)
+	// 后台任务与请求会并发写库，设置忙等待避免 SQLITE_BUSY（对连接池中每个连接生效）
+	DB, err = sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		return err
 	}
@@ -56,15 +57,60 @@ func InitDB(dbPath string) error {
 		_, _ = DB.Exec("ALTER TABLE health_activities ADD COLUMN tag TEXT DEFAULT 'manual'")
 	}
 
+	// 初始化健康活动同步字段及墓碑表
+	if err := InitActivitySyncTable(); err != nil {
+		return err
+	}
+
+	// 初始化健康活动备注全文索引
+	if err := InitActivitySearchIndex(); err != nil {
+		return err
+	}
+
+	// 初始化月报订阅表
+	if err := InitActivityReportTable(); err != nil {
+		return err
+	}
+
+	// 初始化活动统计分享表
+	if err := InitActivityShareTable(); err != nil {
+		return err
+	}
+
          // 初始化抖音文件表
         if err := InitDouyinTable(); err != nil {
             return err
         }
 
+	// 初始化抖音下载任务表
+	if err := InitDouyinJobTable(); err != nil {
+		return err
+	}
+
+	// 初始化抖音批量解析批次表
+	if err := InitDouyinBatchTable(); err != nil {
+		return err
+	}
+
+	// 初始化创作者订阅表
+	if err := InitDouyinSubscriptionTable(); err != nil {
+		return err
+	}
+
+	// 初始化抖音视频分享表
+	if err := InitDouyinShareTable(); err != nil {
+		return err
+	}
+
 	// 初始化文件传输表
 	if err := InitFileTransferTable(); err != nil {
 		return err
 	}
+
+	// 初始化断点续传上传表
+	if err := InitTusUploadTable(); err != nil {
+		return err
+	}
 	
 	// 初始化音乐表
 	if err := InitMusicTable(); err != nil {
@@ -81,6 +127,11 @@ func InitDB(dbPath string) error {
 		return err
 	}
 
+	// 初始化回收站表
+	if err := InitTrashTable(); err != nil {
+		return err
+	}
+
 	log.Println("数据库初始化成功")
 	return nil
 }
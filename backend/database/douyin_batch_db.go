@@ -0,0 +1,105 @@
+package database
+
+import (
+	"log"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// InitDouyinBatchTable 初始化抖音批量解析批次表
+func InitDouyinBatchTable() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS douyin_batches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS douyin_batch_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			batch_id INTEGER NOT NULL,
+			url TEXT NOT NULL,
+			aweme_id TEXT NOT NULL DEFAULT '',
+			job_id INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL,
+			message TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (batch_id) REFERENCES douyin_batches(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_douyin_batch_items_batch ON douyin_batch_items(batch_id, id);`,
+	}
+	for _, stmt := range statements {
+		if _, err := DB.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	log.Println("抖音批量解析批次表初始化成功")
+	return nil
+}
+
+// CreateDouyinBatch 保存一次批量解析的各链接结果，返回批次ID
+func CreateDouyinBatch(userID int, items []models.DouyinParsingItem) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO douyin_batches (user_id, created_at) VALUES (?, ?)", userID, utils.NowUTCString())
+	if err != nil {
+		return 0, err
+	}
+	batchID, _ := result.LastInsertId()
+
+	for _, item := range items {
+		_, err := tx.Exec(
+			"INSERT INTO douyin_batch_items (batch_id, url, aweme_id, job_id, status, message) VALUES (?, ?, ?, ?, ?, ?)",
+			batchID, item.URL, item.AwemeID, item.JobID, item.Status, item.Message,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int(batchID), tx.Commit()
+}
+
+// GetDouyinBatch 获取用户的批次，各链接的状态取自其下载任务的当前状态；批次不存在时返回 sql.ErrNoRows
+func GetDouyinBatch(id, userID int) (*models.DouyinBatch, error) {
+	var batch models.DouyinBatch
+	var createdAt string
+	err := DB.QueryRow("SELECT id, created_at FROM douyin_batches WHERE id = ? AND user_id = ?", id, userID).Scan(&batch.ID, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	batch.CreatedAt = utils.UTCToShanghai(createdAt)
+
+	rows, err := DB.Query(
+		`SELECT i.url, i.aweme_id, i.job_id, COALESCE(j.status, i.status), COALESCE(j.message, i.message)
+		FROM douyin_batch_items i
+		LEFT JOIN douyin_jobs j ON j.id = i.job_id
+		WHERE i.batch_id = ?
+		ORDER BY i.id`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batch.Done = true
+	batch.Summary = make(map[string]int)
+	batch.Items = []models.DouyinParsingItem{}
+	for rows.Next() {
+		var item models.DouyinParsingItem
+		if err := rows.Scan(&item.URL, &item.AwemeID, &item.JobID, &item.Status, &item.Message); err != nil {
+			return nil, err
+		}
+		batch.Summary[item.Status]++
+		if item.Status == models.DouyinJobQueued || item.Status == models.DouyinJobRunning {
+			batch.Done = false
+		}
+		batch.Items = append(batch.Items, item)
+	}
+	return &batch, rows.Err()
+}
@@ -9,10 +9,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 var DB *sql.DB
 
+// douyinFileRefMu 使为已有文件新增引用（CreateFileRecordForUser）与删除物理文件（DeleteDouyinFile）互斥，
+// 避免删除时刚好有其他用户复用同一文件，留下指向已删除文件的记录
+var douyinFileRefMu sync.Mutex
+
 // 初始化抖音文件表
 func InitDouyinTable() error {
 	// 创建抖音URL表（存储解析的URL）
@@ -58,6 +63,28 @@ func InitDouyinTable() error {
 	// SQLite不支持直接删除UNIQUE约束，需要重建表，这里先忽略错误
 	_, _ = DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_user_path ON douyin_files(user_id, path)")
 
+	// 作品ID（短链和完整链接指向同一作品时ID相同），用于跨链接形式去重
+	_, _ = DB.Exec("ALTER TABLE douyin_urls ADD COLUMN aweme_id TEXT NOT NULL DEFAULT ''")
+	_, _ = DB.Exec("ALTER TABLE douyin_files ADD COLUMN aweme_id TEXT NOT NULL DEFAULT ''")
+	_, _ = DB.Exec("CREATE INDEX IF NOT EXISTS idx_douyin_urls_aweme ON douyin_urls(aweme_id)")
+	_, _ = DB.Exec("CREATE INDEX IF NOT EXISTS idx_douyin_files_aweme ON douyin_files(aweme_id)")
+
+	// 视频元数据
+	_, _ = DB.Exec("ALTER TABLE douyin_files ADD COLUMN author TEXT NOT NULL DEFAULT ''")
+	_, _ = DB.Exec("ALTER TABLE douyin_files ADD COLUMN description TEXT NOT NULL DEFAULT ''")
+	_, _ = DB.Exec("ALTER TABLE douyin_files ADD COLUMN create_time TEXT NOT NULL DEFAULT ''")
+	_, _ = DB.Exec("ALTER TABLE douyin_files ADD COLUMN duration REAL NOT NULL DEFAULT 0")
+	_, _ = DB.Exec("ALTER TABLE douyin_files ADD COLUMN width INTEGER NOT NULL DEFAULT 0")
+	_, _ = DB.Exec("ALTER TABLE douyin_files ADD COLUMN height INTEGER NOT NULL DEFAULT 0")
+	_, _ = DB.Exec("ALTER TABLE douyin_files ADD COLUMN cover_path TEXT NOT NULL DEFAULT ''")
+	_, _ = DB.Exec("ALTER TABLE douyin_files ADD COLUMN cover_url TEXT NOT NULL DEFAULT ''")
+
+	// 链接的下载状态：下载失败的链接也会记录，用于展示失败列表和手动重试
+	_, _ = DB.Exec("ALTER TABLE douyin_urls ADD COLUMN status TEXT NOT NULL DEFAULT 'succeeded'")
+	_, _ = DB.Exec("ALTER TABLE douyin_urls ADD COLUMN last_error TEXT NOT NULL DEFAULT ''")
+	_, _ = DB.Exec("ALTER TABLE douyin_urls ADD COLUMN last_job_id INTEGER NOT NULL DEFAULT 0")
+	_, _ = DB.Exec("ALTER TABLE douyin_urls ADD COLUMN updated_at DATETIME")
+
 	return nil
 }
 
@@ -81,39 +108,120 @@ func FileExistsWithURL(userID int, path string, url string) (bool, error) {
 	return count > 0, nil
 }
 
+// 匹配同一URL或同一作品ID（作品ID为空时只按URL匹配），参数依次为 url, awemeID, awemeID
+const douyinURLMatch = "(url = ? OR (? != '' AND aweme_id = ?))"
+
+// 视频元数据列，顺序与 models.DouyinFile 中的元数据字段一致
+const douyinFileMetaColumns = "author, description, create_time, duration, width, height, cover_path, cover_url"
+
 // 保存抖音文件信息（如果已存在则忽略，保证唯一性）
 func SaveDouyinFile(file *models.DouyinFile) error {
 	_, err := DB.Exec(
-		"INSERT OR IGNORE INTO douyin_files (user_id, url, file_name, file_size, file_size_str, modified_time, path) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		file.UserID, file.URL, file.FileName, file.FileSize, file.FileSizeStr, file.ModifiedTime, file.Path,
+		"INSERT OR IGNORE INTO douyin_files (user_id, url, aweme_id, file_name, file_size, file_size_str, modified_time, path, "+douyinFileMetaColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		file.UserID, file.URL, file.AwemeID, file.FileName, file.FileSize, file.FileSizeStr, file.ModifiedTime, file.Path,
+		file.Author, file.Description, file.CreateTime, file.Duration, file.Width, file.Height, file.CoverPath, file.CoverURL,
 	)
 	return err
 }
 
-// 检查URL是否已解析（检查douyin_urls表）
-func URLExists(userID int, url string) (bool, error) {
+// 复制同一物理文件已有记录（任意用户）的元数据
+func copyDouyinFileMetadata(file *models.DouyinFile) {
+	_ = DB.QueryRow(
+		"SELECT "+douyinFileMetaColumns+" FROM douyin_files WHERE path = ? ORDER BY id LIMIT 1",
+		file.Path,
+	).Scan(&file.Author, &file.Description, &file.CreateTime, &file.Duration, &file.Width, &file.Height, &file.CoverPath, &file.CoverURL)
+}
+
+// 检查URL或作品是否已成功解析（检查douyin_urls表）
+func URLExists(userID int, url, awemeID string) (bool, error) {
 	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM douyin_urls WHERE user_id = ? AND url = ?", userID, url).Scan(&count)
+	err := DB.QueryRow("SELECT COUNT(*) FROM douyin_urls WHERE user_id = ? AND status = ? AND "+douyinURLMatch, userID, models.DouyinURLSucceeded, url, awemeID, awemeID).Scan(&count)
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-// 保存解析的URL
-func SaveDouyinURL(userID int, url string) error {
+// 保存解析成功的URL及其作品ID（清除之前的失败状态）
+func SaveDouyinURL(userID int, url, awemeID string) error {
 	_, err := DB.Exec(
-		"INSERT OR IGNORE INTO douyin_urls (user_id, url) VALUES (?, ?)",
-		userID, url,
+		`INSERT INTO douyin_urls (user_id, url, aweme_id, status, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, url) DO UPDATE SET
+			aweme_id = CASE WHEN excluded.aweme_id != '' THEN excluded.aweme_id ELSE aweme_id END,
+			status = excluded.status, last_error = '', updated_at = excluded.updated_at`,
+		userID, url, awemeID, models.DouyinURLSucceeded, utils.NowUTCString(),
 	)
 	return err
 }
 
-// 检查URL对应的文件是否存在（检查特定用户）
-func CheckURLFilesExist(userID int, url string) (bool, error) {
+// 记录URL下载失败（status 为 retrying 或 failed）及对应的任务
+func SaveDouyinURLFailure(userID int, url, awemeID, status, lastError string, jobID int) error {
+	_, err := DB.Exec(
+		`INSERT INTO douyin_urls (user_id, url, aweme_id, status, last_error, last_job_id, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, url) DO UPDATE SET
+			aweme_id = CASE WHEN excluded.aweme_id != '' THEN excluded.aweme_id ELSE aweme_id END,
+			status = excluded.status, last_error = excluded.last_error, last_job_id = excluded.last_job_id, updated_at = excluded.updated_at`,
+		userID, url, awemeID, status, lastError, jobID, utils.NowUTCString(),
+	)
+	return err
+}
+
+// 任务被取消时，把仍标记为重试中的URL改为失败
+func MarkDouyinURLCancelled(jobID int) error {
+	_, err := DB.Exec(
+		"UPDATE douyin_urls SET status = ?, last_error = '已取消', updated_at = ? WHERE last_job_id = ? AND status = ?",
+		models.DouyinURLFailed, utils.NowUTCString(), jobID, models.DouyinURLRetrying,
+	)
+	return err
+}
+
+// 获取用户下载失败（含等待自动重试）的URL，最近的在前
+func GetUserFailedDouyinURLs(userID int) ([]models.DouyinFailedURL, error) {
+	rows, err := DB.Query(
+		`SELECT u.id, u.url, u.aweme_id, u.status, u.last_error, u.last_job_id, COALESCE(l.attempts, j.attempts, 0), COALESCE(u.updated_at, u.created_at)
+		FROM douyin_urls u
+		LEFT JOIN douyin_jobs j ON j.id = u.last_job_id
+		LEFT JOIN douyin_jobs l ON l.id = j.leader_id AND j.leader_id != 0
+		WHERE u.user_id = ? AND u.status != ?
+		ORDER BY COALESCE(u.updated_at, u.created_at) DESC, u.id DESC`,
+		userID, models.DouyinURLSucceeded,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.DouyinFailedURL{}
+	for rows.Next() {
+		var f models.DouyinFailedURL
+		var updatedAt string
+		if err := rows.Scan(&f.ID, &f.URL, &f.AwemeID, &f.Status, &f.LastError, &f.JobID, &f.Attempts, &updatedAt); err != nil {
+			return nil, err
+		}
+		f.UpdatedAt = utils.UTCToShanghai(updatedAt)
+		list = append(list, f)
+	}
+	return list, rows.Err()
+}
+
+// 获取用户的某个下载失败的URL
+func GetUserFailedDouyinURL(id, userID int) (*models.DouyinFailedURL, error) {
+	var f models.DouyinFailedURL
+	err := DB.QueryRow(
+		"SELECT id, url, aweme_id, status, last_error, last_job_id FROM douyin_urls WHERE id = ? AND user_id = ? AND status != ?",
+		id, userID, models.DouyinURLSucceeded,
+	).Scan(&f.ID, &f.URL, &f.AwemeID, &f.Status, &f.LastError, &f.JobID)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// 检查URL或作品对应的文件是否存在（检查特定用户）
+func CheckURLFilesExist(userID int, url, awemeID string) (bool, error) {
 	rows, err := DB.Query(
-		"SELECT path FROM douyin_files WHERE user_id = ? AND url = ?",
-		userID, url,
+		"SELECT path FROM douyin_files WHERE user_id = ? AND "+douyinURLMatch,
+		userID, url, awemeID, awemeID,
 	)
 	if err != nil {
 		return false, err
@@ -136,11 +244,11 @@ func CheckURLFilesExist(userID int, url string) (bool, error) {
 	return hasFiles, nil
 }
 
-// 检查URL是否已被任何用户解析过且文件存在
-func CheckURLParsedAndFileExists(url string) (bool, []string, error) {
+// 检查URL或作品是否已被任何用户解析过且文件存在
+func CheckURLParsedAndFileExists(url, awemeID string) (bool, []string, error) {
 	rows, err := DB.Query(
-		"SELECT DISTINCT path FROM douyin_files WHERE url = ?",
-		url,
+		"SELECT DISTINCT path FROM douyin_files WHERE "+douyinURLMatch,
+		url, awemeID, awemeID,
 	)
 	if err != nil {
 		return false, nil, err
@@ -165,7 +273,10 @@ func CheckURLParsedAndFileExists(url string) (bool, []string, error) {
 }
 
 // 为用户创建文件记录（如果不存在）
-func CreateFileRecordForUser(userID int, url string, paths []string) error {
+func CreateFileRecordForUser(userID int, url, awemeID string, paths []string) error {
+	douyinFileRefMu.Lock()
+	defer douyinFileRefMu.Unlock()
+
 	for _, path := range paths {
 		// 检查文件是否存在
 		fileInfo, err := os.Stat(path)
@@ -204,6 +315,7 @@ func CreateFileRecordForUser(userID int, url string, paths []string) error {
 		file := models.DouyinFile{
 			UserID:       userID,
 			URL:          url,
+			AwemeID:      awemeID,
 			FileName:     filepath.Base(path),
 			FileSize:     fileSize,
 			FileSizeStr:  fileSizeStr,
@@ -211,6 +323,7 @@ func CreateFileRecordForUser(userID int, url string, paths []string) error {
 			Path:         path,
 			CreatedAt:    utils.NowUTCString(), // 存储 UTC 时间
 		}
+		copyDouyinFileMetadata(&file)
 
 		if err := SaveDouyinFile(&file); err != nil {
 			log.Printf("为用户创建文件记录失败: %v", err)
@@ -234,59 +347,94 @@ func formatFileSizeInDB(size int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
 }
 
-// 获取用户的文件列表
-func GetUserDouyinFiles(userID int) ([]models.DouyinFile, error) {
-	rows, err := DB.Query(
-		"SELECT id, user_id, url, file_name, file_size, file_size_str, modified_time, path, created_at FROM douyin_files WHERE user_id = ? ORDER BY created_at DESC",
-		userID,
-	)
+// 根据ID获取文件信息
+func GetDouyinFileByID(id, userID int) (*models.DouyinFile, error) {
+	return scanDouyinFile(DB.QueryRow(
+		"SELECT "+douyinFileColumns+" FROM douyin_files WHERE id = ? AND user_id = ?",
+		id, userID,
+	))
+}
+
+// DeleteDouyinFile 在事务中删除用户的文件记录；没有其他记录引用同一路径时才删除物理文件（及封面），
+// 用户已没有该URL的文件时同时删除URL记录。返回物理文件是否被删除，记录不存在时返回 sql.ErrNoRows
+func DeleteDouyinFile(id, userID int) (bool, error) {
+	douyinFileRefMu.Lock()
+	defer douyinFileRefMu.Unlock()
+
+	tx, err := DB.Begin()
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var files []models.DouyinFile
-	for rows.Next() {
-		var file models.DouyinFile
-		err := rows.Scan(
-			&file.ID,
-			&file.UserID,
-			&file.URL,
-			&file.FileName,
-			&file.FileSize,
-			&file.FileSizeStr,
-			&file.ModifiedTime,
-			&file.Path,
-			&file.CreatedAt,
-		)
-		if err != nil {
-			continue
+	var path, url, coverPath string
+	err = tx.QueryRow(
+		"SELECT path, COALESCE(url, ''), cover_path FROM douyin_files WHERE id = ? AND user_id = ?",
+		id, userID,
+	).Scan(&path, &url, &coverPath)
+	if err != nil {
+		return false, err
+	}
+
+	// 先删除记录：事务持有写锁，其他用户在此期间无法新增引用该路径的记录
+	if _, err := tx.Exec("DELETE FROM douyin_files WHERE id = ?", id); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("DELETE FROM douyin_shares WHERE file_id = ?", id); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(
+		"DELETE FROM douyin_urls WHERE user_id = ? AND url = ? AND NOT EXISTS (SELECT 1 FROM douyin_files WHERE user_id = ? AND url = ?)",
+		userID, url, userID, url,
+	); err != nil {
+		return false, err
+	}
+
+	// 在事务内决定是否删除物理文件，提交成功后才真正删除，避免回滚后记录指向已删除的文件
+	var refs int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM douyin_files WHERE path = ?", path).Scan(&refs); err != nil {
+		return false, err
+	}
+	removeCover := false
+	if refs == 0 && coverPath != "" {
+		var coverRefs int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM douyin_files WHERE cover_path = ?", coverPath).Scan(&coverRefs); err != nil {
+			return false, err
 		}
-		files = append(files, file)
+		removeCover = coverRefs == 0
 	}
 
-	return files, nil
-}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	if refs > 0 {
+		return false, nil
+	}
+	// 删除前再确认一次引用数：下载任务入库时不经过 douyinFileRefMu，可能在提交后新增了引用
+	if err := DB.QueryRow("SELECT COUNT(*) FROM douyin_files WHERE path = ?", path).Scan(&refs); err != nil {
+		return false, err
+	}
+	if refs > 0 {
+		return false, nil
+	}
+	if removeCover {
+		var coverRefs int
+		if err := DB.QueryRow("SELECT COUNT(*) FROM douyin_files WHERE cover_path = ?", coverPath).Scan(&coverRefs); err != nil {
+			return false, err
+		}
+		removeCover = coverRefs == 0
+	}
 
-// 根据ID获取文件信息
-func GetDouyinFileByID(id, userID int) (*models.DouyinFile, error) {
-	var file models.DouyinFile
-	err := DB.QueryRow(
-		"SELECT id, user_id, url, file_name, file_size, file_size_str, modified_time, path, created_at FROM douyin_files WHERE id = ? AND user_id = ?",
-		id, userID,
-	).Scan(
-		&file.ID,
-		&file.UserID,
-		&file.URL,
-		&file.FileName,
-		&file.FileSize,
-		&file.FileSizeStr,
-		&file.ModifiedTime,
-		&file.Path,
-		&file.CreatedAt,
-	)
-	if err != nil {
-		return nil, err
+	// 记录已删除，物理文件删除失败只记录日志（残留文件不再被引用）
+	removed := true
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("删除文件失败: %s, %v", path, err)
+		removed = false
+	}
+	if removeCover {
+		if err := os.Remove(coverPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除封面失败: %s, %v", coverPath, err)
+		}
 	}
-	return &file, nil
+	return removed, nil
 }
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"backend/models"
+)
+
+// saveTestDouyinFile 写入视频和封面文件，并为用户保存记录
+func saveTestDouyinFile(t *testing.T, dir string, userID int) (id int, path, cover string) {
+	t.Helper()
+	path = filepath.Join(dir, "video.mp4")
+	cover = filepath.Join(dir, "video.jpg")
+	for _, p := range []string{path, cover} {
+		if err := os.WriteFile(p, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := &models.DouyinFile{UserID: userID, URL: "https://www.douyin.com/video/1", FileName: "video.mp4", Path: path, CoverPath: cover}
+	if err := SaveDouyinFile(file); err != nil {
+		t.Fatal(err)
+	}
+	return douyinFileID(t, userID, path), path, cover
+}
+
+func douyinFileID(t *testing.T, userID int, path string) int {
+	t.Helper()
+	var id int
+	if err := DB.QueryRow("SELECT id FROM douyin_files WHERE user_id = ? AND path = ?", userID, path).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestDeleteDouyinFileSharedPath(t *testing.T) {
+	setupTestDB(t)
+	id1, path, cover := saveTestDouyinFile(t, t.TempDir(), 1)
+	if err := CreateFileRecordForUser(2, "https://v.douyin.com/abc/", "", []string{path}); err != nil {
+		t.Fatal(err)
+	}
+	id2 := douyinFileID(t, 2, path)
+
+	// 其他用户仍引用同一文件时只删除记录
+	removed, err := DeleteDouyinFile(id1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed || !fileExists(path) || !fileExists(cover) {
+		t.Fatalf("仍被引用的文件不应删除: removed=%v", removed)
+	}
+
+	removed, err = DeleteDouyinFile(id2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !removed || fileExists(path) || fileExists(cover) {
+		t.Fatalf("最后一个引用删除后应删除文件和封面: removed=%v", removed)
+	}
+}
+
+// 删除文件与其他用户复用同一文件同时发生：要么文件保留且有新记录，要么文件删除且没有新记录
+func TestDeleteDouyinFileConcurrentReuse(t *testing.T) {
+	setupTestDB(t)
+	for i := 0; i < 20; i++ {
+		id, path, _ := saveTestDouyinFile(t, t.TempDir(), 1)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := DeleteDouyinFile(id, 1); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := CreateFileRecordForUser(2, "https://v.douyin.com/abc/", "", []string{path}); err != nil {
+				t.Error(err)
+			}
+		}()
+		wg.Wait()
+
+		var refs int
+		if err := DB.QueryRow("SELECT COUNT(*) FROM douyin_files WHERE path = ?", path).Scan(&refs); err != nil {
+			t.Fatal(err)
+		}
+		if exists := fileExists(path); exists != (refs > 0) {
+			t.Fatalf("第 %d 次: 文件存在=%v，引用数=%d", i+1, exists, refs)
+		}
+		if _, err := DB.Exec("DELETE FROM douyin_files"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"backend/models"
+)
+
+const douyinFileColumns = "id, user_id, COALESCE(url, ''), aweme_id, file_name, file_size, file_size_str, modified_time, path, created_at, " + douyinFileMetaColumns
+
+func scanDouyinFile(s rowScanner) (*models.DouyinFile, error) {
+	var file models.DouyinFile
+	err := s.Scan(
+		&file.ID, &file.UserID, &file.URL, &file.AwemeID, &file.FileName, &file.FileSize, &file.FileSizeStr,
+		&file.ModifiedTime, &file.Path, &file.CreatedAt,
+		&file.Author, &file.Description, &file.CreateTime, &file.Duration, &file.Width, &file.Height, &file.CoverPath, &file.CoverURL,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// 文件列表和分组列表的排序列（分组按组内聚合值排序）
+var (
+	douyinFileSortColumns = map[string]string{
+		"date":   "created_at",
+		"size":   "file_size",
+		"author": "author",
+		"name":   "file_name",
+	}
+	douyinGroupSortColumns = map[string]string{
+		"date":   "MAX(created_at)",
+		"size":   "SUM(file_size)",
+		"author": "MIN(author)",
+		"name":   "MIN(file_name)",
+	}
+)
+
+// douyinFileOrder 拼接 ORDER BY 子句（未知排序字段按日期）
+func douyinFileOrder(columns map[string]string, q models.DouyinFileQuery, tieBreaker string) string {
+	column, ok := columns[q.Sort]
+	if !ok {
+		column = columns["date"]
+	}
+	dir := "ASC"
+	if q.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s", column, dir, tieBreaker, dir)
+}
+
+// douyinFilePage 拼接分页子句；PageSize 为 0 时不分页，返回全部
+func douyinFilePage(q models.DouyinFileQuery) (string, []interface{}) {
+	if q.PageSize <= 0 {
+		return "", nil
+	}
+	return " LIMIT ? OFFSET ?", []interface{}{q.PageSize, (q.Page - 1) * q.PageSize}
+}
+
+// douyinFileFilter 用户和关键字（文件名、作者、描述）过滤条件
+func douyinFileFilter(userID int, keyword string) (string, []interface{}) {
+	where := "user_id = ?"
+	args := []interface{}{userID}
+	if keyword = strings.TrimSpace(keyword); keyword != "" {
+		pattern := "%" + escapeLike(keyword) + "%"
+		where += ` AND (file_name LIKE ? ESCAPE '\' OR author LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\')`
+		args = append(args, pattern, pattern, pattern)
+	}
+	return where, args
+}
+
+// QueryUserDouyinFiles 分页查询用户的抖音文件，返回当前页和总数
+func QueryUserDouyinFiles(userID int, q models.DouyinFileQuery) ([]models.DouyinFile, int, error) {
+	where, args := douyinFileFilter(userID, q.Keyword)
+
+	var total int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM douyin_files WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit, limitArgs := douyinFilePage(q)
+	rows, err := DB.Query(
+		"SELECT "+douyinFileColumns+" FROM douyin_files WHERE "+where+douyinFileOrder(douyinFileSortColumns, q, "id")+limit,
+		append(args, limitArgs...)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	files := []models.DouyinFile{}
+	for rows.Next() {
+		file, err := scanDouyinFile(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		files = append(files, *file)
+	}
+	return files, total, rows.Err()
+}
+
+// QueryUserDouyinFileGroups 按来源URL分组分页查询用户的抖音文件，返回当前页的分组（含组内文件）和分组总数
+func QueryUserDouyinFileGroups(userID int, q models.DouyinFileQuery) ([]models.DouyinFileGroup, int, error) {
+	where, args := douyinFileFilter(userID, q.Keyword)
+
+	var total int
+	if err := DB.QueryRow("SELECT COUNT(DISTINCT COALESCE(url, '')) FROM douyin_files WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit, limitArgs := douyinFilePage(q)
+	rows, err := DB.Query(
+		"SELECT COALESCE(url, ''), MAX(aweme_id), COUNT(*), SUM(file_size), MAX(created_at) FROM douyin_files WHERE "+where+
+			" GROUP BY COALESCE(url, '')"+douyinFileOrder(douyinGroupSortColumns, q, "MIN(id)")+limit,
+		append(args, limitArgs...)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	groups := []models.DouyinFileGroup{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var g models.DouyinFileGroup
+		if err := rows.Scan(&g.URL, &g.AwemeID, &g.Count, &g.TotalSize, &g.LatestAt); err != nil {
+			return nil, 0, err
+		}
+		g.TotalSizeStr = formatFileSizeInDB(g.TotalSize)
+		g.Files = []models.DouyinFile{}
+		index[g.URL] = len(groups)
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+	if len(groups) == 0 {
+		return groups, total, nil
+	}
+
+	// 取出当前页各分组内的文件，组内按同样的排序
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(groups)), ", ")
+	for _, g := range groups {
+		args = append(args, g.URL)
+	}
+	fileRows, err := DB.Query(
+		"SELECT "+douyinFileColumns+" FROM douyin_files WHERE "+where+" AND COALESCE(url, '') IN ("+placeholders+")"+douyinFileOrder(douyinFileSortColumns, q, "id"),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer fileRows.Close()
+
+	for fileRows.Next() {
+		file, err := scanDouyinFile(fileRows)
+		if err != nil {
+			return nil, 0, err
+		}
+		i := index[file.URL]
+		groups[i].Files = append(groups[i].Files, *file)
+	}
+	return groups, total, fileRows.Err()
+}
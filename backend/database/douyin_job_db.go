@@ -0,0 +1,358 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// 任务日志最多保留的字节数（超出时保留末尾）
+const douyinJobLogLimit = 64 * 1024
+
+// InitDouyinJobTable 初始化抖音下载任务表
+func InitDouyinJobTable() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS douyin_jobs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			url TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'queued',
+			message TEXT NOT NULL DEFAULT '',
+			log TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			started_at DATETIME,
+			finished_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_douyin_jobs_status ON douyin_jobs(status, id);`,
+		`CREATE INDEX IF NOT EXISTS idx_douyin_jobs_user ON douyin_jobs(user_id, id);`,
+	}
+	for _, stmt := range statements {
+		if _, err := DB.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	// url_key 为规范化后的URL；leader_id 非0 表示该任务等待同一URL的另一个任务下载完成，不单独执行
+	_, _ = DB.Exec("ALTER TABLE douyin_jobs ADD COLUMN url_key TEXT NOT NULL DEFAULT ''")
+	_, _ = DB.Exec("ALTER TABLE douyin_jobs ADD COLUMN leader_id INTEGER NOT NULL DEFAULT 0")
+	_, _ = DB.Exec("ALTER TABLE douyin_jobs ADD COLUMN aweme_id TEXT NOT NULL DEFAULT ''")
+	if _, err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_douyin_jobs_url_key ON douyin_jobs(url_key, status)"); err != nil {
+		return err
+	}
+
+	// attempts 为已执行次数；next_attempt_at 非空表示临时错误后等待自动重试，到时间前不会被领取
+	_, _ = DB.Exec("ALTER TABLE douyin_jobs ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0")
+	_, _ = DB.Exec("ALTER TABLE douyin_jobs ADD COLUMN next_attempt_at DATETIME")
+
+	// 每次执行的退出码、截断后的输出和耗时
+	attemptStatements := []string{
+		`CREATE TABLE IF NOT EXISTS douyin_job_attempts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			job_id INTEGER NOT NULL,
+			attempt INTEGER NOT NULL,
+			downloader TEXT NOT NULL DEFAULT '',
+			exit_code INTEGER NOT NULL DEFAULT -1,
+			success INTEGER NOT NULL DEFAULT 0,
+			transient INTEGER NOT NULL DEFAULT 0,
+			message TEXT NOT NULL DEFAULT '',
+			output TEXT NOT NULL DEFAULT '',
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			started_at DATETIME,
+			FOREIGN KEY (job_id) REFERENCES douyin_jobs(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_douyin_job_attempts_job ON douyin_job_attempts(job_id, attempt);`,
+	}
+	for _, stmt := range attemptStatements {
+		if _, err := DB.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	log.Println("抖音下载任务表初始化成功")
+	return nil
+}
+
+const douyinJobColumns = "id, user_id, url, aweme_id, status, message, log, leader_id, created_at, COALESCE(started_at, ''), COALESCE(finished_at, ''), attempts, COALESCE(next_attempt_at, '')"
+
+func scanDouyinJob(s rowScanner) (*models.DouyinJob, error) {
+	var job models.DouyinJob
+	var createdAt, startedAt, finishedAt, nextAttemptAt string
+	err := s.Scan(&job.ID, &job.UserID, &job.URL, &job.AwemeID, &job.Status, &job.Message, &job.Log, &job.LeaderID,
+		&createdAt, &startedAt, &finishedAt, &job.Attempts, &nextAttemptAt)
+	if err != nil {
+		return nil, err
+	}
+	job.CreatedAt = utils.UTCToShanghai(createdAt)
+	job.StartedAt = utils.UTCToShanghai(startedAt)
+	job.FinishedAt = utils.UTCToShanghai(finishedAt)
+	job.NextAttemptAt = utils.UTCToShanghai(nextAttemptAt)
+	return &job, nil
+}
+
+// CreateDouyinJob 创建排队中的下载任务；urlKey 为去重用的键，leaderID 非0 时任务跟随该任务的下载结果，不单独执行
+func CreateDouyinJob(userID int, url, awemeID, urlKey string, leaderID int) (*models.DouyinJob, error) {
+	message := "等待下载"
+	if leaderID != 0 {
+		message = fmt.Sprintf("等待任务 #%d 下载完成", leaderID)
+	}
+	result, err := DB.Exec(
+		"INSERT INTO douyin_jobs (user_id, url, aweme_id, url_key, leader_id, status, message, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		userID, url, awemeID, urlKey, leaderID, models.DouyinJobQueued, message, utils.NowUTCString(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return GetDouyinJob(int(id), userID)
+}
+
+// GetDouyinJob 获取用户的下载任务
+func GetDouyinJob(id, userID int) (*models.DouyinJob, error) {
+	return scanDouyinJob(DB.QueryRow("SELECT "+douyinJobColumns+" FROM douyin_jobs WHERE id = ? AND user_id = ?", id, userID))
+}
+
+// GetActiveDouyinJob 获取用户对同一URL（按去重键比较）尚未结束（排队或执行中）的任务，没有返回 sql.ErrNoRows
+func GetActiveDouyinJob(userID int, urlKey string) (*models.DouyinJob, error) {
+	return scanDouyinJob(DB.QueryRow(
+		"SELECT "+douyinJobColumns+" FROM douyin_jobs WHERE user_id = ? AND url_key = ? AND status IN (?, ?) ORDER BY id DESC LIMIT 1",
+		userID, urlKey, models.DouyinJobQueued, models.DouyinJobRunning,
+	))
+}
+
+// GetActiveDouyinLeaderJob 获取任意用户对同一URL尚未结束、且会实际执行下载的任务，没有返回 sql.ErrNoRows
+func GetActiveDouyinLeaderJob(urlKey string) (*models.DouyinJob, error) {
+	return scanDouyinJob(DB.QueryRow(
+		"SELECT "+douyinJobColumns+" FROM douyin_jobs WHERE url_key = ? AND leader_id = 0 AND status IN (?, ?) ORDER BY id LIMIT 1",
+		urlKey, models.DouyinJobQueued, models.DouyinJobRunning,
+	))
+}
+
+// GetDouyinJobFollowers 获取等待该任务下载结果的任务
+func GetDouyinJobFollowers(leaderID int) ([]models.DouyinJob, error) {
+	rows, err := DB.Query(
+		"SELECT "+douyinJobColumns+" FROM douyin_jobs WHERE leader_id = ? AND status = ? ORDER BY id",
+		leaderID, models.DouyinJobQueued,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []models.DouyinJob
+	for rows.Next() {
+		job, err := scanDouyinJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// PromoteDouyinJobFollowers 任务被取消时，让最早的跟随任务改为自己下载，其余任务改为跟随它，返回新的执行任务ID（没有跟随任务时返回0）
+func PromoteDouyinJobFollowers(leaderID int) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var newLeaderID int
+	err = tx.QueryRow(
+		"SELECT id FROM douyin_jobs WHERE leader_id = ? AND status = ? ORDER BY id LIMIT 1",
+		leaderID, models.DouyinJobQueued,
+	).Scan(&newLeaderID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE douyin_jobs SET leader_id = 0, message = '等待下载' WHERE id = ?", newLeaderID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(
+		"UPDATE douyin_jobs SET leader_id = ?, message = ? WHERE leader_id = ? AND status = ?",
+		newLeaderID, fmt.Sprintf("等待任务 #%d 下载完成", newLeaderID), leaderID, models.DouyinJobQueued,
+	); err != nil {
+		return 0, err
+	}
+	return newLeaderID, tx.Commit()
+}
+
+// GetUserDouyinJobs 获取用户最近的下载任务（不含日志）
+func GetUserDouyinJobs(userID, limit int) ([]models.DouyinJob, error) {
+	rows, err := DB.Query(
+		"SELECT "+douyinJobColumns+" FROM douyin_jobs WHERE user_id = ? ORDER BY id DESC LIMIT ?",
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []models.DouyinJob{}
+	for rows.Next() {
+		job, err := scanDouyinJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		job.Log = ""
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimNextDouyinJob 原子地取出最早排队（且已到重试时间）的任务，标记为执行中并累加执行次数，没有任务时返回 sql.ErrNoRows
+func ClaimNextDouyinJob() (*models.DouyinJob, error) {
+	now := utils.NowUTCString()
+	return scanDouyinJob(DB.QueryRow(
+		`UPDATE douyin_jobs SET status = ?, message = '下载中', started_at = ?, attempts = attempts + 1, next_attempt_at = NULL
+		WHERE id = (SELECT id FROM douyin_jobs WHERE status = ? AND leader_id = 0 AND (next_attempt_at IS NULL OR next_attempt_at <= ?) ORDER BY id LIMIT 1)
+		RETURNING `+douyinJobColumns,
+		models.DouyinJobRunning, now, models.DouyinJobQueued, now,
+	))
+}
+
+// RetryDouyinJobLater 把执行失败的任务重新排队，在 delay 之后才会被再次领取
+func RetryDouyinJobLater(id int, delay time.Duration, message string) error {
+	_, err := DB.Exec(
+		"UPDATE douyin_jobs SET status = ?, message = ?, started_at = NULL, next_attempt_at = ? WHERE id = ?",
+		models.DouyinJobQueued, message, utils.NowUTC().Add(delay).Format("2006-01-02 15:04:05"), id,
+	)
+	return err
+}
+
+// RetryDouyinJobNow 取消等待中任务的重试延迟，使其可以立即被领取
+func RetryDouyinJobNow(id int) error {
+	_, err := DB.Exec(
+		"UPDATE douyin_jobs SET next_attempt_at = NULL, message = '等待下载' WHERE id = ? AND status = ? AND next_attempt_at IS NOT NULL",
+		id, models.DouyinJobQueued,
+	)
+	return err
+}
+
+// SaveDouyinJobAttempt 记录任务的一次执行
+func SaveDouyinJobAttempt(a *models.DouyinJobAttempt) error {
+	result, err := DB.Exec(
+		`INSERT INTO douyin_job_attempts (job_id, attempt, downloader, exit_code, success, transient, message, output, duration_ms, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.JobID, a.Attempt, a.Downloader, a.ExitCode, a.Success, a.Transient, a.Message, a.Output, a.DurationMs, a.StartedAt,
+	)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	a.ID = int(id)
+	return nil
+}
+
+// GetDouyinJobAttempts 获取任务的执行记录（按执行顺序）
+func GetDouyinJobAttempts(jobID int) ([]models.DouyinJobAttempt, error) {
+	rows, err := DB.Query(
+		`SELECT id, job_id, attempt, downloader, exit_code, success, transient, message, output, duration_ms, COALESCE(started_at, '')
+		FROM douyin_job_attempts WHERE job_id = ? ORDER BY id`,
+		jobID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.DouyinJobAttempt{}
+	for rows.Next() {
+		var a models.DouyinJobAttempt
+		var startedAt string
+		if err := rows.Scan(&a.ID, &a.JobID, &a.Attempt, &a.Downloader, &a.ExitCode, &a.Success, &a.Transient,
+			&a.Message, &a.Output, &a.DurationMs, &startedAt); err != nil {
+			return nil, err
+		}
+		a.StartedAt = utils.UTCToShanghai(startedAt)
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// CancelQueuedDouyinJob 取消用户尚未开始执行的任务，任务不存在或已开始执行时返回 false
+func CancelQueuedDouyinJob(id, userID int) (bool, error) {
+	result, err := DB.Exec(
+		"UPDATE douyin_jobs SET status = ?, message = '已取消', finished_at = ? WHERE id = ? AND user_id = ? AND status = ?",
+		models.DouyinJobCancelled, utils.NowUTCString(), id, userID, models.DouyinJobQueued,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// FinishDouyinJob 记录任务结果（succeeded / failed / cancelled）
+func FinishDouyinJob(id int, status, message string) error {
+	_, err := DB.Exec(
+		"UPDATE douyin_jobs SET status = ?, message = ?, finished_at = ? WHERE id = ?",
+		status, message, utils.NowUTCString(), id,
+	)
+	return err
+}
+
+// AppendDouyinJobLog 追加任务日志，超出上限时只保留末尾
+func AppendDouyinJobLog(id int, text string) error {
+	line := utils.NowString() + " " + text
+	if len(line) > 0 && line[len(line)-1] != '\n' {
+		line += "\n"
+	}
+	_, err := DB.Exec(
+		"UPDATE douyin_jobs SET log = substr(log || ?, -?) WHERE id = ?",
+		line, douyinJobLogLimit, id,
+	)
+	return err
+}
+
+// GetRunningDouyinJobIDs 获取执行中的任务ID
+func GetRunningDouyinJobIDs() (map[int]bool, error) {
+	rows, err := DB.Query("SELECT id FROM douyin_jobs WHERE status = ?", models.DouyinJobRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// RequeueInterruptedDouyinJobs 将服务停止时仍在执行的任务重新排队，返回数量；
+// 跟随的任务已结束但未被处理的跟随任务改为自己下载
+func RequeueInterruptedDouyinJobs() (int, error) {
+	_, err := DB.Exec(
+		`UPDATE douyin_jobs SET leader_id = 0, message = '等待下载'
+		WHERE status = ? AND leader_id != 0
+		AND leader_id NOT IN (SELECT id FROM douyin_jobs WHERE status IN (?, ?))`,
+		models.DouyinJobQueued, models.DouyinJobQueued, models.DouyinJobRunning,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := DB.Exec(
+		"UPDATE douyin_jobs SET status = ?, message = '服务重启，重新排队', started_at = NULL WHERE status = ?",
+		models.DouyinJobQueued, models.DouyinJobRunning,
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
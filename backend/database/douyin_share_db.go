@@ -0,0 +1,136 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// InitDouyinShareTable 初始化抖音视频分享表
+func InitDouyinShareTable() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS douyin_shares (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			file_id INTEGER NOT NULL,
+			token TEXT NOT NULL UNIQUE,
+			view_count INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME,
+			revoked_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (file_id) REFERENCES douyin_files(id) ON DELETE CASCADE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_douyin_shares_user ON douyin_shares(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_douyin_shares_file ON douyin_shares(file_id);`,
+	}
+	for _, stmt := range statements {
+		if _, err := DB.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	log.Println("抖音视频分享表初始化成功")
+	return nil
+}
+
+// douyinShareSelect 查询分享及对应的视频信息（与 scanDouyinShare 对应）
+const douyinShareSelect = `
+	SELECT s.id, s.user_id, s.file_id, s.token, f.file_name, f.author, f.description, s.view_count,
+		s.created_at, COALESCE(s.expires_at, ''), COALESCE(s.revoked_at, '')
+	FROM douyin_shares s
+	JOIN douyin_files f ON f.id = s.file_id`
+
+func scanDouyinShare(s rowScanner) (*models.DouyinShare, error) {
+	var share models.DouyinShare
+	var createdAt, expiresAt, revokedAt string
+	err := s.Scan(&share.ID, &share.UserID, &share.FileID, &share.Token, &share.FileName, &share.Author, &share.Description,
+		&share.ViewCount, &createdAt, &expiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	share.CreatedAt = utils.UTCToShanghai(createdAt)
+	share.ExpiresAt = utils.UTCToShanghai(expiresAt)
+	share.RevokedAt = utils.UTCToShanghai(revokedAt)
+	return &share, nil
+}
+
+// CreateDouyinShare 为用户自己的视频创建公开分享；expiresIn 为0表示不过期
+func CreateDouyinShare(userID, fileID int, expiresIn time.Duration) (*models.DouyinShare, error) {
+	if _, err := GetDouyinFileByID(fileID, userID); err != nil {
+		return nil, err
+	}
+
+	token, err := GenerateShareToken()
+	if err != nil {
+		return nil, err
+	}
+	var expiresAt interface{}
+	if expiresIn > 0 {
+		expiresAt = utils.NowUTC().Add(expiresIn).Format("2006-01-02 15:04:05")
+	}
+
+	result, err := DB.Exec(
+		"INSERT INTO douyin_shares (user_id, file_id, token, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		userID, fileID, token, utils.NowUTCString(), expiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return GetDouyinShareByID(int(id), userID)
+}
+
+// GetDouyinShareByID 获取用户的某个分享
+func GetDouyinShareByID(id, userID int) (*models.DouyinShare, error) {
+	return scanDouyinShare(DB.QueryRow(douyinShareSelect+" WHERE s.id = ? AND s.user_id = ?", id, userID))
+}
+
+// GetDouyinShareByToken 通过公开 token 获取分享（不检查有效期）
+func GetDouyinShareByToken(token string) (*models.DouyinShare, error) {
+	return scanDouyinShare(DB.QueryRow(douyinShareSelect+" WHERE s.token = ?", token))
+}
+
+// GetUserDouyinShares 获取用户创建的所有分享（含已撤销和已过期）
+func GetUserDouyinShares(userID int) ([]models.DouyinShare, error) {
+	rows, err := DB.Query(douyinShareSelect+" WHERE s.user_id = ? ORDER BY s.id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []models.DouyinShare{}
+	for rows.Next() {
+		share, err := scanDouyinShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *share)
+	}
+	return shares, rows.Err()
+}
+
+// RevokeDouyinShare 撤销分享（保留记录）
+func RevokeDouyinShare(id, userID int) error {
+	result, err := DB.Exec(
+		"UPDATE douyin_shares SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		utils.NowUTCString(), id, userID,
+	)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// IncrementDouyinShareViewCount 增加分享页面访问次数
+func IncrementDouyinShareViewCount(id int) error {
+	_, err := DB.Exec("UPDATE douyin_shares SET view_count = view_count + 1 WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"log"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// InitDouyinSubscriptionTable 初始化创作者订阅表和订阅作品记录表
+func InitDouyinSubscriptionTable() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS douyin_subscriptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			profile_url TEXT NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'active',
+			last_checked_at DATETIME,
+			last_error TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			UNIQUE(user_id, profile_url)
+		);`,
+		`CREATE TABLE IF NOT EXISTS douyin_subscription_posts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			subscription_id INTEGER NOT NULL,
+			post_key TEXT NOT NULL,
+			aweme_id TEXT NOT NULL DEFAULT '',
+			url TEXT NOT NULL,
+			title TEXT NOT NULL DEFAULT '',
+			job_id INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL,
+			message TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (subscription_id) REFERENCES douyin_subscriptions(id),
+			UNIQUE(subscription_id, post_key)
+		);`,
+	}
+	for _, stmt := range statements {
+		if _, err := DB.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	log.Println("创作者订阅表初始化成功")
+	return nil
+}
+
+const douyinSubscriptionColumns = `id, user_id, profile_url, name, status, COALESCE(last_checked_at, ''), last_error, created_at,
+	(SELECT COUNT(*) FROM douyin_subscription_posts p WHERE p.subscription_id = douyin_subscriptions.id AND p.status != '` + models.DouyinSubscriptionPostBaseline + `')`
+
+func scanDouyinSubscription(s rowScanner) (*models.DouyinSubscription, error) {
+	var sub models.DouyinSubscription
+	var lastCheckedAt, createdAt string
+	err := s.Scan(&sub.ID, &sub.UserID, &sub.ProfileURL, &sub.Name, &sub.Status, &lastCheckedAt, &sub.LastError, &createdAt, &sub.PostCount)
+	if err != nil {
+		return nil, err
+	}
+	sub.LastCheckedAt = utils.UTCToShanghai(lastCheckedAt)
+	sub.CreatedAt = utils.UTCToShanghai(createdAt)
+	return &sub, nil
+}
+
+func queryDouyinSubscriptions(query string, args ...interface{}) ([]models.DouyinSubscription, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []models.DouyinSubscription{}
+	for rows.Next() {
+		sub, err := scanDouyinSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+// CreateDouyinSubscription 创建订阅；同一用户已订阅该主页时返回已有的订阅
+func CreateDouyinSubscription(userID int, profileURL, name string) (*models.DouyinSubscription, error) {
+	_, err := DB.Exec(
+		"INSERT OR IGNORE INTO douyin_subscriptions (user_id, profile_url, name, status, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, profileURL, name, models.DouyinSubscriptionActive, utils.NowUTCString(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanDouyinSubscription(DB.QueryRow(
+		"SELECT "+douyinSubscriptionColumns+" FROM douyin_subscriptions WHERE user_id = ? AND profile_url = ?",
+		userID, profileURL,
+	))
+}
+
+// GetDouyinSubscription 获取用户的订阅，不存在时返回 sql.ErrNoRows
+func GetDouyinSubscription(id, userID int) (*models.DouyinSubscription, error) {
+	return scanDouyinSubscription(DB.QueryRow(
+		"SELECT "+douyinSubscriptionColumns+" FROM douyin_subscriptions WHERE id = ? AND user_id = ?",
+		id, userID,
+	))
+}
+
+// GetUserDouyinSubscriptions 获取用户的全部订阅
+func GetUserDouyinSubscriptions(userID int) ([]models.DouyinSubscription, error) {
+	return queryDouyinSubscriptions(
+		"SELECT "+douyinSubscriptionColumns+" FROM douyin_subscriptions WHERE user_id = ? ORDER BY id DESC",
+		userID,
+	)
+}
+
+// GetDueDouyinSubscriptions 获取未暂停且上次检查早于 before（UTC）或从未检查过的订阅
+func GetDueDouyinSubscriptions(before string) ([]models.DouyinSubscription, error) {
+	return queryDouyinSubscriptions(
+		"SELECT "+douyinSubscriptionColumns+" FROM douyin_subscriptions WHERE status = ? AND (last_checked_at IS NULL OR last_checked_at <= ?) ORDER BY id",
+		models.DouyinSubscriptionActive, before,
+	)
+}
+
+// SetDouyinSubscriptionStatus 暂停或恢复订阅，订阅不存在时返回 false
+func SetDouyinSubscriptionStatus(id, userID int, status string) (bool, error) {
+	result, err := DB.Exec("UPDATE douyin_subscriptions SET status = ? WHERE id = ? AND user_id = ?", status, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteDouyinSubscription 删除订阅及其作品记录（已下载的文件保留），订阅不存在时返回 false
+func DeleteDouyinSubscription(id, userID int) (bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM douyin_subscriptions WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec("DELETE FROM douyin_subscription_posts WHERE subscription_id = ?", id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// MarkDouyinSubscriptionChecked 记录检查时间和错误（成功时 errMsg 为空）
+func MarkDouyinSubscriptionChecked(id int, errMsg string) error {
+	_, err := DB.Exec(
+		"UPDATE douyin_subscriptions SET last_checked_at = ?, last_error = ? WHERE id = ?",
+		utils.NowUTCString(), errMsg, id,
+	)
+	return err
+}
+
+// DouyinSubscriptionPostExists 订阅是否已记录过该作品
+func DouyinSubscriptionPostExists(subscriptionID int, postKey string) (bool, error) {
+	var count int
+	err := DB.QueryRow(
+		"SELECT COUNT(*) FROM douyin_subscription_posts WHERE subscription_id = ? AND post_key = ?",
+		subscriptionID, postKey,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// HasDouyinSubscriptionPosts 订阅是否已记录过任何作品（没有时下次检查为首次检查，只记录 baseline）
+func HasDouyinSubscriptionPosts(subscriptionID int) (bool, error) {
+	var count int
+	err := DB.QueryRow(
+		"SELECT COUNT(*) FROM douyin_subscription_posts WHERE subscription_id = ?",
+		subscriptionID,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// AddDouyinSubscriptionPost 记录订阅发现的作品（已记录时忽略）
+func AddDouyinSubscriptionPost(subscriptionID int, postKey string, post models.DouyinSubscriptionPost) error {
+	_, err := DB.Exec(
+		`INSERT OR IGNORE INTO douyin_subscription_posts (subscription_id, post_key, aweme_id, url, title, job_id, status, message, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		subscriptionID, postKey, post.AwemeID, post.URL, post.Title, post.JobID, post.Status, post.Message, utils.NowUTCString(),
+	)
+	return err
+}
+
+// GetDouyinSubscriptionPosts 获取订阅最近发现的作品，状态取自下载任务的当前状态
+func GetDouyinSubscriptionPosts(subscriptionID, limit int) ([]models.DouyinSubscriptionPost, error) {
+	rows, err := DB.Query(
+		`SELECT p.id, p.aweme_id, p.url, p.title, p.job_id, COALESCE(j.status, p.status), COALESCE(j.message, p.message), p.created_at
+		FROM douyin_subscription_posts p
+		LEFT JOIN douyin_jobs j ON j.id = p.job_id
+		WHERE p.subscription_id = ?
+		ORDER BY p.id DESC LIMIT ?`,
+		subscriptionID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []models.DouyinSubscriptionPost{}
+	for rows.Next() {
+		var post models.DouyinSubscriptionPost
+		var createdAt string
+		if err := rows.Scan(&post.ID, &post.AwemeID, &post.URL, &post.Title, &post.JobID, &post.Status, &post.Message, &createdAt); err != nil {
+			return nil, err
+		}
+		post.CreatedAt = utils.UTCToShanghai(createdAt)
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
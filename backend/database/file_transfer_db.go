@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"log"
-	"os"
 
 	"backend/models"
 	"backend/utils"
@@ -91,19 +90,38 @@ func GetFileByShareToken(token string) (*models.FileTransfer, error) {
 	return &file, nil
 }
 
-// SaveFileTransfer 保存文件传输记录
+// SaveFileTransfer 保存文件传输记录（成功后回填 file.ID）
 func SaveFileTransfer(file *models.FileTransfer) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveFileTransferTx(tx, file); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// saveFileTransferTx 在事务中插入文件传输记录（成功后回填 file.ID）
+func saveFileTransferTx(tx *sql.Tx, file *models.FileTransfer) error {
 	// 存储 UTC 时间（如果为空，使用当前 UTC 时间）
 	createdAt := file.CreatedAt
 	if createdAt == "" {
 		createdAt = utils.NowUTCString()
 	}
 
-	_, err := DB.Exec(
+	result, err := tx.Exec(
 		"INSERT INTO file_transfers (user_id, file_name, file_path, file_size, file_type, created_at) VALUES (?, ?, ?, ?, ?, ?)",
 		file.UserID, file.FileName, file.FilePath, file.FileSize, file.FileType, createdAt,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	file.ID = int(id)
+	return nil
 }
 
 // GetUserFileTransfers 获取用户的文件列表（分页）
@@ -151,37 +169,23 @@ func GetUserFileTransfers(userID int, page, pageSize int) ([]models.FileTransfer
 	return files, total, nil
 }
 
-// DeleteFileTransfer 删除文件传输记录
+// DeleteFileTransfer 删除文件传输记录（移入回收站，物理文件保留到过期清理）
 func DeleteFileTransfer(id, userID int) error {
-	// 先获取文件路径
-	var filePath string
-	err := DB.QueryRow("SELECT file_path FROM file_transfers WHERE id = ? AND user_id = ?", id, userID).Scan(&filePath)
+	tx, err := DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// 先删除物理文件（如果存在）
-	if _, err := os.Stat(filePath); err == nil {
-		// 文件存在，尝试删除
-		if err := os.Remove(filePath); err != nil {
-			log.Printf("删除物理文件失败: %v", err)
-			// 文件删除失败，不删除数据库记录
-			return err
-		}
-		log.Printf("物理文件删除成功: %s", filePath)
-	} else {
-		log.Printf("物理文件不存在: %s", filePath)
-		// 文件不存在，继续删除数据库记录
+	if err := moveToTrashTx(tx, userID, models.TrashTypeFile, id, nil); err != nil {
+		log.Printf("移入回收站失败: %v", err)
+		return err
 	}
-
-	// 删除数据库记录
-	_, err = DB.Exec("DELETE FROM file_transfers WHERE id = ? AND user_id = ?", id, userID)
-	if err != nil {
-		log.Printf("删除数据库记录失败: %v", err)
+	if err := tx.Commit(); err != nil {
 		return err
 	}
 
-	log.Printf("数据库记录删除成功: id=%d, user_id=%d", id, userID)
+	log.Printf("文件记录已移入回收站: id=%d, user_id=%d", id, userID)
 	return nil
 }
 
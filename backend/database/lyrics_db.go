@@ -3,7 +3,6 @@ package database
 import (
 	"database/sql"
 	"log"
-	"os"
 	"time"
 	
 	"backend/models"
@@ -274,15 +273,8 @@ func UnbindLyricsFromMusic(musicID int) error {
 	return err
 }
 
-// DeleteLyrics 删除歌词
+// DeleteLyrics 删除歌词（移入回收站，物理文件保留到过期清理）
 func DeleteLyrics(id, userID int) error {
-	// 先获取文件路径
-	var filePath string
-	err := DB.QueryRow("SELECT file_path FROM lyrics WHERE id = ? AND user_id = ?", id, userID).Scan(&filePath)
-	if err != nil {
-		return err
-	}
-
 	// 开始事务
 	tx, err := DB.Begin()
 	if err != nil {
@@ -290,17 +282,22 @@ func DeleteLyrics(id, userID int) error {
 	}
 	defer tx.Rollback()
 
-	// 先删除关联表中的绑定记录（虽然外键有CASCADE，但显式删除更清晰）
-	_, err = tx.Exec("DELETE FROM music_lyrics_binding WHERE lyrics_id = ?", id)
+	// 保存绑定快照（恢复时重新绑定）
+	bindings, err := snapshotRows(tx, "SELECT * FROM music_lyrics_binding WHERE lyrics_id = ?", id)
 	if err != nil {
-		log.Printf("删除歌词绑定记录失败: %v", err)
 		return err
 	}
 
-	// 删除歌词记录
-	_, err = tx.Exec("DELETE FROM lyrics WHERE id = ? AND user_id = ?", id, userID)
+	// 歌词记录移入回收站
+	if err := moveToTrashTx(tx, userID, models.TrashTypeLyrics, id, bindings); err != nil {
+		log.Printf("歌词移入回收站失败: %v", err)
+		return err
+	}
+
+	// 删除关联表中的绑定记录
+	_, err = tx.Exec("DELETE FROM music_lyrics_binding WHERE lyrics_id = ?", id)
 	if err != nil {
-		log.Printf("删除歌词记录失败: %v", err)
+		log.Printf("删除歌词绑定记录失败: %v", err)
 		return err
 	}
 
@@ -309,17 +306,7 @@ func DeleteLyrics(id, userID int) error {
 		return err
 	}
 
-	// 删除物理文件（在事务外执行，避免影响数据库操作）
-	if _, err := os.Stat(filePath); err == nil {
-		if err := os.Remove(filePath); err != nil {
-			log.Printf("删除歌词文件失败: %v", err)
-			// 文件删除失败不影响数据库操作成功
-		} else {
-			log.Printf("歌词文件删除成功: %s", filePath)
-		}
-	}
-
-	log.Printf("歌词记录删除成功: id=%d, user_id=%d", id, userID)
+	log.Printf("歌词记录已移入回收站: id=%d, user_id=%d", id, userID)
 	return nil
 }
 
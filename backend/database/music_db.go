@@ -2,7 +2,6 @@ package database
 
 import (
 	"log"
-	"os"
 	"time"
 	
 	"backend/models"
@@ -170,61 +169,35 @@ func GetMusicByID(id, userID int) (*models.Music, error) {
 	return &music, nil
 }
 
-// DeleteMusic 删除音乐记录（同时删除歌词绑定记录）
+// DeleteMusic 删除音乐记录（移入回收站，同时删除歌词绑定记录；物理文件保留到过期清理）
 func DeleteMusic(id, userID int) error {
-	// 先获取文件路径
-	var filePath string
-	var coverPath string
-	err := DB.QueryRow("SELECT file_path, cover_path FROM music WHERE id = ? AND user_id = ?", id, userID).Scan(&filePath, &coverPath)
-	if err != nil {
-		return err
-	}
-	
 	// 开始事务
 	tx, err := DB.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
-	
-	// 1. 删除歌词绑定记录
-	_, err = tx.Exec("DELETE FROM music_lyrics_binding WHERE music_id = ?", id)
+
+	// 1. 保存歌词绑定快照（恢复时重新绑定），然后删除绑定记录
+	bindings, err := snapshotRows(tx, "SELECT * FROM music_lyrics_binding WHERE music_id = ?", id)
 	if err != nil {
-		log.Printf("删除歌词绑定记录失败: %v", err)
 		return err
 	}
-	log.Printf("歌词绑定记录删除成功: music_id=%d", id)
-	
-	// 2. 删除数据库记录
-	_, err = tx.Exec("DELETE FROM music WHERE id = ? AND user_id = ?", id, userID)
+	if err := moveToTrashTx(tx, userID, models.TrashTypeMusic, id, bindings); err != nil {
+		log.Printf("音乐移入回收站失败: %v", err)
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM music_lyrics_binding WHERE music_id = ?", id)
 	if err != nil {
-		log.Printf("删除音乐记录失败: %v", err)
+		log.Printf("删除歌词绑定记录失败: %v", err)
 		return err
 	}
-	
+
 	// 提交事务
 	if err = tx.Commit(); err != nil {
 		return err
 	}
-	
-	// 3. 删除物理文件（在事务外执行，即使失败也不影响数据库一致性）
-	if _, err := os.Stat(filePath); err == nil {
-		if err := os.Remove(filePath); err != nil {
-			log.Printf("删除音乐文件失败: %v", err)
-			// 不返回错误，允许继续
-		} else {
-			log.Printf("音乐文件删除成功: %s", filePath)
-		}
-	}
-	
-	// 4. 删除封面图片（如果有）
-	if coverPath != "" {
-		if _, err := os.Stat(coverPath); err == nil {
-			os.Remove(coverPath)
-		}
-	}
-	
-	log.Printf("音乐记录删除成功: id=%d, user_id=%d", id, userID)
+
+	log.Printf("音乐记录已移入回收站: id=%d, user_id=%d", id, userID)
 	return nil
 }
-
@@ -0,0 +1,17 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// setupTestDB 在临时目录中初始化一个全新的数据库，测试结束后关闭
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	if err := InitDB(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("初始化测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		DB.Close()
+	})
+}
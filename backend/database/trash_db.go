@@ -0,0 +1,405 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// ErrTrashRestoreConflict 恢复时原记录的唯一键已被占用
+var ErrTrashRestoreConflict = errors.New("记录已存在，无法恢复")
+
+// trashTables 回收站条目类型对应的数据表
+var trashTables = map[string]string{
+	models.TrashTypeActivity: "health_activities",
+	models.TrashTypeFile:     "file_transfers",
+	models.TrashTypeMusic:    "music",
+	models.TrashTypeLyrics:   "lyrics",
+}
+
+// trashPayload 回收站快照内容
+type trashPayload struct {
+	Row      map[string]interface{}   `json:"row"`
+	Bindings []map[string]interface{} `json:"bindings,omitempty"` // 歌词绑定记录
+}
+
+// InitTrashTable 初始化回收站表
+func InitTrashTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS trash_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		item_type TEXT NOT NULL,
+		item_id INTEGER NOT NULL,
+		title TEXT,
+		payload TEXT NOT NULL,
+		file_paths TEXT,
+		deleted_at TEXT NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return err
+	}
+	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_trash_user ON trash_items(user_id, deleted_at);`); err != nil {
+		return err
+	}
+
+	log.Println("回收站表初始化成功")
+	return nil
+}
+
+// snapshotRows 读取查询结果为 列名→值 的映射，时间统一格式化为 UTC 字符串
+func snapshotRows(tx *sql.Tx, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			switch v := values[i].(type) {
+			case time.Time:
+				row[col] = v.UTC().Format("2006-01-02 15:04:05")
+			case []byte:
+				row[col] = string(v)
+			default:
+				row[col] = v
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// trashTitle 回收站列表中显示的标题
+func trashTitle(itemType string, row map[string]interface{}) string {
+	str := func(key string) string {
+		if v, ok := row[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	switch itemType {
+	case models.TrashTypeActivity:
+		title := str("record_date") + " " + str("record_time")
+		if remark := str("remark"); remark != "" {
+			title += " " + remark
+		}
+		return title
+	case models.TrashTypeFile:
+		return str("file_name")
+	default:
+		return str("title")
+	}
+}
+
+// trashFilePaths 记录关联的物理文件（过期清理时删除）
+func trashFilePaths(itemType string, row map[string]interface{}) []string {
+	var keys []string
+	switch itemType {
+	case models.TrashTypeFile, models.TrashTypeLyrics:
+		keys = []string{"file_path"}
+	case models.TrashTypeMusic:
+		keys = []string{"file_path", "cover_path"}
+	}
+	var paths []string
+	for _, key := range keys {
+		if v, ok := row[key].(string); ok && v != "" {
+			paths = append(paths, v)
+		}
+	}
+	return paths
+}
+
+// moveToTrashTx 在事务中把记录快照写入回收站，然后删除原记录
+func moveToTrashTx(tx *sql.Tx, userID int, itemType string, id int, bindings []map[string]interface{}) error {
+	table := trashTables[itemType]
+	rows, err := snapshotRows(tx, "SELECT * FROM "+table+" WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return sql.ErrNoRows
+	}
+	row := rows[0]
+
+	payload, err := json.Marshal(trashPayload{Row: row, Bindings: bindings})
+	if err != nil {
+		return err
+	}
+	filePaths, err := json.Marshal(trashFilePaths(itemType, row))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO trash_items (user_id, item_type, item_id, title, payload, file_paths, deleted_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		userID, itemType, id, trashTitle(itemType, row), string(payload), string(filePaths), utils.NowUTCString(),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM "+table+" WHERE id = ? AND user_id = ?", id, userID)
+	return err
+}
+
+// GetUserTrashItems 获取用户回收站列表，itemType 为空表示全部
+func GetUserTrashItems(userID int, itemType string, retention time.Duration) ([]models.TrashItem, error) {
+	query := "SELECT id, user_id, item_type, item_id, COALESCE(title, ''), deleted_at FROM trash_items WHERE user_id = ?"
+	args := []interface{}{userID}
+	if itemType != "" {
+		query += " AND item_type = ?"
+		args = append(args, itemType)
+	}
+	query += " ORDER BY deleted_at DESC, id DESC"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.TrashItem{}
+	for rows.Next() {
+		var item models.TrashItem
+		var deletedAt string
+		if err := rows.Scan(&item.ID, &item.UserID, &item.ItemType, &item.ItemID, &item.Title, &deletedAt); err != nil {
+			continue
+		}
+		item.DeletedAt = utils.UTCToShanghai(deletedAt)
+		if t, err := time.Parse("2006-01-02 15:04:05", deletedAt); err == nil {
+			item.ExpiresAt = t.Add(retention).In(utils.GetShanghaiTZ()).Format("2006-01-02 15:04:05")
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// RestoreTrashItem 从回收站恢复记录（保持原ID），返回恢复的条目
+func RestoreTrashItem(userID, trashID int) (*models.TrashItem, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var item models.TrashItem
+	var payloadStr string
+	err = tx.QueryRow(
+		"SELECT id, user_id, item_type, item_id, COALESCE(title, ''), payload FROM trash_items WHERE id = ? AND user_id = ?",
+		trashID, userID,
+	).Scan(&item.ID, &item.UserID, &item.ItemType, &item.ItemID, &item.Title, &payloadStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload trashPayload
+	decoder := json.NewDecoder(bytes.NewReader([]byte(payloadStr)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	table, ok := trashTables[item.ItemType]
+	if !ok {
+		return nil, fmt.Errorf("未知的回收站类型: %s", item.ItemType)
+	}
+	if err := insertSnapshotRow(tx, table, payload.Row); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, ErrTrashRestoreConflict
+		}
+		return nil, err
+	}
+
+	switch item.ItemType {
+	case models.TrashTypeActivity:
+		// 恢复视为一次新的修改，离线客户端会重新收到该记录
+		seq, err := nextActivitySeq(tx)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(
+			"UPDATE health_activities SET sync_seq = ?, updated_at = ?, sync_key = '' WHERE id = ?",
+			seq, utils.NowUTC().Format(syncTimeLayout), item.ItemID,
+		); err != nil {
+			return nil, err
+		}
+		if uuid, ok := payload.Row["uuid"].(string); ok && uuid != "" {
+			if _, err := tx.Exec("DELETE FROM activity_tombstones WHERE user_id = ? AND uuid = ?", userID, uuid); err != nil {
+				return nil, err
+			}
+		}
+	case models.TrashTypeMusic, models.TrashTypeLyrics:
+		// 恢复歌词绑定（仅当歌曲和歌词都还存在，且歌曲尚未绑定其他歌词）
+		for _, b := range payload.Bindings {
+			musicID, lyricsID := jsonValue(b["music_id"]), jsonValue(b["lyrics_id"])
+			if _, err := tx.Exec(
+				`INSERT OR IGNORE INTO music_lyrics_binding (music_id, lyrics_id, created_at)
+				SELECT ?, ?, ? WHERE EXISTS (SELECT 1 FROM music WHERE id = ?) AND EXISTS (SELECT 1 FROM lyrics WHERE id = ?)`,
+				musicID, lyricsID, jsonValue(b["created_at"]), musicID, lyricsID,
+			); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM trash_items WHERE id = ?", trashID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("回收站恢复成功: trash_id=%d, type=%s, item_id=%d, user_id=%d", trashID, item.ItemType, item.ItemID, userID)
+	return &item, nil
+}
+
+// insertSnapshotRow 按快照重新插入记录
+func insertSnapshotRow(tx *sql.Tx, table string, row map[string]interface{}) error {
+	columns := make([]string, 0, len(row))
+	for col := range row {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	placeholders := make([]string, len(columns))
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		placeholders[i] = "?"
+		values[i] = jsonValue(row[col])
+	}
+
+	_, err := tx.Exec(
+		"INSERT INTO "+table+" ("+strings.Join(columns, ", ")+") VALUES ("+strings.Join(placeholders, ", ")+")",
+		values...,
+	)
+	return err
+}
+
+// jsonValue 将 JSON 解码出的数字还原为整数/浮点数
+func jsonValue(v interface{}) interface{} {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
+
+// EmptyUserTrash 永久删除用户回收站条目（trashID 为0时清空全部），返回删除数量
+func EmptyUserTrash(userID, trashID int) (int, error) {
+	if trashID > 0 {
+		return purgeTrashItems("user_id = ? AND id = ?", userID, trashID)
+	}
+	return purgeTrashItems("user_id = ?", userID)
+}
+
+// PurgeExpiredTrash 永久删除所有超过保留期的回收站条目，返回删除数量
+func PurgeExpiredTrash(retention time.Duration) (int, error) {
+	cutoff := utils.NowUTC().Add(-retention).Format("2006-01-02 15:04:05")
+	return purgeTrashItems("deleted_at < ?", cutoff)
+}
+
+// purgeTrashItems 删除回收站条目，并删除不再被任何记录引用的物理文件
+func purgeTrashItems(where string, args ...interface{}) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id, COALESCE(file_paths, '[]') FROM trash_items WHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	var paths []string
+	for rows.Next() {
+		var id int
+		var filePaths string
+		if err := rows.Scan(&id, &filePaths); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		var p []string
+		_ = json.Unmarshal([]byte(filePaths), &p)
+		ids = append(ids, id)
+		paths = append(paths, p...)
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.Exec("DELETE FROM trash_items WHERE "+where, args...); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// 删除物理文件（在事务外执行，失败不影响数据库一致性）
+	for _, path := range paths {
+		if isFileReferenced(path) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除回收站文件失败: %s, %v", path, err)
+		} else if err == nil {
+			log.Printf("回收站文件删除成功: %s", path)
+		}
+	}
+	return len(ids), nil
+}
+
+// isFileReferenced 检查物理文件是否仍被记录或其他回收站条目引用
+func isFileReferenced(path string) bool {
+	quoted, _ := json.Marshal(path)
+	var count int
+	err := DB.QueryRow(
+		`SELECT
+			(SELECT COUNT(*) FROM file_transfers WHERE file_path = ?) +
+			(SELECT COUNT(*) FROM music WHERE file_path = ? OR cover_path = ?) +
+			(SELECT COUNT(*) FROM lyrics WHERE file_path = ?) +
+			(SELECT COUNT(*) FROM trash_items WHERE instr(file_paths, ?) > 0)`,
+		path, path, path, path, string(quoted),
+	).Scan(&count)
+	if err != nil {
+		// 查询失败时保守处理，保留文件
+		return true
+	}
+	return count > 0
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// InitTusUploadTable 初始化断点续传上传表
+func InitTusUploadTable() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS tus_uploads (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			file_name TEXT NOT NULL,
+			file_type TEXT NOT NULL,
+			metadata TEXT NOT NULL DEFAULT '',
+			upload_length INTEGER NOT NULL,
+			upload_offset INTEGER NOT NULL DEFAULT 0,
+			part_path TEXT NOT NULL,
+			file_id INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL,
+			expires_at TEXT NOT NULL, -- UTC "2006-01-02 15:04:05"，按字符串比较
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_tus_uploads_expires ON tus_uploads(expires_at);`,
+	}
+	for _, stmt := range statements {
+		if _, err := DB.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	log.Println("断点续传上传表初始化成功")
+	return nil
+}
+
+const tusUploadColumns = "id, user_id, file_name, file_type, metadata, upload_length, upload_offset, part_path, file_id, created_at, expires_at"
+
+func scanTusUpload(s rowScanner) (*models.TusUpload, error) {
+	var u models.TusUpload
+	err := s.Scan(&u.ID, &u.UserID, &u.FileName, &u.FileType, &u.Metadata, &u.Length, &u.Offset, &u.PartPath, &u.FileID, &u.CreatedAt, &u.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// CreateTusUpload 创建上传记录，ttl 后过期
+func CreateTusUpload(u *models.TusUpload, ttl time.Duration) error {
+	u.CreatedAt = utils.NowUTCString()
+	u.ExpiresAt = utils.NowUTC().Add(ttl).Format("2006-01-02 15:04:05")
+	_, err := DB.Exec(
+		"INSERT INTO tus_uploads ("+tusUploadColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		u.ID, u.UserID, u.FileName, u.FileType, u.Metadata, u.Length, u.Offset, u.PartPath, u.FileID, u.CreatedAt, u.ExpiresAt,
+	)
+	return err
+}
+
+// GetTusUpload 获取用户的上传记录
+func GetTusUpload(id string, userID int) (*models.TusUpload, error) {
+	return scanTusUpload(DB.QueryRow("SELECT "+tusUploadColumns+" FROM tus_uploads WHERE id = ? AND user_id = ?", id, userID))
+}
+
+// UpdateTusUploadOffset 记录已接收的字节数，并把过期时间顺延 ttl
+func UpdateTusUploadOffset(id string, offset int64, ttl time.Duration) (string, error) {
+	expiresAt := utils.NowUTC().Add(ttl).Format("2006-01-02 15:04:05")
+	_, err := DB.Exec("UPDATE tus_uploads SET upload_offset = ?, expires_at = ? WHERE id = ?", offset, expiresAt, id)
+	return expiresAt, err
+}
+
+// CompleteTusUpload 上传完成：在同一事务中创建文件传输记录（回填 file.ID）并标记上传已完成，
+// 任一步失败时都不会留下记录；上传记录已不存在时返回 sql.ErrNoRows
+func CompleteTusUpload(id string, file *models.FileTransfer) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveFileTransferTx(tx, file); err != nil {
+		return err
+	}
+	result, err := tx.Exec("UPDATE tus_uploads SET file_id = ?, part_path = '' WHERE id = ? AND file_id = 0", file.ID, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// DeleteTusUpload 删除上传记录
+func DeleteTusUpload(id string) error {
+	_, err := DB.Exec("DELETE FROM tus_uploads WHERE id = ?", id)
+	return err
+}
+
+// DeleteExpiredTusUpload 删除仍处于过期状态的上传记录（期间又收到数据、已顺延的不删除）
+func DeleteExpiredTusUpload(id string) (bool, error) {
+	result, err := DB.Exec("DELETE FROM tus_uploads WHERE id = ? AND expires_at <= ?", id, utils.NowUTCString())
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// GetExpiredTusUploads 获取已过期的上传记录（含已完成的，已完成的只删除记录）
+func GetExpiredTusUploads() ([]models.TusUpload, error) {
+	rows, err := DB.Query("SELECT "+tusUploadColumns+" FROM tus_uploads WHERE expires_at <= ?", utils.NowUTCString())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uploads []models.TusUpload
+	for rows.Next() {
+		u, err := scanTusUpload(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, *u)
+	}
+	return uploads, rows.Err()
+}
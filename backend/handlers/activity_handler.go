@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/services"
+	"backend/utils"
+)
+
+// ActivityPredictionHandler 预测下一次活动时间（按标签）
+func ActivityPredictionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	predictions, err := services.PredictNextActivity(userID)
+	if err != nil {
+		log.Printf("预测下一次活动失败: %v", err)
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ActivityPredictionResponse{
+		Success: true,
+		Message: "获取成功",
+		Data:    predictions,
+	})
+}
+
+// 单次同步最多处理的修改数和返回的变更数
+const (
+	maxSyncMutations    = 500
+	defaultSyncLimit    = 200
+	maxSyncChangesLimit = 1000
+)
+
+// ActivitySyncHandler 离线同步：应用客户端修改（按幂等键去重），返回游标之后的变更和删除墓碑
+func ActivitySyncHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.ActivitySyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求数据", http.StatusBadRequest)
+		return
+	}
+	if len(req.Mutations) > maxSyncMutations {
+		http.Error(w, "单次提交的修改过多", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if req.Cursor < 0 {
+		req.Cursor = 0
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultSyncLimit
+	}
+	if req.Limit > maxSyncChangesLimit {
+		req.Limit = maxSyncChangesLimit
+	}
+
+	data, changed, err := database.ApplyActivitySync(userID, &req)
+	if err != nil {
+		log.Printf("同步健康活动失败: user_id=%d, error=%v", userID, err)
+		http.Error(w, "同步失败", http.StatusInternalServerError)
+		return
+	}
+	if changed {
+		services.InvalidateActivityStats(userID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ActivitySyncResponse{
+		Success: true,
+		Message: "同步成功",
+		Data:    data,
+	})
+}
+
+// ActivitySearchHandler 按备注全文搜索健康活动记录（分页），返回高亮片段
+func ActivitySearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	keyword := strings.TrimSpace(r.URL.Query().Get("q"))
+	if keyword == "" {
+		http.Error(w, "搜索关键词不能为空", http.StatusBadRequest)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	hits, total, err := database.SearchUserActivities(userID, keyword, page, pageSize)
+	if err != nil {
+		log.Printf("搜索健康活动失败: user_id=%d, q=%q, error=%v", userID, keyword, err)
+		http.Error(w, "搜索失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ActivitySearchResponse{
+		Success: true,
+		Message: "搜索成功",
+		Data: &models.ActivitySearchData{
+			List:     hits,
+			Total:    total,
+			Page:     page,
+			PageSize: pageSize,
+		},
+	})
+}
+
+// ActivitySeriesHandler 获取日期范围内的活动记录序列（?from=YYYY-MM-DD&to=YYYY-MM-DD，默认最近一年）
+func ActivitySeriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	data, ok := activityResourceData(w, r, userID, models.ActivityShareResourceSeries, false)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ActivitySeriesResponse{
+		Success: true,
+		Message: "获取成功",
+		Data:    data.(*models.ActivitySeriesData),
+	})
+}
+
+// activityResourceData 查询用户的统计/记录序列/热力图数据（本人查看和分享查看共用），出错时已写入响应
+func activityResourceData(w http.ResponseWriter, r *http.Request, userID int, resource string, hideRemarks bool) (interface{}, bool) {
+	switch resource {
+	case models.ActivityShareResourceStats:
+		stats, err := services.GetActivityStats(userID)
+		if err != nil {
+			log.Printf("获取统计数据失败: user_id=%d, error=%v", userID, err)
+			http.Error(w, "查询失败", http.StatusInternalServerError)
+			return nil, false
+		}
+		return stats, true
+
+	case models.ActivityShareResourceHeatmap:
+		from, to, ok := parseActivityDateRange(w, r)
+		if !ok {
+			return nil, false
+		}
+		data, err := services.GetActivityHeatmap(userID, from, to)
+		if err != nil {
+			log.Printf("获取热力图失败: user_id=%d, error=%v", userID, err)
+			http.Error(w, "查询失败", http.StatusInternalServerError)
+			return nil, false
+		}
+		return data, true
+
+	case models.ActivityShareResourceSeries:
+		from, to, ok := parseActivityDateRange(w, r)
+		if !ok {
+			return nil, false
+		}
+		activities, err := database.GetUserActivitiesBetween(userID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+		if err != nil {
+			log.Printf("获取活动记录序列失败: user_id=%d, error=%v", userID, err)
+			http.Error(w, "查询失败", http.StatusInternalServerError)
+			return nil, false
+		}
+		if hideRemarks {
+			for i := range activities {
+				activities[i].Remark = ""
+			}
+		}
+		return &models.ActivitySeriesData{
+			From: from.Format("2006-01-02"),
+			To:   to.Format("2006-01-02"),
+			List: activities,
+		}, true
+	}
+
+	http.Error(w, "不支持的资源", http.StatusNotFound)
+	return nil, false
+}
+
+// ActivityCompareHandler 对比两个日期范围（?a_from=&a_to=&b_from=&b_to=，YYYY-MM-DD）的活动情况，变化为 B 相对 A
+func ActivityCompareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var dates [4]time.Time
+	for i, name := range []string{"a_from", "a_to", "b_from", "b_to"} {
+		t, err := time.ParseInLocation("2006-01-02", r.URL.Query().Get(name), utils.GetShanghaiTZ())
+		if err != nil {
+			http.Error(w, "日期参数 "+name+" 缺失或格式错误，应为 YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		dates[i] = t
+	}
+	if dates[0].After(dates[1]) || dates[2].After(dates[3]) {
+		http.Error(w, "开始日期不能晚于结束日期", http.StatusBadRequest)
+		return
+	}
+
+	data, err := services.CompareActivityRanges(userID, dates[0], dates[1], dates[2], dates[3])
+	if err != nil {
+		log.Printf("对比活动范围失败: user_id=%d, error=%v", userID, err)
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ActivityComparisonResponse{
+		Success: true,
+		Message: "获取成功",
+		Data:    data,
+	})
+}
+
+// 批量操作单次最多指定的记录ID数
+const maxBulkIDs = 1000
+
+// ActivityBulkDeleteHandler 批量删除记录（按ID列表或日期范围+标签），preview=true 时仅预览
+func ActivityBulkDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := decodeActivityBulkRequest(w, r)
+	if !ok {
+		return
+	}
+	result, err := database.BulkDeleteActivities(userID, req.ActivityBulkSelector, req.Preview)
+	writeActivityBulkResult(w, userID, "删除", result, err)
+}
+
+// ActivityBulkRetagHandler 批量修改记录标签（new_tag: auto / manual），preview=true 时仅预览
+func ActivityBulkRetagHandler(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := decodeActivityBulkRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.NewTag != "auto" && req.NewTag != "manual" {
+		http.Error(w, "标签只能为 auto 或 manual", http.StatusBadRequest)
+		return
+	}
+	result, err := database.BulkRetagActivities(userID, req.ActivityBulkSelector, req.NewTag, req.Preview)
+	writeActivityBulkResult(w, userID, "修改标签", result, err)
+}
+
+// ActivityBulkShiftHandler 批量平移记录的日期和时间（shift_days + shift_minutes），preview=true 时仅预览
+func ActivityBulkShiftHandler(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := decodeActivityBulkRequest(w, r)
+	if !ok {
+		return
+	}
+	shift := time.Duration(req.ShiftDays)*24*time.Hour + time.Duration(req.ShiftMinutes)*time.Minute
+	if shift == 0 {
+		http.Error(w, "平移量不能为0", http.StatusBadRequest)
+		return
+	}
+	result, err := database.BulkShiftActivities(userID, req.ActivityBulkSelector, shift, req.Preview)
+	writeActivityBulkResult(w, userID, "平移", result, err)
+}
+
+// decodeActivityBulkRequest 校验方法、登录状态和筛选条件，出错时已写入响应
+func decodeActivityBulkRequest(w http.ResponseWriter, r *http.Request) (int, *models.ActivityBulkRequest, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return 0, nil, false
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return 0, nil, false
+	}
+
+	var req models.ActivityBulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求数据", http.StatusBadRequest)
+		return 0, nil, false
+	}
+	if len(req.IDs) > maxBulkIDs {
+		http.Error(w, "单次操作的记录过多", http.StatusRequestEntityTooLarge)
+		return 0, nil, false
+	}
+	for _, date := range []string{req.From, req.To} {
+		if date != "" && utils.WeekDay(date) == "" {
+			http.Error(w, "日期格式错误，应为 YYYY-MM-DD", http.StatusBadRequest)
+			return 0, nil, false
+		}
+	}
+	if req.Tag != "" && req.Tag != "auto" && req.Tag != "manual" {
+		http.Error(w, "标签只能为 auto 或 manual", http.StatusBadRequest)
+		return 0, nil, false
+	}
+	return userID, &req, true
+}
+
+func writeActivityBulkResult(w http.ResponseWriter, userID int, action string, result *models.ActivityBulkResult, err error) {
+	switch err {
+	case nil:
+	case database.ErrBulkSelectorEmpty:
+		http.Error(w, "请指定记录ID或筛选条件", http.StatusBadRequest)
+		return
+	case database.ErrActivityNotOwned:
+		http.Error(w, "部分记录不存在或无权操作", http.StatusForbidden)
+		return
+	default:
+		log.Printf("批量%s失败: user_id=%d, error=%v", action, userID, err)
+		http.Error(w, "批量"+action+"失败", http.StatusInternalServerError)
+		return
+	}
+
+	message := "批量" + action + "成功"
+	if result.Preview {
+		message = "预览成功"
+	} else if result.Affected > 0 {
+		services.InvalidateActivityStats(userID)
+		log.Printf("批量%s: user_id=%d, affected=%d", action, userID, result.Affected)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ActivityBulkResponse{
+		Success: true,
+		Message: message,
+		Data:    result,
+	})
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/services"
+	"backend/utils"
+)
+
+// 热力图颜色（等级 0-4）
+var heatmapColors = []string{"#ebedf0", "#c6e48b", "#7bc96f", "#239a3b", "#196127"}
+
+// 热力图、记录序列单次查询的最大天数
+const maxActivityRangeDays = 366
+
+type reportRow struct {
+	Label    string
+	Current  string
+	Previous string
+	Delta    string
+}
+
+type reportCell struct {
+	Day   string // 日（空表示非本月）
+	Title string
+	Color string
+}
+
+// RenderActivityReport 使用嵌入模板将月报渲染为 HTML（页面和邮件共用）
+func RenderActivityReport(report *models.ActivityMonthlyReport) ([]byte, error) {
+	tmpl, err := getTemplate("activity_report.html")
+	if err != nil {
+		return nil, err
+	}
+
+	cur, prev := report.Current, report.Previous
+	countDelta := fmt.Sprintf("%+d", report.CountDelta)
+	if report.CountChangePercent != nil {
+		countDelta += fmt.Sprintf("（%+.1f%%）", *report.CountChangePercent)
+	}
+	rows := []reportRow{
+		{"总次数", fmt.Sprint(cur.Count), fmt.Sprint(prev.Count), countDelta},
+		{"自动", fmt.Sprint(cur.AutoCount), fmt.Sprint(prev.AutoCount), fmt.Sprintf("%+d", cur.AutoCount-prev.AutoCount)},
+		{"手动", fmt.Sprint(cur.ManualCount), fmt.Sprint(prev.ManualCount), fmt.Sprintf("%+d", cur.ManualCount-prev.ManualCount)},
+		{"总时长（分钟）", fmt.Sprint(cur.TotalDuration), fmt.Sprint(prev.TotalDuration), fmt.Sprintf("%+d", report.DurationDelta)},
+		{"活跃天数", fmt.Sprint(cur.ActiveDays), fmt.Sprint(prev.ActiveDays), fmt.Sprintf("%+d", cur.ActiveDays-prev.ActiveDays)},
+		{"平均间隔（天）", formatDays(cur.AvgIntervalDays), formatDays(prev.AvgIntervalDays), formatDaysDelta(cur.AvgIntervalDays, prev.AvgIntervalDays)},
+		{"最短间隔（天）", formatDays(cur.MinIntervalDays), formatDays(prev.MinIntervalDays), formatDaysDelta(cur.MinIntervalDays, prev.MinIntervalDays)},
+		{"最长间隔（天）", formatDays(cur.MaxIntervalDays), formatDays(prev.MaxIntervalDays), formatDaysDelta(cur.MaxIntervalDays, prev.MaxIntervalDays)},
+	}
+
+	var weeks [][]reportCell
+	for _, week := range report.Calendar {
+		cells := make([]reportCell, len(week))
+		for i, d := range week {
+			if d.Date == "" {
+				continue
+			}
+			cells[i] = reportCell{
+				Day:   strings.TrimPrefix(d.Date[8:], "0"),
+				Title: fmt.Sprintf("%s：%d 次，%d 分钟", d.Date, d.Count, d.Duration),
+				Color: heatmapColors[d.Level],
+			}
+		}
+		weeks = append(weeks, cells)
+	}
+
+	data := struct {
+		Report *models.ActivityMonthlyReport
+		Rows   []reportRow
+		Weeks  [][]reportCell
+		Colors []string
+	}{
+		Report: report,
+		Rows:   rows,
+		Weeks:  weeks,
+		Colors: heatmapColors,
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func formatDays(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", *v)
+}
+
+func formatDaysDelta(cur, prev *float64) string {
+	if cur == nil || prev == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f", *cur-*prev)
+}
+
+// ActivityReportHandler 月度活动报告页面（?month=YYYY-MM，默认本月）
+func ActivityReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	month := r.URL.Query().Get("month")
+	if month == "" {
+		month = utils.Now().Format("2006-01")
+	}
+
+	report, err := services.BuildMonthlyReport(userID, month)
+	if err == services.ErrInvalidReportMonth {
+		http.Error(w, "月份格式错误，应为 YYYY-MM", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("生成月报失败: user_id=%d, month=%s, error=%v", userID, month, err)
+		http.Error(w, "生成报告失败", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "获取成功",
+			"data":    report,
+		})
+		return
+	}
+
+	body, err := RenderActivityReport(report)
+	if err != nil {
+		log.Printf("渲染月报失败: %v", err)
+		http.Error(w, "服务器错误", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(body)
+}
+
+// ActivityHeatmapHandler 每日活动热力图（?from=YYYY-MM-DD&to=YYYY-MM-DD，默认最近一年）
+func ActivityHeatmapHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	data, ok := activityResourceData(w, r, userID, models.ActivityShareResourceHeatmap, false)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ActivityHeatmapResponse{
+		Success: true,
+		Message: "获取成功",
+		Data:    data.(*models.ActivityHeatmapData),
+	})
+}
+
+// parseActivityDateRange 解析 from/to 日期范围（默认截至今天的最近一年），出错时已写入响应
+func parseActivityDateRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	tz := utils.GetShanghaiTZ()
+	now := utils.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, tz)
+	if s := r.URL.Query().Get("to"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, tz)
+		if err != nil {
+			http.Error(w, "结束日期格式错误", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -(maxActivityRangeDays - 1))
+	if s := r.URL.Query().Get("from"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, tz)
+		if err != nil {
+			http.Error(w, "开始日期格式错误", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+		from = t
+	}
+	if from.After(to) {
+		http.Error(w, "开始日期不能晚于结束日期", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+	if to.Sub(from).Hours()/24 >= maxActivityRangeDays {
+		http.Error(w, fmt.Sprintf("日期范围不能超过 %d 天", maxActivityRangeDays), http.StatusBadRequest)
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// ActivityReportSubscriptionHandler 月报邮件订阅：GET 查询，POST 订阅/修改，DELETE 取消
+func ActivityReportSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sub, err := database.GetActivityReportSubscription(userID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("查询月报订阅失败: user_id=%d, error=%v", userID, err)
+			http.Error(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.ActivityReportSubscriptionResponse{
+			Success:      true,
+			Message:      "获取成功",
+			Subscription: sub,
+		})
+
+	case http.MethodPost:
+		var req models.ActivityReportSubscriptionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "无效的请求数据", http.StatusBadRequest)
+			return
+		}
+		addr, err := mail.ParseAddress(strings.TrimSpace(req.Email))
+		if err != nil {
+			http.Error(w, "邮箱格式错误", http.StatusBadRequest)
+			return
+		}
+		enabled := req.Enabled == nil || *req.Enabled
+
+		sub, err := database.SaveActivityReportSubscription(userID, addr.Address, enabled)
+		if err != nil {
+			log.Printf("保存月报订阅失败: user_id=%d, error=%v", userID, err)
+			http.Error(w, "保存失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.ActivityReportSubscriptionResponse{
+			Success:      true,
+			Message:      "订阅成功",
+			Subscription: sub,
+		})
+
+	case http.MethodDelete:
+		err := database.DeleteActivityReportSubscription(userID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "未订阅月报", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("取消月报订阅失败: user_id=%d, error=%v", userID, err)
+			http.Error(w, "取消失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.ActivityReportSubscriptionResponse{
+			Success: true,
+			Message: "已取消订阅",
+		})
+
+	default:
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+	}
+}
+
+// ActivityReportSendHandler 立即将指定月份（?month=YYYY-MM，默认上月）的月报发送到订阅邮箱
+func ActivityReportSendHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	if !services.SMTP.Enabled() {
+		http.Error(w, "未配置邮件服务", http.StatusServiceUnavailable)
+		return
+	}
+
+	sub, err := database.GetActivityReportSubscription(userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "请先设置接收月报的邮箱", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("查询月报订阅失败: user_id=%d, error=%v", userID, err)
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	month := r.URL.Query().Get("month")
+	if month == "" {
+		now := utils.Now()
+		month = time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, now.Location()).Format("2006-01")
+	}
+
+	err = services.SendActivityReport(RenderActivityReport, userID, sub.Email, month)
+	if err == services.ErrInvalidReportMonth {
+		http.Error(w, "月份格式错误，应为 YYYY-MM", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("发送月报失败: user_id=%d, month=%s, error=%v", userID, month, err)
+		http.Error(w, "发送失败", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "月报已发送到 " + sub.Email,
+	})
+}
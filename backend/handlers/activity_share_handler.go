@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+)
+
+// 单次返回的访问日志条数
+const activityShareLogLimit = 200
+
+// CreateActivityShareHandler 创建活动统计只读分享：指定 grantee_username 时授权给该用户，否则生成公开 token
+func CreateActivityShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateActivityShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求数据", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresDays < 0 {
+		http.Error(w, "有效天数不能为负数", http.StatusBadRequest)
+		return
+	}
+
+	granteeID := 0
+	if username := strings.TrimSpace(req.GranteeUsername); username != "" {
+		id, err := database.GetUserIDByUsername(username)
+		if err == sql.ErrNoRows {
+			http.Error(w, "用户不存在", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("查询用户失败: %v", err)
+			http.Error(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+		if id == userID {
+			http.Error(w, "不能分享给自己", http.StatusBadRequest)
+			return
+		}
+		granteeID = id
+	}
+
+	grant, err := database.CreateActivityShare(userID, granteeID, req.HideRemarks, time.Duration(req.ExpiresDays)*24*time.Hour)
+	if err != nil {
+		log.Printf("创建统计分享失败: %v", err)
+		http.Error(w, "创建分享失败", http.StatusInternalServerError)
+		return
+	}
+
+	resp := models.ActivityShareResponse{
+		Success: true,
+		Message: "分享创建成功",
+		Grant:   grant,
+	}
+	if grant.Token != "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		resp.ShareURL = scheme + "://" + r.Host + "/api/public/activities/" + grant.Token + "/"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+
+	log.Printf("创建统计分享成功: owner_id=%d, grant_id=%d, grantee_id=%d", userID, grant.ID, granteeID)
+}
+
+// ListActivitySharesHandler 获取自己创建的统计分享（含已撤销）
+func ListActivitySharesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	grants, err := database.GetUserActivityShares(userID)
+	if err != nil {
+		log.Printf("获取统计分享列表失败: %v", err)
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ActivityShareListResponse{
+		Success: true,
+		Message: "获取成功",
+		List:    grants,
+	})
+}
+
+// ListReceivedActivitySharesHandler 获取别人授权给自己、仍然有效的统计分享
+func ListReceivedActivitySharesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	grants, err := database.GetReceivedActivityShares(userID)
+	if err != nil {
+		log.Printf("获取收到的统计分享失败: %v", err)
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ActivityShareListResponse{
+		Success: true,
+		Message: "获取成功",
+		List:    grants,
+	})
+}
+
+// RevokeActivityShareHandler 撤销统计分享（?id=）
+func RevokeActivityShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "无效的分享ID", http.StatusBadRequest)
+		return
+	}
+
+	err = database.RevokeActivityShare(id, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "分享不存在或已撤销", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("撤销统计分享失败: %v", err)
+		http.Error(w, "撤销失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ActivityShareResponse{
+		Success: true,
+		Message: "已撤销分享",
+	})
+
+	log.Printf("撤销统计分享: owner_id=%d, grant_id=%d", userID, id)
+}
+
+// ActivityShareLogsHandler 获取统计分享的访问日志（?id=，仅拥有者）
+func ActivityShareLogsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "无效的分享ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := database.GetActivityShareByID(id, userID); err != nil {
+		http.Error(w, "分享不存在", http.StatusNotFound)
+		return
+	}
+
+	logs, err := database.GetActivityShareAccessLogs(id, activityShareLogLimit)
+	if err != nil {
+		log.Printf("获取分享访问日志失败: %v", err)
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ActivityShareLogResponse{
+		Success: true,
+		Message: "获取成功",
+		List:    logs,
+	})
+}
+
+// SharedActivityHandler 被授权用户只读查看（需登录）
+// 路径格式：/api/activities/shared/{stats|series|heatmap}?grant_id=
+func SharedActivityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	resource := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/activities/shared/"), "/")
+	grantID, err := strconv.Atoi(r.URL.Query().Get("grant_id"))
+	if err != nil {
+		http.Error(w, "无效的分享ID", http.StatusBadRequest)
+		return
+	}
+
+	grant, err := database.GetActivityShare(grantID)
+	if err != nil {
+		log.Printf("访问不存在的统计分享: grant_id=%d, viewer_id=%d", grantID, userID)
+		http.Error(w, "分享不存在或无权限", http.StatusNotFound)
+		return
+	}
+	serveSharedActivity(w, r, grant, userID, resource)
+}
+
+// PublicSharedActivityHandler 通过公开 token 只读查看，无需登录
+// 路径格式：/api/public/activities/{token}/{stats|series|heatmap}
+func PublicSharedActivityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/public/activities/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "无效的分享链接", http.StatusNotFound)
+		return
+	}
+	token, resource := parts[0], parts[1]
+
+	grant, err := database.GetActivityShareByToken(token)
+	if err != nil {
+		// 无法对应到分享，只能记录在服务端日志中
+		log.Printf("访问不存在的统计分享: ip=%s, resource=%s", clientIP(r), resource)
+		http.Error(w, "分享不存在或已失效", http.StatusNotFound)
+		return
+	}
+	serveSharedActivity(w, r, grant, 0, resource)
+}
+
+// serveSharedActivity 记录访问日志（包括被拒绝的访问）后校验分享，有效时返回拥有者的只读数据
+// viewerID 为0表示通过公开 token 访问
+func serveSharedActivity(w http.ResponseWriter, r *http.Request, grant *models.ActivityShareGrant, viewerID int, resource string) {
+	status := sharedActivityAccessStatus(grant, viewerID, resource)
+	if err := database.LogActivityShareAccess(grant.ID, viewerID, resource, status, clientIP(r), r.UserAgent()); err != nil {
+		log.Printf("记录分享访问日志失败: grant_id=%d, error=%v", grant.ID, err)
+	}
+
+	switch status {
+	case models.ActivityShareAccessOK:
+	case models.ActivityShareAccessForbidden:
+		http.Error(w, "分享不存在或无权限", http.StatusNotFound)
+		return
+	case models.ActivityShareAccessInvalid:
+		http.Error(w, "不支持的资源", http.StatusNotFound)
+		return
+	default:
+		http.Error(w, "分享不存在或已失效", http.StatusNotFound)
+		return
+	}
+
+	data, ok := activityResourceData(w, r, grant.OwnerID, resource, grant.HideRemarks)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.SharedActivityResponse{
+		Success:       true,
+		Message:       "获取成功",
+		OwnerUsername: grant.OwnerUsername,
+		HideRemarks:   grant.HideRemarks,
+		Data:          data,
+	})
+}
+
+// sharedActivityAccessStatus 判断一次分享访问的结果
+func sharedActivityAccessStatus(grant *models.ActivityShareGrant, viewerID int, resource string) string {
+	// 授权给用户的分享只能由该用户登录访问，公开 token 只能访问公开分享
+	if grant.GranteeID != viewerID {
+		return models.ActivityShareAccessForbidden
+	}
+	if grant.RevokedAt != "" {
+		return models.ActivityShareAccessRevoked
+	}
+	if !grant.Active(utils.NowString()) {
+		return models.ActivityShareAccessExpired
+	}
+	switch resource {
+	case models.ActivityShareResourceStats, models.ActivityShareResourceSeries, models.ActivityShareResourceHeatmap:
+		return models.ActivityShareAccessOK
+	}
+	return models.ActivityShareAccessInvalid
+}
+
+// clientIP 请求的来源地址（不含端口）
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
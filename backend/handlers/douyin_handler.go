@@ -1,19 +1,25 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 
 	"backend/database"
 	"backend/models"
 	"backend/services"
+	"backend/utils"
 )
 
-// 抖音解析处理器
+// 一次粘贴最多处理的链接数
+const maxDouyinBatchURLs = 50
+
+// 抖音解析处理器（文本中有多个链接时逐个加入队列，并返回批次ID）
 func DouyinParsingHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
@@ -32,101 +38,288 @@ func DouyinParsingHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 提取URL
-	url, err := services.ExtractURL(req.Text)
-	if err != nil {
+	// 提取所有URL
+	urls := services.ExtractURLs(req.Text)
+	if len(urls) == 0 {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(models.DouyinParsingResponse{
 			Success: false,
-			Message: err.Error(),
+			Message: "未解析到正确链接",
 		})
 		return
 	}
+	if len(urls) > maxDouyinBatchURLs {
+		http.Error(w, fmt.Sprintf("一次最多提交 %d 个链接", maxDouyinBatchURLs), http.StatusBadRequest)
+		return
+	}
 
-	// 先检查该用户是否已解析过此URL且文件存在
-	urlExists, err := database.URLExists(userID, url)
-	if err != nil {
-		log.Printf("检查URL是否存在失败: %v", err)
-	} else if urlExists {
-		// 该用户已解析过，检查文件是否存在
-		filesExist, err := database.CheckURLFilesExist(userID, url)
+	// 并发解析短链的作品ID（总时长有上限），避免逐个请求长时间占用连接
+	awemeIDs := services.ResolveAwemeIDs(r.Context(), urls)
+
+	if len(urls) == 1 {
+		item, err := services.ParseDouyinLink(userID, urls[0], awemeIDs[0])
 		if err != nil {
-			log.Printf("检查文件是否存在失败: %v", err)
-		} else if filesExist {
-			// 该用户已解析且文件存在，直接返回成功
-			log.Printf("用户已解析过此URL且文件存在，直接返回成功: %s, 用户ID: %d", url, userID)
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(models.DouyinParsingResponse{
-				Success: true,
-				Message: "解析成功（文件已存在）",
-				Data:    url,
-			})
+			log.Printf("创建抖音下载任务失败: %v", err)
+			http.Error(w, "创建下载任务失败", http.StatusInternalServerError)
 			return
 		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.DouyinParsingResponse{
+			Success: true,
+			Message: item.Message,
+			Data:    item.URL,
+			AwemeID: item.AwemeID,
+			JobID:   item.JobID,
+			Status:  item.Status,
+		})
+		return
 	}
 
-	// 检查URL是否已被任何用户解析过且文件存在
-	parsedAndExists, paths, err := database.CheckURLParsedAndFileExists(url)
-	if err != nil {
-		log.Printf("检查URL是否已解析失败: %v", err)
-	} else if parsedAndExists {
-		// URL已被解析过且文件存在，直接返回成功，并为当前用户创建记录
-		log.Printf("URL已被其他用户解析过且文件存在，直接返回成功: %s, 用户ID: %d", url, userID)
-		
-		// 保存URL到数据库（为该用户创建URL记录，保证唯一性）
-		// SaveDouyinURL 使用 INSERT OR IGNORE，已存在的记录会被忽略
-		if err := database.SaveDouyinURL(userID, url); err != nil {
-			log.Printf("保存URL失败: %v", err)
+	// 多个链接：逐个处理，记录为一个批次供客户端查询进度
+	items := make([]models.DouyinParsingItem, 0, len(urls))
+	for i, url := range urls {
+		item, err := services.ParseDouyinLink(userID, url, awemeIDs[i])
+		if err != nil {
+			log.Printf("创建抖音下载任务失败: %s, %v", url, err)
+			item = &models.DouyinParsingItem{URL: url, Status: models.DouyinJobFailed, Message: "创建下载任务失败"}
 		}
+		items = append(items, *item)
+	}
 
-		// 为该用户创建文件记录（如果还没有，保证唯一性）
-		// CreateFileRecordForUser 内部会检查并创建，SaveDouyinFile 使用 INSERT OR IGNORE 保证唯一性
-		if err := database.CreateFileRecordForUser(userID, url, paths); err != nil {
-			log.Printf("为用户创建文件记录失败: %v", err)
-		}
+	batchID, err := database.CreateDouyinBatch(userID, items)
+	if err != nil {
+		log.Printf("保存抖音解析批次失败: %v", err)
+		http.Error(w, "保存批次失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DouyinParsingResponse{
+		Success: true,
+		Message: fmt.Sprintf("已解析 %d 个链接", len(items)),
+		BatchID: batchID,
+		Items:   items,
+	})
+}
+
+// 抖音批量解析批次查询处理器
+// GET /api/douyin/batches/{id}  批次中各链接的当前状态
+func DouyinBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	batchID, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/douyin/batches"), "/"))
+	if err != nil {
+		http.Error(w, "无效的批次ID", http.StatusBadRequest)
+		return
+	}
+
+	batch, err := database.GetDouyinBatch(batchID, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "批次不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("获取抖音解析批次失败: %v", err)
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DouyinBatchResponse{
+		Success: true,
+		Message: "获取成功",
+		Batch:   batch,
+	})
+}
 
+// 抖音下载任务处理器
+// GET  /api/douyin/jobs/             最近的任务列表
+// GET  /api/douyin/jobs/{id}         任务状态、日志和每次执行的记录
+// POST /api/douyin/jobs/{id}/cancel  取消排队中或执行中的任务
+func DouyinJobHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/douyin/jobs"), "/")
+	if strings.HasSuffix(idStr, "/cancel") {
+		cancelDouyinJob(w, r, strings.TrimSuffix(idStr, "/cancel"))
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	if idStr == "" {
+		jobs, err := database.GetUserDouyinJobs(userID, 50)
+		if err != nil {
+			log.Printf("获取抖音任务列表失败: %v", err)
+			http.Error(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(models.DouyinParsingResponse{
+		json.NewEncoder(w).Encode(models.DouyinJobListResponse{
 			Success: true,
-			Message: "解析成功（使用已有文件）",
-			Data:    url,
+			Message: "获取成功",
+			List:    jobs,
 		})
 		return
 	}
 
-	// URL未被解析过或文件不存在，执行解析命令
-	result, err := services.ParseDouyinURL(req.Text)
+	jobID, err := strconv.Atoi(idStr)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(models.DouyinParsingResponse{
-			Success: false,
-			Message: err.Error(),
-		})
+		http.Error(w, "无效的任务ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := database.GetDouyinJob(jobID, userID)
+	if err != nil {
+		http.Error(w, "任务不存在", http.StatusNotFound)
 		return
 	}
+	attempts, err := database.GetDouyinJobAttempts(jobID)
+	if err != nil {
+		log.Printf("获取抖音任务执行记录失败: %v", err)
+	}
 
-	log.Printf("解析抖音链接成功: %s, 用户ID: %d", result.Data, userID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DouyinJobResponse{
+		Success:  true,
+		Message:  "获取成功",
+		Job:      job,
+		Attempts: attempts,
+	})
+}
 
-	// 如果解析成功，保存URL并扫描文件
-	if result.Success {
-		// 保存URL到数据库
-		if err := database.SaveDouyinURL(userID, url); err != nil {
-			log.Printf("保存URL失败: %v", err)
+func cancelDouyinJob(w http.ResponseWriter, r *http.Request, idStr string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	jobID, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "无效的任务ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := services.CancelDouyinJob(jobID, userID); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			http.Error(w, "任务不存在", http.StatusNotFound)
+		case err == services.ErrDouyinJobNotCancellable:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("取消抖音任务失败: %v", err)
+			http.Error(w, "取消失败", http.StatusInternalServerError)
 		}
+		return
+	}
+
+	job, err := database.GetDouyinJob(jobID, userID)
+	if err != nil {
+		http.Error(w, "任务不存在", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DouyinJobResponse{
+		Success: true,
+		Message: "已请求取消",
+		Job:     job,
+	})
+}
+
+// 下载失败的抖音链接列表（含等待自动重试的链接）
+// GET /api/douyin/failed
+func DouyinFailedURLListHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := database.GetUserFailedDouyinURLs(userID)
+	if err != nil {
+		log.Printf("获取下载失败的抖音链接失败: %v", err)
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DouyinFailedURLListResponse{
+		Success: true,
+		Message: "获取成功",
+		List:    list,
+	})
+}
+
+// 手动重试下载失败的抖音链接
+// POST /api/douyin/failed/retry?id=
+func DouyinRetryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
 
-		// 扫描并保存文件
-		go func() {
-			if err := services.ScanAndSaveFiles(userID, url); err != nil {
-				log.Printf("扫描文件失败: %v", err)
-			}
-		}()
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "无效的链接ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := services.RetryDouyinURL(id, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "链接不存在或未失败", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("重试抖音链接失败: %v", err)
+		http.Error(w, "重试失败", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	json.NewEncoder(w).Encode(models.DouyinJobResponse{
+		Success: true,
+		Message: "已重新加入下载队列",
+		Job:     job,
+	})
 }
 
 // 获取抖音文件列表处理器
+// GET /api/douyin/files?page=&page_size=&q=&sort=date|size|author|name&order=asc|desc&group_by=url
 func DouyinFileListHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
@@ -139,8 +332,45 @@ func DouyinFileListHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	files, err := database.GetUserDouyinFiles(userID)
+	// 分页参数与文件传输列表一致；两个参数都未提供时返回全部文件（兼容旧客户端）
+	query := r.URL.Query()
+	page, pageSize := 0, 0
+	if query.Has("page") || query.Has("page_size") {
+		page, _ = strconv.Atoi(query.Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		pageSize, _ = strconv.Atoi(query.Get("page_size"))
+		if pageSize < 1 {
+			pageSize = 10
+		}
+		if pageSize > 100 {
+			pageSize = 100
+		}
+	}
+
+	q := models.DouyinFileQuery{
+		Keyword:  query.Get("q"),
+		Sort:     query.Get("sort"),
+		Desc:     query.Get("order") != "asc",
+		Page:     page,
+		PageSize: pageSize,
+	}
+	resp := models.DouyinFileListResponse{
+		Success:  true,
+		Message:  "获取成功",
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	var err error
+	if query.Get("group_by") == "url" {
+		resp.Groups, resp.Total, err = database.QueryUserDouyinFileGroups(userID, q)
+	} else {
+		resp.List, resp.Total, err = database.QueryUserDouyinFiles(userID, q)
+	}
 	if err != nil {
+		log.Printf("获取抖音文件列表失败: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(models.DouyinFileListResponse{
 			Success: false,
@@ -150,11 +380,7 @@ func DouyinFileListHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.DouyinFileListResponse{
-		Success: true,
-		Message: "获取成功",
-		List:    files,
-	})
+	json.NewEncoder(w).Encode(resp)
 }
 
 // 下载抖音文件处理器
@@ -205,5 +431,94 @@ func DouyinDownloadHandler(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, file.Path)
 }
 
+// 删除抖音文件处理器（只删除当前用户的记录，没有其他用户引用时才删除物理文件）
+// DELETE /api/douyin/delete?id=
+func DouyinFileDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	fileID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "无效的文件ID", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := database.DeleteDouyinFile(fileID, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "文件不存在或无权限", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("删除抖音文件失败: %v", err)
+		http.Error(w, "删除失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"message":      "删除成功",
+		"file_removed": removed,
+	})
+}
+
+// 把抖音视频加入文件传输库（硬链接或复制到 uploads/），之后可在文件传输中列出、下载和分享
+// POST /api/douyin/promote?id=
+func DouyinPromoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	fileID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "无效的文件ID", http.StatusBadRequest)
+		return
+	}
+
+	transfer, linked, err := services.PromoteDouyinFile(userID, fileID, uploadDir)
+	if err == sql.ErrNoRows {
+		http.Error(w, "文件不存在或无权限", http.StatusNotFound)
+		return
+	}
+	if os.IsNotExist(err) {
+		http.Error(w, "文件不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("抖音视频加入文件传输失败: %v", err)
+		http.Error(w, "加入文件传输失败", http.StatusInternalServerError)
+		return
+	}
+
+	transfer.FileSizeStr = formatFileSize(transfer.FileSize)
+	transfer.CreatedAt = utils.UTCToShanghai(transfer.CreatedAt)
+	message := "已加入文件传输（复制）"
+	if linked {
+		message = "已加入文件传输（硬链接）"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.FileUploadResponse{
+		Success: true,
+		Message: message,
+		Data:    *transfer,
+	})
+}
+
 // 从请求头获取用户ID（辅助函数）
 // getUserID 函数已移至 auth.go
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+)
+
+// CreateDouyinShareHandler 为自己的抖音视频生成公开观看链接
+// POST /api/douyin/share/create {file_id, expires_days}
+func CreateDouyinShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateDouyinShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求数据", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresDays < 0 {
+		http.Error(w, "有效天数不能为负数", http.StatusBadRequest)
+		return
+	}
+
+	share, err := database.CreateDouyinShare(userID, req.FileID, time.Duration(req.ExpiresDays)*24*time.Hour)
+	if err == sql.ErrNoRows {
+		http.Error(w, "文件不存在或无权限", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("创建抖音视频分享失败: %v", err)
+		http.Error(w, "创建分享失败", http.StatusInternalServerError)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DouyinShareResponse{
+		Success:  true,
+		Message:  "分享创建成功",
+		Share:    share,
+		ShareURL: scheme + "://" + r.Host + "/share/douyin/" + share.Token,
+	})
+
+	log.Printf("创建抖音视频分享成功: user_id=%d, share_id=%d, file_id=%d", userID, share.ID, share.FileID)
+}
+
+// ListDouyinSharesHandler 获取自己创建的抖音视频分享（含已撤销和已过期）
+func ListDouyinSharesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	shares, err := database.GetUserDouyinShares(userID)
+	if err != nil {
+		log.Printf("获取抖音视频分享列表失败: %v", err)
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DouyinShareListResponse{
+		Success: true,
+		Message: "获取成功",
+		List:    shares,
+	})
+}
+
+// RevokeDouyinShareHandler 撤销抖音视频分享（?id=）
+func RevokeDouyinShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "无效的分享ID", http.StatusBadRequest)
+		return
+	}
+
+	err = database.RevokeDouyinShare(id, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "分享不存在或已撤销", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("撤销抖音视频分享失败: %v", err)
+		http.Error(w, "撤销失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DouyinShareResponse{
+		Success: true,
+		Message: "已撤销分享",
+	})
+
+	log.Printf("撤销抖音视频分享: user_id=%d, share_id=%d", userID, id)
+}
+
+// activeDouyinShare 通过 token 获取仍然有效的分享及对应文件
+func activeDouyinShare(token string) (*models.DouyinShare, *models.DouyinFile, bool) {
+	share, err := database.GetDouyinShareByToken(token)
+	if err != nil || !share.Active(utils.NowString()) {
+		return nil, nil, false
+	}
+	file, err := database.GetDouyinFileByID(share.FileID, share.UserID)
+	if err != nil {
+		return nil, nil, false
+	}
+	return share, file, true
+}
+
+// DouyinSharePageHandler 抖音视频分享观看页面（公开访问，无需登录）
+// GET /share/douyin/{token}
+func DouyinSharePageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmpl, err := getTemplate("douyin_share.html")
+	if err != nil {
+		log.Printf("解析模板失败: %v", err)
+		http.Error(w, "服务器错误", http.StatusInternalServerError)
+		return
+	}
+
+	token := strings.Trim(strings.TrimPrefix(r.URL.Path, "/share/douyin/"), "/")
+	share, file, ok := activeDouyinShare(token)
+
+	// 模板在 Share 为空时渲染“分享不存在或已失效”
+	data := struct {
+		Share     *models.DouyinShare
+		File      *models.DouyinFile
+		StreamURL string
+		CoverURL  string
+	}{}
+	if ok {
+		if err := database.IncrementDouyinShareViewCount(share.ID); err != nil {
+			log.Printf("更新分享访问次数失败: share_id=%d, error=%v", share.ID, err)
+		}
+		data.Share = share
+		data.File = file
+		data.StreamURL = "/api/public/douyin/" + token + "/stream"
+		if file.CoverPath != "" {
+			data.CoverURL = "/api/public/douyin/" + token + "/cover"
+		}
+	}
+
+	// 响应头必须在 WriteHeader 之前设置
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("渲染模板失败: %v", err)
+	}
+}
+
+// PublicDouyinShareHandler 通过分享 token 在线播放视频或获取封面，无需登录
+// GET /api/public/douyin/{token}/stream
+// GET /api/public/douyin/{token}/cover
+func PublicDouyinShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/public/douyin/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "无效的分享链接", http.StatusNotFound)
+		return
+	}
+
+	_, file, ok := activeDouyinShare(parts[0])
+	if !ok {
+		http.Error(w, "分享不存在或已失效", http.StatusNotFound)
+		return
+	}
+
+	// 每次请求都重新校验分享是否有效（仍可用 ETag 协商），撤销后立即无法访问
+	switch parts[1] {
+	case "stream":
+		serveDouyinVideo(w, r, file, "no-cache")
+	case "cover":
+		if file.CoverPath == "" {
+			http.Error(w, "封面不存在", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeFile(w, r, file.CoverPath)
+	default:
+		http.Error(w, "不支持的资源", http.StatusNotFound)
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"backend/database"
+	"backend/models"
+	"backend/services"
+)
+
+// DouyinSubscriptionHandler 创作者订阅
+// GET    /api/douyin/subscriptions               订阅列表
+// POST   /api/douyin/subscriptions               创建订阅 {profile_url, name}
+// DELETE /api/douyin/subscriptions/{id}          删除订阅
+// POST   /api/douyin/subscriptions/{id}/pause    暂停
+// POST   /api/douyin/subscriptions/{id}/resume   恢复
+// POST   /api/douyin/subscriptions/{id}/check    立即检查新作品
+// GET    /api/douyin/subscriptions/{id}/history  发现的作品及下载状态
+func DouyinSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/douyin/subscriptions"), "/")
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			listDouyinSubscriptions(w, userID)
+		case http.MethodPost:
+			createDouyinSubscription(w, r, userID)
+		default:
+			http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	idStr, action, _ := strings.Cut(path, "/")
+	subID, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "无效的订阅ID", http.StatusBadRequest)
+		return
+	}
+
+	method := map[string]string{
+		"":        http.MethodDelete,
+		"pause":   http.MethodPost,
+		"resume":  http.MethodPost,
+		"check":   http.MethodPost,
+		"history": http.MethodGet,
+	}
+	want, ok := method[action]
+	if !ok {
+		http.Error(w, "接口不存在", http.StatusNotFound)
+		return
+	}
+	if r.Method != want {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch action {
+	case "":
+		deleted, err := database.DeleteDouyinSubscription(subID, userID)
+		if err != nil {
+			log.Printf("删除订阅失败: %v", err)
+			http.Error(w, "删除失败", http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.Error(w, "订阅不存在", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.DouyinSubscriptionResponse{Success: true, Message: "删除成功"})
+	case "pause", "resume":
+		status := models.DouyinSubscriptionPaused
+		message := "已暂停"
+		if action == "resume" {
+			status = models.DouyinSubscriptionActive
+			message = "已恢复"
+		}
+		updated, err := database.SetDouyinSubscriptionStatus(subID, userID, status)
+		if err != nil {
+			log.Printf("更新订阅状态失败: %v", err)
+			http.Error(w, "更新失败", http.StatusInternalServerError)
+			return
+		}
+		if !updated {
+			http.Error(w, "订阅不存在", http.StatusNotFound)
+			return
+		}
+		writeDouyinSubscription(w, subID, userID, message, 0)
+	case "check":
+		sub, err := database.GetDouyinSubscription(subID, userID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "订阅不存在", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+		added, err := services.CheckDouyinSubscription(sub)
+		if err != nil {
+			log.Printf("检查订阅失败: subscription_id=%d, error=%v", subID, err)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.DouyinSubscriptionResponse{Success: false, Message: "检查失败: " + err.Error()})
+			return
+		}
+		writeDouyinSubscription(w, subID, userID, "检查完成", added)
+	case "history":
+		if _, err := database.GetDouyinSubscription(subID, userID); err != nil {
+			http.Error(w, "订阅不存在", http.StatusNotFound)
+			return
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 200 {
+			limit = 50
+		}
+		posts, err := database.GetDouyinSubscriptionPosts(subID, limit)
+		if err != nil {
+			log.Printf("获取订阅历史失败: %v", err)
+			http.Error(w, "查询失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.DouyinSubscriptionHistoryResponse{Success: true, Message: "获取成功", List: posts})
+	}
+}
+
+func listDouyinSubscriptions(w http.ResponseWriter, userID int) {
+	subs, err := database.GetUserDouyinSubscriptions(userID)
+	if err != nil {
+		log.Printf("获取订阅列表失败: %v", err)
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DouyinSubscriptionListResponse{Success: true, Message: "获取成功", List: subs})
+}
+
+func createDouyinSubscription(w http.ResponseWriter, r *http.Request, userID int) {
+	var req models.CreateDouyinSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求数据", http.StatusBadRequest)
+		return
+	}
+	profileURL := strings.TrimSpace(req.ProfileURL)
+	if u, err := url.Parse(profileURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, "无效的主页链接", http.StatusBadRequest)
+		return
+	}
+
+	sub, err := database.CreateDouyinSubscription(userID, profileURL, strings.TrimSpace(req.Name))
+	if err != nil {
+		log.Printf("创建订阅失败: %v", err)
+		http.Error(w, "创建失败", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DouyinSubscriptionResponse{
+		Success:      true,
+		Message:      "订阅成功，首次检查只记录已有作品，之后发布的作品会自动下载",
+		Subscription: sub,
+	})
+}
+
+func writeDouyinSubscription(w http.ResponseWriter, subID, userID int, message string, added int) {
+	sub, err := database.GetDouyinSubscription(subID, userID)
+	if err != nil {
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DouyinSubscriptionResponse{
+		Success:      true,
+		Message:      message,
+		Subscription: sub,
+		Added:        added,
+	})
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"backend/database"
+)
+
+// setupTestDB 在临时目录中初始化一个全新的数据库，并切换到临时工作目录（上传目录使用相对路径）
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := database.InitDB(filepath.Join(dir, "test.db")); err != nil {
+		t.Fatalf("初始化测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Close()
+	})
+	t.Chdir(dir)
+}
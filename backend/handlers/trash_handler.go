@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"backend/database"
+	"backend/models"
+	"backend/services"
+)
+
+// TrashListHandler 获取回收站列表（可按 type 过滤：activity / file / music / lyrics）
+func TrashListHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	items, err := database.GetUserTrashItems(userID, r.URL.Query().Get("type"), services.TrashRetention)
+	if err != nil {
+		log.Printf("获取回收站列表失败: %v", err)
+		http.Error(w, "获取回收站列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TrashListResponse{
+		Success: true,
+		Message: "获取成功",
+		List:    items,
+	})
+}
+
+// TrashRestoreHandler 从回收站恢复记录
+func TrashRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	trashID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || trashID <= 0 {
+		http.Error(w, "无效的回收站条目ID", http.StatusBadRequest)
+		return
+	}
+
+	item, err := database.RestoreTrashItem(userID, trashID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		switch err {
+		case sql.ErrNoRows:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(models.TrashRestoreResponse{Success: false, Message: "回收站条目不存在"})
+		case database.ErrTrashRestoreConflict:
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(models.TrashRestoreResponse{Success: false, Message: err.Error()})
+		default:
+			log.Printf("恢复回收站条目失败: trash_id=%d, error=%v", trashID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(models.TrashRestoreResponse{Success: false, Message: "恢复失败"})
+		}
+		return
+	}
+	if item.ItemType == models.TrashTypeActivity {
+		services.InvalidateActivityStats(userID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TrashRestoreResponse{
+		Success:  true,
+		Message:  "恢复成功",
+		ItemType: item.ItemType,
+		ItemID:   item.ItemID,
+	})
+}
+
+// TrashEmptyHandler 清空回收站（指定 id 时只永久删除该条目）
+func TrashEmptyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	trashID := 0
+	if idStr := r.URL.Query().Get("id"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil || id <= 0 {
+			http.Error(w, "无效的回收站条目ID", http.StatusBadRequest)
+			return
+		}
+		trashID = id
+	}
+
+	count, err := database.EmptyUserTrash(userID, trashID)
+	if err != nil {
+		log.Printf("清空回收站失败: user_id=%d, error=%v", userID, err)
+		http.Error(w, "清空回收站失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "清空成功",
+		"count":   count,
+	})
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/services"
+	"backend/utils"
+)
+
+// tus 断点续传协议（https://tus.io/protocols/resumable-upload）
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,creation-with-upload,termination,expiration"
+	tusBasePath   = "/api/file/tus/"
+	tusChunkType  = "application/offset+octet-stream"
+)
+
+// 未完成上传的临时文件目录
+var tusPartDir = filepath.Join(uploadDir, "tus")
+
+// TusOptionsHandler 协议探测（OPTIONS，不需要登录）：返回支持的版本、扩展和最大文件大小
+func TusOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(services.TusMaxSize, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TusUploadHandler 断点续传上传，完成后成为文件传输记录
+// POST   /api/file/tus/       创建上传（Upload-Length、Upload-Metadata: filename/filetype），可同时携带第一段数据
+// HEAD   /api/file/tus/{id}   查询已接收的字节数
+// PATCH  /api/file/tus/{id}   从 Upload-Offset 处继续写入
+// DELETE /api/file/tus/{id}   终止上传并删除临时文件
+func TusUploadHandler(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserID(r)
+	if userID == 0 {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "不支持的 tus 协议版本", http.StatusPreconditionFailed)
+		return
+	}
+
+	// 部分环境不支持 PATCH/DELETE，允许用 POST + X-HTTP-Method-Override 代替
+	method := r.Method
+	if override := r.Header.Get("X-HTTP-Method-Override"); override != "" && method == http.MethodPost {
+		method = strings.ToUpper(override)
+	}
+
+	idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(tusBasePath, "/")), "/")
+	if idStr == "" {
+		if method != http.MethodPost {
+			http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+			return
+		}
+		createTusUpload(w, r, userID)
+		return
+	}
+
+	id := utils.NormalizeUUID(idStr)
+	if id == "" {
+		http.Error(w, "上传不存在", http.StatusNotFound)
+		return
+	}
+	switch method {
+	case http.MethodHead:
+		headTusUpload(w, id, userID)
+	case http.MethodPatch:
+		patchTusUpload(w, r, id, userID)
+	case http.MethodDelete:
+		deleteTusUpload(w, id, userID)
+	default:
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+	}
+}
+
+func createTusUpload(w http.ResponseWriter, r *http.Request, userID int) {
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		http.Error(w, "不支持延迟指定文件大小", http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "缺少或无效的 Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if length > services.TusMaxSize {
+		http.Error(w, "文件超过大小限制", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	id := utils.NewUUID()
+	metadata := r.Header.Get("Upload-Metadata")
+	meta := parseTusMetadata(metadata)
+
+	// 只保留文件名部分，防止路径穿越
+	fileName := filepath.Base(strings.ReplaceAll(meta["filename"], "\\", "/"))
+	if fileName == "." || fileName == "/" || fileName == "" {
+		fileName = "upload_" + id[:8]
+	}
+	fileType := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+	if fileType == "" {
+		fileType = "unknown"
+	}
+
+	if err := os.MkdirAll(tusPartDir, 0755); err != nil {
+		http.Error(w, "创建目录失败", http.StatusInternalServerError)
+		return
+	}
+	upload := &models.TusUpload{
+		ID:       id,
+		UserID:   userID,
+		FileName: fileName,
+		FileType: fileType,
+		Metadata: metadata,
+		Length:   length,
+		PartPath: filepath.Join(tusPartDir, id),
+	}
+	part, err := os.Create(upload.PartPath)
+	if err != nil {
+		http.Error(w, "创建文件失败", http.StatusInternalServerError)
+		return
+	}
+	part.Close()
+
+	if err := database.CreateTusUpload(upload, services.TusUploadTTL); err != nil {
+		os.Remove(upload.PartPath)
+		log.Printf("创建断点续传上传失败: %v", err)
+		http.Error(w, "保存记录失败", http.StatusInternalServerError)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	w.Header().Set("Location", scheme+"://"+r.Host+tusBasePath+id)
+
+	unlock := services.LockTusUpload(id)
+	defer unlock()
+
+	// creation-with-upload：创建请求中携带的第一段数据；空文件直接完成
+	if r.Header.Get("Content-Type") == tusChunkType || length == 0 {
+		r.Header.Set("Upload-Offset", "0")
+		if !writeTusChunk(w, r, upload) {
+			return
+		}
+	} else {
+		w.Header().Set("Upload-Expires", tusExpires(upload.ExpiresAt))
+	}
+	w.WriteHeader(http.StatusCreated)
+
+	log.Printf("创建断点续传上传: upload_id=%s, user_id=%d, file_name=%s, length=%d", id, userID, fileName, length)
+}
+
+func headTusUpload(w http.ResponseWriter, id string, userID int) {
+	upload, ok := getTusUpload(w, id, userID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.Metadata != "" {
+		w.Header().Set("Upload-Metadata", upload.Metadata)
+	}
+	if upload.FileID == 0 {
+		w.Header().Set("Upload-Expires", tusExpires(upload.ExpiresAt))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func patchTusUpload(w http.ResponseWriter, r *http.Request, id string, userID int) {
+	if r.Header.Get("Content-Type") != tusChunkType {
+		http.Error(w, "Content-Type 必须为 "+tusChunkType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// 同一上传同时只处理一个 PATCH（客户端断线重连时，旧请求可能还未结束）
+	unlock := services.LockTusUpload(id)
+	defer unlock()
+
+	upload, ok := getTusUpload(w, id, userID)
+	if !ok {
+		return
+	}
+	if !writeTusChunk(w, r, upload) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func deleteTusUpload(w http.ResponseWriter, id string, userID int) {
+	unlock := services.LockTusUpload(id)
+	defer unlock()
+
+	upload, err := database.GetTusUpload(id, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "上传不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 已完成的上传只删除记录，文件传输中的文件通过文件删除接口处理
+	if upload.PartPath != "" {
+		if err := os.Remove(upload.PartPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除上传临时文件失败: %s, %v", upload.PartPath, err)
+		}
+	}
+	if err := database.DeleteTusUpload(id); err != nil {
+		log.Printf("删除断点续传上传记录失败: %v", err)
+		http.Error(w, "删除失败", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Printf("终止断点续传上传: upload_id=%s, user_id=%d", id, userID)
+}
+
+// getTusUpload 获取用户的上传，不存在返回 404，未完成且已过期返回 410
+func getTusUpload(w http.ResponseWriter, id string, userID int) (*models.TusUpload, bool) {
+	upload, err := database.GetTusUpload(id, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "上传不存在", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return nil, false
+	}
+	if upload.FileID == 0 && upload.ExpiresAt <= utils.NowUTCString() {
+		http.Error(w, "上传已过期", http.StatusGone)
+		return nil, false
+	}
+	return upload, true
+}
+
+// writeTusChunk 校验 Upload-Offset 后把请求体追加到临时文件，收到全部数据时完成上传；
+// 连接中断时已写入的部分也会记录，客户端通过 HEAD 获取偏移量后继续。调用方需持有上传的锁，
+// 返回 false 时已写入错误响应
+func writeTusChunk(w http.ResponseWriter, r *http.Request, upload *models.TusUpload) bool {
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "缺少或无效的 Upload-Offset", http.StatusBadRequest)
+		return false
+	}
+	if offset != upload.Offset {
+		http.Error(w, "Upload-Offset 与已接收的字节数不一致", http.StatusConflict)
+		return false
+	}
+	remaining := upload.Length - upload.Offset
+	if r.ContentLength > remaining {
+		http.Error(w, "数据超过文件大小", http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	if remaining > 0 {
+		part, err := os.OpenFile(upload.PartPath, os.O_WRONLY, 0644)
+		if err != nil {
+			http.Error(w, "打开文件失败", http.StatusInternalServerError)
+			return false
+		}
+		// 丢弃上次中断时写入但未记录偏移量的数据
+		if err := part.Truncate(upload.Offset); err != nil {
+			part.Close()
+			http.Error(w, "写入文件失败", http.StatusInternalServerError)
+			return false
+		}
+		if _, err := part.Seek(upload.Offset, io.SeekStart); err != nil {
+			part.Close()
+			http.Error(w, "写入文件失败", http.StatusInternalServerError)
+			return false
+		}
+		n, copyErr := io.Copy(part, io.LimitReader(r.Body, remaining))
+		if err := part.Close(); err != nil && copyErr == nil {
+			copyErr = err
+		}
+
+		upload.Offset += n
+		expiresAt, err := database.UpdateTusUploadOffset(upload.ID, upload.Offset, services.TusUploadTTL)
+		if err != nil {
+			log.Printf("记录上传偏移量失败: upload_id=%s, error=%v", upload.ID, err)
+			http.Error(w, "保存进度失败", http.StatusInternalServerError)
+			return false
+		}
+		upload.ExpiresAt = expiresAt
+		if copyErr != nil {
+			log.Printf("接收上传数据中断: upload_id=%s, offset=%d, error=%v", upload.ID, upload.Offset, copyErr)
+			http.Error(w, "接收数据中断", http.StatusInternalServerError)
+			return false
+		}
+	}
+
+	if upload.Offset == upload.Length && upload.FileID == 0 {
+		transfer, err := services.FinishTusUpload(upload, uploadDir)
+		if err != nil {
+			log.Printf("完成断点续传上传失败: upload_id=%s, error=%v", upload.ID, err)
+			http.Error(w, "保存文件失败", http.StatusInternalServerError)
+			return false
+		}
+		upload.FileID = transfer.ID
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if upload.FileID == 0 {
+		w.Header().Set("Upload-Expires", tusExpires(upload.ExpiresAt))
+	}
+	return true
+}
+
+// parseTusMetadata 解析 Upload-Metadata：逗号分隔的 "键 base64值"，值可省略
+func parseTusMetadata(header string) map[string]string {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		meta[key] = string(decoded)
+	}
+	return meta
+}
+
+// tusExpires 把数据库中的 UTC 时间转换为 Upload-Expires 使用的 HTTP 日期格式
+func tusExpires(expiresAt string) string {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", expiresAt, time.UTC)
+	if err != nil {
+		return ""
+	}
+	return t.Format(http.TimeFormat)
+}
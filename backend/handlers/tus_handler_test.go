@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"backend/database"
+)
+
+// tusRequest 以指定用户身份（与 AuthMiddleware 一样设置 X-User-ID）调用 TusUploadHandler
+func tusRequest(method, target string, userID int, headers map[string]string, body io.Reader) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	r.Header.Set("X-User-ID", strconv.Itoa(userID))
+	r.Header.Set("Tus-Resumable", tusVersion)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	TusUploadHandler(w, r)
+	return w
+}
+
+// createTestTusUpload 创建一个不带数据的上传，返回上传地址
+func createTestTusUpload(t *testing.T, userID int, length int) string {
+	t.Helper()
+	w := tusRequest(http.MethodPost, tusBasePath, userID, map[string]string{
+		"Upload-Length":   strconv.Itoa(length),
+		"Upload-Metadata": "filename aGVsbG8udHh0", // hello.txt
+	}, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建上传: %d %s", w.Code, w.Body.String())
+	}
+	return tusBasePath + path.Base(w.Header().Get("Location"))
+}
+
+func patchTusChunk(target string, userID int, offset int, body io.Reader) *httptest.ResponseRecorder {
+	return tusRequest(http.MethodPatch, target, userID, map[string]string{
+		"Content-Type":  tusChunkType,
+		"Upload-Offset": strconv.Itoa(offset),
+	}, body)
+}
+
+func headTusOffset(t *testing.T, target string, userID int) string {
+	t.Helper()
+	w := tusRequest(http.MethodHead, target, userID, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("HEAD: %d %s", w.Code, w.Body.String())
+	}
+	return w.Header().Get("Upload-Offset")
+}
+
+func tusUploadID(target string) string {
+	return path.Base(target)
+}
+
+// brokenReader 先返回数据，然后模拟连接中断
+type brokenReader struct {
+	data string
+	done bool
+}
+
+func (b *brokenReader) Read(p []byte) (int, error) {
+	if b.done {
+		return 0, errors.New("connection reset")
+	}
+	b.done = true
+	return copy(p, b.data), nil
+}
+
+func TestTusUploadHandler(t *testing.T) {
+	setupTestDB(t)
+
+	t.Run("偏移量不一致返回409", func(t *testing.T) {
+		target := createTestTusUpload(t, 1, 10)
+		if w := patchTusChunk(target, 1, 5, strings.NewReader("hello")); w.Code != http.StatusConflict {
+			t.Errorf("状态码 = %d, want 409", w.Code)
+		}
+	})
+
+	t.Run("数据超过文件大小返回413", func(t *testing.T) {
+		target := createTestTusUpload(t, 1, 4)
+		if w := patchTusChunk(target, 1, 0, strings.NewReader("hello")); w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("状态码 = %d, want 413", w.Code)
+		}
+		if got := headTusOffset(t, target, 1); got != "0" {
+			t.Errorf("拒绝后 Upload-Offset = %s, want 0", got)
+		}
+	})
+
+	t.Run("中断后HEAD返回已保存的偏移量并可继续", func(t *testing.T) {
+		target := createTestTusUpload(t, 1, 10)
+		if w := patchTusChunk(target, 1, 0, &brokenReader{data: "hel"}); w.Code != http.StatusInternalServerError {
+			t.Errorf("中断的 PATCH 状态码 = %d, want 500", w.Code)
+		}
+		if got := headTusOffset(t, target, 1); got != "3" {
+			t.Fatalf("中断后 Upload-Offset = %s, want 3", got)
+		}
+
+		w := patchTusChunk(target, 1, 3, strings.NewReader("lo worl"))
+		if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "10" {
+			t.Fatalf("继续上传: %d, Upload-Offset=%s", w.Code, w.Header().Get("Upload-Offset"))
+		}
+		upload, err := database.GetTusUpload(tusUploadID(target), 1)
+		if err != nil || upload.FileID == 0 {
+			t.Fatalf("上传应已完成: %+v, %v", upload, err)
+		}
+		transfer, err := database.GetFileTransferByID(upload.FileID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content, _ := os.ReadFile(transfer.FilePath); string(content) != "hello worl" {
+			t.Errorf("文件内容 = %q, want %q", content, "hello worl")
+		}
+	})
+
+	t.Run("过期的上传返回410", func(t *testing.T) {
+		target := createTestTusUpload(t, 1, 10)
+		if _, err := database.DB.Exec("UPDATE tus_uploads SET expires_at = '2000-01-01 00:00:00' WHERE id = ?", tusUploadID(target)); err != nil {
+			t.Fatal(err)
+		}
+		if w := tusRequest(http.MethodHead, target, 1, nil, nil); w.Code != http.StatusGone {
+			t.Errorf("HEAD 状态码 = %d, want 410", w.Code)
+		}
+		if w := patchTusChunk(target, 1, 0, strings.NewReader("hello")); w.Code != http.StatusGone {
+			t.Errorf("PATCH 状态码 = %d, want 410", w.Code)
+		}
+	})
+
+	t.Run("DELETE终止上传并删除临时文件", func(t *testing.T) {
+		target := createTestTusUpload(t, 1, 10)
+		patchTusChunk(target, 1, 0, strings.NewReader("hello"))
+		upload, err := database.GetTusUpload(tusUploadID(target), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if w := tusRequest(http.MethodDelete, target, 1, nil, nil); w.Code != http.StatusNoContent {
+			t.Fatalf("DELETE 状态码 = %d, want 204", w.Code)
+		}
+		if _, err := os.Stat(upload.PartPath); !os.IsNotExist(err) {
+			t.Errorf("临时文件应已删除: %v", err)
+		}
+		if w := tusRequest(http.MethodHead, target, 1, nil, nil); w.Code != http.StatusNotFound {
+			t.Errorf("终止后 HEAD 状态码 = %d, want 404", w.Code)
+		}
+	})
+
+	t.Run("其他用户的上传返回404", func(t *testing.T) {
+		target := createTestTusUpload(t, 1, 10)
+		if w := tusRequest(http.MethodHead, target, 2, nil, nil); w.Code != http.StatusNotFound {
+			t.Errorf("HEAD 状态码 = %d, want 404", w.Code)
+		}
+		if w := patchTusChunk(target, 2, 0, strings.NewReader("hello")); w.Code != http.StatusNotFound {
+			t.Errorf("PATCH 状态码 = %d, want 404", w.Code)
+		}
+		if w := tusRequest(http.MethodDelete, target, 2, nil, nil); w.Code != http.StatusNotFound {
+			t.Errorf("DELETE 状态码 = %d, want 404", w.Code)
+		}
+		if got := headTusOffset(t, target, 1); got != "0" {
+			t.Errorf("上传者的 Upload-Offset = %s, want 0", got)
+		}
+	})
+
+	t.Run("创建时携带全部数据直接完成", func(t *testing.T) {
+		w := tusRequest(http.MethodPost, tusBasePath, 1, map[string]string{
+			"Upload-Length":   "5",
+			"Upload-Metadata": "filename aGVsbG8udHh0",
+			"Content-Type":    tusChunkType,
+		}, strings.NewReader("hello"))
+		if w.Code != http.StatusCreated || w.Header().Get("Upload-Offset") != "5" {
+			t.Fatalf("创建: %d, Upload-Offset=%s, %s", w.Code, w.Header().Get("Upload-Offset"), w.Body.String())
+		}
+		upload, err := database.GetTusUpload(path.Base(w.Header().Get("Location")), 1)
+		if err != nil || upload.FileID == 0 {
+			t.Fatalf("上传应已完成: %+v, %v", upload, err)
+		}
+		transfer, err := database.GetFileTransferByID(upload.FileID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if transfer.FileName != "hello.txt" {
+			t.Errorf("文件名 = %s, want hello.txt", transfer.FileName)
+		}
+		if content, _ := os.ReadFile(transfer.FilePath); string(content) != "hello" {
+			t.Errorf("文件内容 = %q, want hello", content)
+		}
+	})
+}
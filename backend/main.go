@@ -6,11 +6,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"backend/database"
 	"backend/handlers"
+	"backend/models"
+	"backend/services"
 	"backend/utils"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -38,19 +41,6 @@ type AuthResponse struct {
 	User    *User  `json:"user,omitempty"`
 }
 
-// 健康活动记录
-type HealthActivity struct {
-	ID         int    `json:"id"`
-	UserID     int    `json:"user_id"`
-	RecordDate string `json:"record_date"` // 格式: YYYY-MM-DD
-	RecordTime string `json:"record_time"` // 格式: HH:mm
-	WeekDay    string `json:"week_day"`    // 星期几
-	Duration   int    `json:"duration"`    // 持续时间（分钟）
-	Remark     string `json:"remark"`      // 备注
-	Tag        string `json:"tag"`         // 标签: auto=自动, manual=手动
-	CreatedAt  string `json:"created_at"`
-}
-
 type CreateActivityRequest struct {
 	RecordDate string `json:"record_date"`
 	RecordTime string `json:"record_time"`
@@ -60,34 +50,11 @@ type CreateActivityRequest struct {
 }
 
 type ActivityResponse struct {
-	Success bool             `json:"success"`
-	Message string           `json:"message"`
-	Data    *HealthActivity  `json:"data,omitempty"`
-	List    []HealthActivity `json:"list,omitempty"`
-	Stats   *ActivityStats   `json:"stats,omitempty"`
-}
-
-type ActivityStats struct {
-	TotalAuto              int      `json:"total_auto"`               // 总计自动次数
-	TotalManual            int      `json:"total_manual"`             // 总计手动次数
-	YearAuto               int      `json:"year_auto"`                // 今年自动次数
-	YearManual             int      `json:"year_manual"`              // 今年手动次数
-	MonthAuto              int      `json:"month_auto"`               // 本月自动次数
-	MonthManual            int      `json:"month_manual"`             // 本月手动次数
-	AutoFrequencyPerDay    float64  `json:"auto_frequency_per_day"`   // 自动频率（次/天）
-	ManualFrequencyPerDay  float64  `json:"manual_frequency_per_day"` // 手动频率（次/天）
-	TotalFrequencyPerDay   float64  `json:"total_frequency_per_day"`  // 总频率（次/天）
-	AutoPeriodDays         float64  `json:"auto_period_days"`         // 自动周期（天/次）
-	ManualPeriodDays       float64  `json:"manual_period_days"`       // 手动周期（天/次）
-	TotalPeriodDays        float64  `json:"total_period_days"`        // 总周期（天/次）
-	EarliestDate           string   `json:"earliest_date"`            // 最早记录日期
-	LastTwoInterval        int      `json:"last_two_interval"`        // 最后两次间隔天数，-1表示不足2条
-	LastTwoAutoInterval    int      `json:"last_two_auto_interval"`   // 最后两个自动间隔天数
-	LastTwoManualInterval  int      `json:"last_two_manual_interval"` // 最后两个手动间隔天数
-	LastIntervalDays       *float64 `json:"last_interval_days,omitempty"`        // 最后两次间隔天数（小数）
-	LastAutoIntervalDays   *float64 `json:"last_auto_interval_days,omitempty"`   // 最后两次自动间隔天数（小数）
-	LastManualIntervalDays *float64 `json:"last_manual_interval_days,omitempty"` // 最后两次手动间隔天数（小数）
-	LastToNowDays          *float64 `json:"last_to_now_days,omitempty"`          // 最后一次距今天数（小数）
+	Success bool                    `json:"success"`
+	Message string                  `json:"message"`
+	Data    *models.HealthActivity  `json:"data,omitempty"`
+	List    []models.HealthActivity `json:"list,omitempty"`
+	Stats   *models.ActivityStats   `json:"stats,omitempty"`
 }
 
 func initDB() {
@@ -265,16 +232,6 @@ func profileHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// 获取星期几
-func getWeekDay(dateStr string) string {
-	t, err := time.Parse("2006-01-02", dateStr)
-	if err != nil {
-		return ""
-	}
-	weekdays := []string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
-	return weekdays[t.Weekday()]
-}
-
 // 创建健康活动记录
 func createActivityHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -311,7 +268,7 @@ func createActivityHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	weekDay := getWeekDay(req.RecordDate)
+	weekDay := utils.WeekDay(req.RecordDate)
 	if weekDay == "" {
 		json.NewEncoder(w).Encode(ActivityResponse{
 			Success: false,
@@ -329,22 +286,8 @@ func createActivityHandler(w http.ResponseWriter, r *http.Request) {
 	var userIDInt int
 	fmt.Sscanf(userID, "%d", &userIDInt)
 
-	// 插入记录，存储 UTC 时间
-	createdAtUTC := utils.NowUTCString()
-	result, err := database.DB.Exec(
-		"INSERT INTO health_activities (user_id, record_date, record_time, week_day, duration, remark, tag, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		userIDInt, req.RecordDate, req.RecordTime, weekDay, req.Duration, req.Remark, tag, createdAtUTC,
-	)
-	if err != nil {
-		http.Error(w, "创建记录失败", http.StatusInternalServerError)
-		return
-	}
-
-	activityID, _ := result.LastInsertId()
-
-	// 显示时转换为东八区时间
-	activity := HealthActivity{
-		ID:         int(activityID),
+	// 插入记录，存储 UTC 时间，返回时转换为东八区
+	activity := models.HealthActivity{
 		UserID:     userIDInt,
 		RecordDate: req.RecordDate,
 		RecordTime: req.RecordTime,
@@ -352,8 +295,12 @@ func createActivityHandler(w http.ResponseWriter, r *http.Request) {
 		Duration:   req.Duration,
 		Remark:     req.Remark,
 		Tag:        tag,
-		CreatedAt:  utils.UTCToShanghai(createdAtUTC), // 转换为东八区显示
 	}
+	if err := database.CreateActivity(&activity); err != nil {
+		http.Error(w, "创建记录失败", http.StatusInternalServerError)
+		return
+	}
+	services.InvalidateActivityStats(userIDInt)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(ActivityResponse{
@@ -379,40 +326,11 @@ func listActivitiesHandler(w http.ResponseWriter, r *http.Request) {
 	var userIDInt int
 	fmt.Sscanf(userID, "%d", &userIDInt)
 
-	rows, err := database.DB.Query(
-		"SELECT id, user_id, record_date, record_time, week_day, duration, remark, COALESCE(tag, 'manual'), created_at FROM health_activities WHERE user_id = ? ORDER BY record_date DESC, record_time DESC LIMIT 5",
-		userIDInt,
-	)
+	activities, err := database.GetUserActivities(userIDInt, 5)
 	if err != nil {
 		http.Error(w, "查询失败", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var activities []HealthActivity
-	for rows.Next() {
-		var activity HealthActivity
-		var createdAt string
-		err := rows.Scan(
-			&activity.ID,
-			&activity.UserID,
-			&activity.RecordDate,
-			&activity.RecordTime,
-			&activity.WeekDay,
-			&activity.Duration,
-			&activity.Remark,
-			&activity.Tag,
-			&createdAt,
-		)
-		if err != nil {
-			continue
-		}
-
-		// 处理 created_at 时间：数据库存储的是 UTC，显示时转换为东八区
-		activity.CreatedAt = utils.UTCToShanghai(createdAt)
-
-		activities = append(activities, activity)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(ActivityResponse{
@@ -449,8 +367,7 @@ func deleteActivityHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Sscanf(userID, "%d", &userIDInt)
 
 	// 验证记录是否属于当前用户
-	var ownerID int
-	err := database.DB.QueryRow("SELECT user_id FROM health_activities WHERE id = ?", activityID).Scan(&ownerID)
+	ownerID, err := database.GetActivityOwner(activityID)
 	if err != nil {
 		json.NewEncoder(w).Encode(ActivityResponse{
 			Success: false,
@@ -464,12 +381,12 @@ func deleteActivityHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 删除记录
-	_, err = database.DB.Exec("DELETE FROM health_activities WHERE id = ?", activityID)
-	if err != nil {
+	// 删除记录（留下墓碑供离线客户端同步）
+	if err := database.DeleteActivity(activityID, userIDInt); err != nil {
 		http.Error(w, "删除失败", http.StatusInternalServerError)
 		return
 	}
+	services.InvalidateActivityStats(userIDInt)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(ActivityResponse{
@@ -478,174 +395,6 @@ func deleteActivityHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// calcLastTwoIntervalDays 计算最后两条记录的间隔天数，tag 为空查全部，auto/manual 按标签过滤，不足2条返回-1
-func calcLastTwoIntervalDays(userID int, tagFilter string) int {
-	query := "SELECT record_date FROM health_activities WHERE user_id = ?"
-	args := []interface{}{userID}
-	if tagFilter == "auto" {
-		query += " AND tag = 'auto'"
-	} else if tagFilter == "manual" {
-		query += " AND (COALESCE(tag, 'manual') = 'manual')"
-	}
-	query += " ORDER BY record_date DESC, record_time DESC LIMIT 2"
-
-	rows, err := database.DB.Query(query, args...)
-	if err != nil {
-		return -1
-	}
-	defer rows.Close()
-
-	var dates []string
-	for rows.Next() {
-		var d string
-		if err := rows.Scan(&d); err != nil {
-			return -1
-		}
-		dates = append(dates, d)
-	}
-	if len(dates) < 2 {
-		return -1
-	}
-
-	t1, err1 := time.Parse("2006-01-02", dates[0])
-	t2, err2 := time.Parse("2006-01-02", dates[1])
-	if err1 != nil || err2 != nil {
-		return -1
-	}
-	days := int(t1.Sub(t2).Hours() / 24)
-	if days < 0 {
-		days = -days
-	}
-	return days
-}
-
-func roundToOneDecimal(v float64) float64 {
-	return float64(int(v*10+0.5)) / 10
-}
-
-func parseRecordDateTime(date, t string) (time.Time, error) {
-	if len(t) == 5 {
-		t += ":00"
-	}
-	return time.ParseInLocation("2006-01-02 15:04:05", date+" "+t, time.Local)
-}
-
-func calcRangeDays(userID int, tagFilter string) float64 {
-	query := "SELECT record_date, record_time FROM health_activities WHERE user_id = ?"
-	args := []interface{}{userID}
-	if tagFilter == "auto" {
-		query += " AND tag = 'auto'"
-	} else if tagFilter == "manual" {
-		query += " AND (COALESCE(tag, 'manual') = 'manual')"
-	}
-
-	var minDate, minTime string
-	if err := database.DB.QueryRow(query+" ORDER BY record_date ASC, record_time ASC LIMIT 1", args...).Scan(&minDate, &minTime); err != nil {
-		return 1
-	}
-	var maxDate, maxTime string
-	if err := database.DB.QueryRow(query+" ORDER BY record_date DESC, record_time DESC LIMIT 1", args...).Scan(&maxDate, &maxTime); err != nil {
-		return 1
-	}
-	minDT, err1 := parseRecordDateTime(minDate, minTime)
-	maxDT, err2 := parseRecordDateTime(maxDate, maxTime)
-	if err1 != nil || err2 != nil {
-		return 1
-	}
-	spanDays := maxDT.Sub(minDT).Hours() / 24.0
-	if spanDays <= 0 {
-		spanDays = 1
-	}
-	return spanDays
-}
-
-func calcFrequencyPerDayByRange(total int, spanDays float64) float64 {
-	if total <= 0 {
-		return 0
-	}
-	if spanDays <= 0 {
-		spanDays = 1
-	}
-	return roundToOneDecimal(float64(total) / spanDays)
-}
-
-func calcPeriodDaysByRange(total int, spanDays float64) float64 {
-	if total <= 0 {
-		return 0
-	}
-	if spanDays <= 0 {
-		spanDays = 1
-	}
-	return roundToOneDecimal(spanDays / float64(total))
-}
-
-// calcLastTwoIntervalDaysFloat 计算最后两条记录的间隔天数（小数），不足2条返回 nil
-func calcLastTwoIntervalDaysFloat(userID int, tagFilter string) *float64 {
-	query := "SELECT record_date, record_time FROM health_activities WHERE user_id = ?"
-	args := []interface{}{userID}
-	if tagFilter == "auto" {
-		query += " AND tag = 'auto'"
-	} else if tagFilter == "manual" {
-		query += " AND (COALESCE(tag, 'manual') = 'manual')"
-	}
-	query += " ORDER BY record_date DESC, record_time DESC LIMIT 2"
-
-	rows, err := database.DB.Query(query, args...)
-	if err != nil {
-		return nil
-	}
-	defer rows.Close()
-
-	type pair struct {
-		date string
-		t    string
-	}
-	var items []pair
-	for rows.Next() {
-		var p pair
-		if err := rows.Scan(&p.date, &p.t); err != nil {
-			return nil
-		}
-		items = append(items, p)
-	}
-	if len(items) < 2 {
-		return nil
-	}
-	t1, err1 := parseRecordDateTime(items[0].date, items[0].t)
-	t2, err2 := parseRecordDateTime(items[1].date, items[1].t)
-	if err1 != nil || err2 != nil {
-		return nil
-	}
-	days := t1.Sub(t2).Hours() / 24.0
-	if days < 0 {
-		days = -days
-	}
-	v := roundToOneDecimal(days)
-	return &v
-}
-
-// calcLastToNowDaysFloat 计算最后一次距今的天数（小数），无记录返回 nil
-func calcLastToNowDaysFloat(userID int) *float64 {
-	var date, t string
-	err := database.DB.QueryRow(
-		"SELECT record_date, record_time FROM health_activities WHERE user_id = ? ORDER BY record_date DESC, record_time DESC LIMIT 1",
-		userID,
-	).Scan(&date, &t)
-	if err != nil {
-		return nil
-	}
-	last, err := parseRecordDateTime(date, t)
-	if err != nil {
-		return nil
-	}
-	days := time.Since(last).Hours() / 24.0
-	if days < 0 {
-		return nil
-	}
-	v := roundToOneDecimal(days)
-	return &v
-}
-
 // 获取健康活动统计
 func getActivityStatsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -662,111 +411,32 @@ func getActivityStatsHandler(w http.ResponseWriter, r *http.Request) {
 	var userIDInt int
 	fmt.Sscanf(userID, "%d", &userIDInt)
 
-	now := utils.Now()
-	currentYear := now.Format("2006")
-	currentMonth := now.Format("2006-01")
-
-	// 查询总计（自动/手动，旧数据 NULL 视为手动）
-	var totalAuto, totalManual int
-	database.DB.QueryRow(
-		"SELECT COUNT(*) FROM health_activities WHERE user_id = ? AND tag = 'auto'",
-		userIDInt,
-	).Scan(&totalAuto)
-	database.DB.QueryRow(
-		"SELECT COUNT(*) FROM health_activities WHERE user_id = ? AND (COALESCE(tag, 'manual') = 'manual')",
-		userIDInt,
-	).Scan(&totalManual)
-
-	// 查询最早记录日期
-	var earliestDate string
-	database.DB.QueryRow(
-		"SELECT MIN(record_date) FROM health_activities WHERE user_id = ?",
-		userIDInt,
-	).Scan(&earliestDate)
-
-	// 查询今年（自动/手动）
-	var yearAuto, yearManual int
-	database.DB.QueryRow(
-		"SELECT COUNT(*) FROM health_activities WHERE user_id = ? AND record_date LIKE ? AND tag = 'auto'",
-		userIDInt, currentYear+"%",
-	).Scan(&yearAuto)
-	database.DB.QueryRow(
-		"SELECT COUNT(*) FROM health_activities WHERE user_id = ? AND record_date LIKE ? AND (COALESCE(tag, 'manual') = 'manual')",
-		userIDInt, currentYear+"%",
-	).Scan(&yearManual)
-
-	// 查询本月（自动/手动）
-	var monthAuto, monthManual int
-	database.DB.QueryRow(
-		"SELECT COUNT(*) FROM health_activities WHERE user_id = ? AND record_date LIKE ? AND tag = 'auto'",
-		userIDInt, currentMonth+"%",
-	).Scan(&monthAuto)
-	database.DB.QueryRow(
-		"SELECT COUNT(*) FROM health_activities WHERE user_id = ? AND record_date LIKE ? AND (COALESCE(tag, 'manual') = 'manual')",
-		userIDInt, currentMonth+"%",
-	).Scan(&monthManual)
-
-	// 最后两次间隔天数（全部、自动、手动），-1 表示不足2条
-	lastTwoInterval := calcLastTwoIntervalDays(userIDInt, "")
-	lastTwoAutoInterval := calcLastTwoIntervalDays(userIDInt, "auto")
-	lastTwoManualInterval := calcLastTwoIntervalDays(userIDInt, "manual")
-
-	// 精确小数间隔 & 距今
-	lastIntervalDays := calcLastTwoIntervalDaysFloat(userIDInt, "")
-	lastAutoIntervalDays := calcLastTwoIntervalDaysFloat(userIDInt, "auto")
-	lastManualIntervalDays := calcLastTwoIntervalDaysFloat(userIDInt, "manual")
-	lastToNowDays := calcLastToNowDaysFloat(userIDInt)
-
-	// 周期/频率统计
-	totalRangeDays := calcRangeDays(userIDInt, "")
-	autoRangeDays := calcRangeDays(userIDInt, "auto")
-	manualRangeDays := calcRangeDays(userIDInt, "manual")
-	autoFrequencyPerDay := calcFrequencyPerDayByRange(totalAuto, autoRangeDays)
-	manualFrequencyPerDay := calcFrequencyPerDayByRange(totalManual, manualRangeDays)
-	totalFrequencyPerDay := calcFrequencyPerDayByRange(totalAuto+totalManual, totalRangeDays)
-	autoPeriodDays := calcPeriodDaysByRange(totalAuto, autoRangeDays)
-	manualPeriodDays := calcPeriodDaysByRange(totalManual, manualRangeDays)
-	totalPeriodDays := calcPeriodDaysByRange(totalAuto+totalManual, totalRangeDays)
-
-	stats := ActivityStats{
-		TotalAuto:              totalAuto,
-		TotalManual:            totalManual,
-		YearAuto:               yearAuto,
-		YearManual:             yearManual,
-		MonthAuto:              monthAuto,
-		MonthManual:            monthManual,
-		AutoFrequencyPerDay:    autoFrequencyPerDay,
-		ManualFrequencyPerDay:  manualFrequencyPerDay,
-		TotalFrequencyPerDay:   totalFrequencyPerDay,
-		AutoPeriodDays:         autoPeriodDays,
-		ManualPeriodDays:       manualPeriodDays,
-		TotalPeriodDays:        totalPeriodDays,
-		EarliestDate:           earliestDate,
-		LastTwoInterval:        lastTwoInterval,
-		LastTwoAutoInterval:    lastTwoAutoInterval,
-		LastTwoManualInterval:  lastTwoManualInterval,
-		LastIntervalDays:       lastIntervalDays,
-		LastAutoIntervalDays:   lastAutoIntervalDays,
-		LastManualIntervalDays: lastManualIntervalDays,
-		LastToNowDays:          lastToNowDays,
+	stats, err := services.GetActivityStats(userIDInt)
+	if err != nil {
+		log.Printf("统计健康活动失败: %v", err)
+		http.Error(w, "查询失败", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(ActivityResponse{
 		Success: true,
 		Message: "获取成功",
-		Stats:   &stats,
+		Stats:   stats,
 	})
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range")
-		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges")
-
-		if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, HEAD, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range, "+
+			"Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata, Upload-Defer-Length, X-HTTP-Method-Override")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges, Location, "+
+			"Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Expires, Upload-Metadata")
+
+		// tus 的 OPTIONS 是协议探测，需要由处理器返回支持的版本和扩展
+		if r.Method == http.MethodOptions && !strings.HasPrefix(r.URL.Path, "/api/file/tus") {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
@@ -779,6 +449,81 @@ func main() {
 	initDB()
 	defer database.CloseDB()
 
+	// 回收站保留天数（默认30天），过期条目由后台定时永久删除
+	retentionDays, _ := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if retentionDays <= 0 {
+		retentionDays = 30
+	}
+	services.StartTrashPurger(time.Duration(retentionDays)*24*time.Hour, time.Hour)
+
+	// 断点续传上传：未完成的上传在最后一次接收数据后保留 TUS_UPLOAD_TTL（默认24h），单个文件上限 TUS_MAX_SIZE 字节（默认20GB）
+	if v := os.Getenv("TUS_UPLOAD_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			log.Fatal("TUS_UPLOAD_TTL 配置错误:", v)
+		}
+		services.TusUploadTTL = ttl
+	}
+	if v := os.Getenv("TUS_MAX_SIZE"); v != "" {
+		maxSize, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || maxSize <= 0 {
+			log.Fatal("TUS_MAX_SIZE 配置错误:", v)
+		}
+		services.TusMaxSize = maxSize
+	}
+	services.StartTusUploadCleaner(min(services.TusUploadTTL, time.Hour))
+
+	// 月报邮件：SMTP_HOST/SMTP_PORT/SMTP_USERNAME/SMTP_PASSWORD/SMTP_FROM，每小时检查一次待发送的上月月报
+	services.SMTP = services.LoadSMTPConfig()
+	services.StartActivityReportMailer(handlers.RenderActivityReport, time.Hour)
+
+	// 抖音下载 worker 数（默认2）
+	douyinWorkers, _ := strconv.Atoi(os.Getenv("DOUYIN_WORKERS"))
+	if douyinWorkers <= 0 {
+		douyinWorkers = 2
+	}
+	if err := services.ConfigureDownloaders(); err != nil {
+		log.Fatal("下载器配置错误:", err)
+	}
+	// 单个下载任务超时（默认10分钟，如 DOUYIN_DOWNLOAD_TIMEOUT=30m）
+	if v := os.Getenv("DOUYIN_DOWNLOAD_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			log.Fatal("DOUYIN_DOWNLOAD_TIMEOUT 配置错误:", v)
+		}
+		services.DownloadTimeout = timeout
+	}
+	// 临时错误自动重试次数（默认3，0 表示不重试）和首次重试等待时间（默认30s，之后每次翻倍）
+	if v := os.Getenv("DOUYIN_RETRY_MAX"); v != "" {
+		retryMax, err := strconv.Atoi(v)
+		if err != nil || retryMax < 0 {
+			log.Fatal("DOUYIN_RETRY_MAX 配置错误:", v)
+		}
+		services.DouyinRetryMax = retryMax
+	}
+	if v := os.Getenv("DOUYIN_RETRY_DELAY"); v != "" {
+		delay, err := time.ParseDuration(v)
+		if err != nil || delay <= 0 {
+			log.Fatal("DOUYIN_RETRY_DELAY 配置错误:", v)
+		}
+		services.DouyinRetryBaseDelay = delay
+	}
+	services.StartDouyinWorkers(douyinWorkers)
+
+	// 创作者订阅检查间隔（默认1小时，如 DOUYIN_SUBSCRIPTION_INTERVAL=30m）
+	if err := services.ConfigureProfileLister(); err != nil {
+		log.Fatal("作品列表配置错误:", err)
+	}
+	subscriptionInterval := time.Hour
+	if v := os.Getenv("DOUYIN_SUBSCRIPTION_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			log.Fatal("DOUYIN_SUBSCRIPTION_INTERVAL 配置错误:", v)
+		}
+		subscriptionInterval = interval
+	}
+	services.StartDouyinSubscriptionScheduler(subscriptionInterval)
+
 	mux := http.NewServeMux()
 
 	// 公开路由
@@ -789,6 +534,24 @@ func main() {
 	mux.HandleFunc("/api/profile", authMiddleware(profileHandler))
 	// 注意：更具体的路径要先注册
 	mux.HandleFunc("/api/activities/stats", authMiddleware(getActivityStatsHandler))
+	mux.HandleFunc("/api/activities/predict", authMiddleware(handlers.ActivityPredictionHandler))
+	mux.HandleFunc("/api/activities/sync", authMiddleware(handlers.ActivitySyncHandler))
+	mux.HandleFunc("/api/activities/search", authMiddleware(handlers.ActivitySearchHandler))
+	mux.HandleFunc("/api/activities/heatmap", authMiddleware(handlers.ActivityHeatmapHandler))
+	mux.HandleFunc("/api/activities/report", authMiddleware(handlers.ActivityReportHandler))
+	mux.HandleFunc("/api/activities/report/subscription", authMiddleware(handlers.ActivityReportSubscriptionHandler))
+	mux.HandleFunc("/api/activities/report/send", authMiddleware(handlers.ActivityReportSendHandler))
+	mux.HandleFunc("/api/activities/series", authMiddleware(handlers.ActivitySeriesHandler))
+	mux.HandleFunc("/api/activities/compare", authMiddleware(handlers.ActivityCompareHandler))
+	mux.HandleFunc("/api/activities/bulk/delete", authMiddleware(handlers.ActivityBulkDeleteHandler))
+	mux.HandleFunc("/api/activities/bulk/retag", authMiddleware(handlers.ActivityBulkRetagHandler))
+	mux.HandleFunc("/api/activities/bulk/shift", authMiddleware(handlers.ActivityBulkShiftHandler))
+	mux.HandleFunc("/api/activities/share/create", authMiddleware(handlers.CreateActivityShareHandler))
+	mux.HandleFunc("/api/activities/share/list", authMiddleware(handlers.ListActivitySharesHandler))
+	mux.HandleFunc("/api/activities/share/received", authMiddleware(handlers.ListReceivedActivitySharesHandler))
+	mux.HandleFunc("/api/activities/share/revoke", authMiddleware(handlers.RevokeActivityShareHandler))
+	mux.HandleFunc("/api/activities/share/logs", authMiddleware(handlers.ActivityShareLogsHandler))
+	mux.HandleFunc("/api/activities/shared/", authMiddleware(handlers.SharedActivityHandler))
 	mux.HandleFunc("/api/activities/", authMiddleware(deleteActivityHandler))
 	mux.HandleFunc("/api/activities", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
@@ -803,6 +566,21 @@ func main() {
 	mux.HandleFunc("/api/douyin/parsing", authMiddleware(handlers.DouyinParsingHandler))
 	mux.HandleFunc("/api/douyin/files", authMiddleware(handlers.DouyinFileListHandler))
 	mux.HandleFunc("/api/douyin/download", authMiddleware(handlers.DouyinDownloadHandler))
+	mux.HandleFunc("/api/douyin/delete", authMiddleware(handlers.DouyinFileDeleteHandler))
+	mux.HandleFunc("/api/douyin/stream-url", authMiddleware(handlers.DouyinStreamURLHandler))
+	// stream 路由不使用 authMiddleware，通过签名URL或 Authorization 头鉴权
+	mux.HandleFunc("/api/douyin/stream", handlers.DouyinStreamHandler)
+	mux.HandleFunc("/api/douyin/jobs", authMiddleware(handlers.DouyinJobHandler))
+	mux.HandleFunc("/api/douyin/jobs/", authMiddleware(handlers.DouyinJobHandler))
+	mux.HandleFunc("/api/douyin/batches/", authMiddleware(handlers.DouyinBatchHandler))
+	mux.HandleFunc("/api/douyin/subscriptions", authMiddleware(handlers.DouyinSubscriptionHandler))
+	mux.HandleFunc("/api/douyin/subscriptions/", authMiddleware(handlers.DouyinSubscriptionHandler))
+	mux.HandleFunc("/api/douyin/failed", authMiddleware(handlers.DouyinFailedURLListHandler))
+	mux.HandleFunc("/api/douyin/failed/retry", authMiddleware(handlers.DouyinRetryHandler))
+	mux.HandleFunc("/api/douyin/promote", authMiddleware(handlers.DouyinPromoteHandler))
+	mux.HandleFunc("/api/douyin/share/create", authMiddleware(handlers.CreateDouyinShareHandler))
+	mux.HandleFunc("/api/douyin/share/list", authMiddleware(handlers.ListDouyinSharesHandler))
+	mux.HandleFunc("/api/douyin/share/revoke", authMiddleware(handlers.RevokeDouyinShareHandler))
 
 	// 文件传输相关路由
 	mux.HandleFunc("/api/file/upload", authMiddleware(handlers.FileUploadHandler))
@@ -811,8 +589,20 @@ func main() {
 	mux.HandleFunc("/api/file/download", authMiddleware(handlers.FileDownloadHandler))
 	mux.HandleFunc("/api/file/share", authMiddleware(handlers.FileShareHandler))
 	mux.HandleFunc("/api/file/clipboard", authMiddleware(handlers.SaveClipboardHandler))
+	// 断点续传上传（tus 1.0），OPTIONS 协议探测不需要登录
+	tusHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			handlers.TusOptionsHandler(w, r)
+			return
+		}
+		authMiddleware(handlers.TusUploadHandler)(w, r)
+	}
+	mux.HandleFunc("/api/file/tus", tusHandler)
+	mux.HandleFunc("/api/file/tus/", tusHandler)
 	// 文件公开下载（无需鉴权，通过分享 token）
 	mux.HandleFunc("/api/public/file/", handlers.PublicFileDownloadHandler)
+	mux.HandleFunc("/api/public/activities/", handlers.PublicSharedActivityHandler)
+	mux.HandleFunc("/api/public/douyin/", handlers.PublicDouyinShareHandler)
 
 	// 音乐播放器相关路由
 	mux.HandleFunc("/api/music/upload", authMiddleware(handlers.MusicUploadHandler))
@@ -830,6 +620,7 @@ func main() {
 	mux.HandleFunc("/api/music/share/stream", handlers.StreamSharedMusicHandler)
 	// Web 播放页面路由（浏览器直接访问）
 	mux.HandleFunc("/share/", handlers.ShareWebPlayerHandler)
+	mux.HandleFunc("/share/douyin/", handlers.DouyinSharePageHandler)
 
 	// 歌词相关路由
 	mux.HandleFunc("/api/lyrics/upload", authMiddleware(handlers.LyricsUploadHandler))
@@ -839,6 +630,11 @@ func main() {
 	mux.HandleFunc("/api/lyrics/get", handlers.LyricsGetByMusicIDHandler)              // 公开访问，支持分享页面
 	mux.HandleFunc("/api/lyrics/delete", authMiddleware(handlers.LyricsDeleteHandler))
 
+	// 回收站相关路由
+	mux.HandleFunc("/api/trash/list", authMiddleware(handlers.TrashListHandler))
+	mux.HandleFunc("/api/trash/restore", authMiddleware(handlers.TrashRestoreHandler))
+	mux.HandleFunc("/api/trash/empty", authMiddleware(handlers.TrashEmptyHandler))
+
 	// AriaNg 静态文件服务（放在最后，避免与 API 路由冲突）
 	mux.Handle("/ariang/", http.StripPrefix("/ariang/", ariangHandler()))
 
@@ -0,0 +1,181 @@
+package models
+
+// HealthActivity 健康活动记录
+type HealthActivity struct {
+	ID         int    `json:"id"`
+	UUID       string `json:"uuid"` // 客户端生成的唯一标识（离线同步用）
+	UserID     int    `json:"user_id"`
+	RecordDate string `json:"record_date"` // 格式: YYYY-MM-DD
+	RecordTime string `json:"record_time"` // 格式: HH:mm
+	WeekDay    string `json:"week_day"`    // 星期几
+	Duration   int    `json:"duration"`    // 持续时间（分钟）
+	Remark     string `json:"remark"`      // 备注
+	Tag        string `json:"tag"`         // 标签: auto=自动, manual=手动
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at,omitempty"` // 最后修改时间（UTC，RFC3339），用于冲突判定
+}
+
+// ActivityPoint 健康活动的时间点（统计/预测用的精简记录）
+type ActivityPoint struct {
+	RecordDate string `json:"record_date"` // 格式: YYYY-MM-DD
+	RecordTime string `json:"record_time"` // 格式: HH:mm
+	Duration   int    `json:"duration"`    // 持续时间（分钟）
+	Tag        string `json:"tag"`         // auto / manual，旧数据 NULL 视为 manual
+}
+
+// ActivityPrediction 下一次活动的预测结果
+type ActivityPrediction struct {
+	Tag              string  `json:"tag"`                // all / auto / manual
+	SampleCount      int     `json:"sample_count"`       // 参与计算的间隔数
+	LastTime         string  `json:"last_time"`          // 最后一次记录时间
+	ExpectedAt       string  `json:"expected_at"`        // 预计下一次时间
+	ExpectedDate     string  `json:"expected_date"`      // 预计下一次日期
+	EarliestAt       string  `json:"earliest_at"`        // 置信区间下限
+	LatestAt         string  `json:"latest_at"`          // 置信区间上限
+	MeanIntervalDays float64 `json:"mean_interval_days"` // 指数加权平均间隔（天）
+	StdDevDays       float64 `json:"std_dev_days"`       // 指数加权标准差（天）
+	Overdue          bool    `json:"overdue"`            // 当前时间已超过预计时间
+}
+
+// ActivityPredictionResponse 预测响应
+type ActivityPredictionResponse struct {
+	Success bool                           `json:"success"`
+	Message string                         `json:"message"`
+	Data    map[string]*ActivityPrediction `json:"data,omitempty"` // key: all / auto / manual，样本不足时为 null
+}
+
+// ActivityStats 健康活动统计
+type ActivityStats struct {
+	TotalAuto              int      `json:"total_auto"`                          // 总计自动次数
+	TotalManual            int      `json:"total_manual"`                        // 总计手动次数
+	YearAuto               int      `json:"year_auto"`                           // 今年自动次数
+	YearManual             int      `json:"year_manual"`                         // 今年手动次数
+	MonthAuto              int      `json:"month_auto"`                          // 本月自动次数
+	MonthManual            int      `json:"month_manual"`                        // 本月手动次数
+	AutoFrequencyPerDay    float64  `json:"auto_frequency_per_day"`              // 自动频率（次/天）
+	ManualFrequencyPerDay  float64  `json:"manual_frequency_per_day"`            // 手动频率（次/天）
+	TotalFrequencyPerDay   float64  `json:"total_frequency_per_day"`             // 总频率（次/天）
+	AutoPeriodDays         float64  `json:"auto_period_days"`                    // 自动周期（天/次）
+	ManualPeriodDays       float64  `json:"manual_period_days"`                  // 手动周期（天/次）
+	TotalPeriodDays        float64  `json:"total_period_days"`                   // 总周期（天/次）
+	EarliestDate           string   `json:"earliest_date"`                       // 最早记录日期
+	LastTwoInterval        int      `json:"last_two_interval"`                   // 最后两次间隔天数，-1表示不足2条
+	LastTwoAutoInterval    int      `json:"last_two_auto_interval"`              // 最后两个自动间隔天数
+	LastTwoManualInterval  int      `json:"last_two_manual_interval"`            // 最后两个手动间隔天数
+	LastIntervalDays       *float64 `json:"last_interval_days,omitempty"`        // 最后两次间隔天数（小数）
+	LastAutoIntervalDays   *float64 `json:"last_auto_interval_days,omitempty"`   // 最后两次自动间隔天数（小数）
+	LastManualIntervalDays *float64 `json:"last_manual_interval_days,omitempty"` // 最后两次手动间隔天数（小数）
+	LastToNowDays          *float64 `json:"last_to_now_days,omitempty"`          // 最后一次距今天数（小数）
+}
+
+// ActivitySyncMutation 客户端离线期间产生的一次修改
+type ActivitySyncMutation struct {
+	IdempotencyKey string              `json:"idempotency_key"` // 每次修改唯一，重复提交只生效一次
+	Op             string              `json:"op"`              // upsert / delete
+	UUID           string              `json:"uuid"`
+	UpdatedAt      string              `json:"updated_at"` // 客户端修改时间（RFC3339）
+	Data           *ActivitySyncRecord `json:"data,omitempty"`
+}
+
+// ActivitySyncRecord upsert 携带的记录内容
+type ActivitySyncRecord struct {
+	RecordDate string `json:"record_date"`
+	RecordTime string `json:"record_time"`
+	Duration   int    `json:"duration"`
+	Remark     string `json:"remark"`
+	Tag        string `json:"tag"`
+}
+
+// ActivitySyncRequest 同步请求
+type ActivitySyncRequest struct {
+	Cursor    int64                  `json:"cursor"` // 上次同步返回的游标，首次为0
+	Limit     int                    `json:"limit"`  // 每次最多返回的变更数
+	Mutations []ActivitySyncMutation `json:"mutations"`
+}
+
+// ActivitySyncResult 单个修改的处理结果
+type ActivitySyncResult struct {
+	IdempotencyKey string          `json:"idempotency_key"`
+	UUID           string          `json:"uuid"`
+	Status         string          `json:"status"` // applied / conflict / rejected
+	Message        string          `json:"message,omitempty"`
+	Replayed       bool            `json:"replayed,omitempty"` // 重复提交，返回首次处理结果
+	Current        *HealthActivity `json:"current,omitempty"`  // 冲突时服务端的当前版本（已删除则为空）
+}
+
+// ActivityChange 变更流中的一条记录，Deleted 为 true 时是删除墓碑
+type ActivityChange struct {
+	Seq       int64           `json:"seq"`
+	UUID      string          `json:"uuid"`
+	Deleted   bool            `json:"deleted"`
+	DeletedAt string          `json:"deleted_at,omitempty"`
+	Activity  *HealthActivity `json:"activity,omitempty"`
+}
+
+// ActivitySyncData 同步响应数据
+type ActivitySyncData struct {
+	Cursor  int64                `json:"cursor"` // 下次同步使用的游标
+	HasMore bool                 `json:"has_more"`
+	Results []ActivitySyncResult `json:"results"`
+	Changes []ActivityChange     `json:"changes"`
+}
+
+// ActivitySyncResponse 同步响应
+type ActivitySyncResponse struct {
+	Success bool              `json:"success"`
+	Message string            `json:"message"`
+	Data    *ActivitySyncData `json:"data,omitempty"`
+}
+
+// ActivitySearchHit 备注搜索结果
+type ActivitySearchHit struct {
+	HealthActivity
+	Snippet string `json:"snippet"` // 备注片段，命中部分用 <mark></mark> 包裹
+}
+
+// ActivitySearchData 备注搜索分页数据
+type ActivitySearchData struct {
+	List     []ActivitySearchHit `json:"list"`
+	Total    int                 `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+}
+
+// ActivitySearchResponse 备注搜索响应
+type ActivitySearchResponse struct {
+	Success bool                `json:"success"`
+	Message string              `json:"message"`
+	Data    *ActivitySearchData `json:"data,omitempty"`
+}
+
+// ActivityBulkSelector 批量操作的记录筛选：按ID列表，或按日期范围和标签筛选（同时提供时取交集）
+type ActivityBulkSelector struct {
+	IDs  []int  `json:"ids"`
+	From string `json:"from"` // YYYY-MM-DD，含当天
+	To   string `json:"to"`   // YYYY-MM-DD，含当天
+	Tag  string `json:"tag"`  // auto / manual，为空表示全部
+}
+
+// ActivityBulkRequest 批量删除/修改标签/平移时间请求
+type ActivityBulkRequest struct {
+	ActivityBulkSelector
+	Preview      bool   `json:"preview"`       // 仅预览，不实际修改
+	NewTag       string `json:"new_tag"`       // 修改标签：auto / manual
+	ShiftDays    int    `json:"shift_days"`    // 平移天数（可为负）
+	ShiftMinutes int    `json:"shift_minutes"` // 平移分钟数（可为负）
+}
+
+// ActivityBulkResult 批量操作结果
+type ActivityBulkResult struct {
+	Preview    bool             `json:"preview"`
+	Matched    int              `json:"matched"`              // 命中的记录数
+	Affected   int              `json:"affected"`             // 实际变化的记录数（预览时为将变化的记录数）
+	Activities []HealthActivity `json:"activities,omitempty"` // 预览时返回操作后的记录（删除时为将删除的记录）
+}
+
+// ActivityBulkResponse 批量操作响应
+type ActivityBulkResponse struct {
+	Success bool                `json:"success"`
+	Message string              `json:"message"`
+	Data    *ActivityBulkResult `json:"data,omitempty"`
+}
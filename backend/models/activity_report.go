@@ -0,0 +1,114 @@
+package models
+
+// ActivityDayCount 单日活动汇总（热力图单元格）
+type ActivityDayCount struct {
+	Date     string `json:"date"`     // YYYY-MM-DD
+	Count    int    `json:"count"`    // 当日记录数
+	Duration int    `json:"duration"` // 当日总时长（分钟）
+	Level    int    `json:"level"`    // 热度等级 0-4
+}
+
+// ActivityHeatmapData 热力图数据
+type ActivityHeatmapData struct {
+	From     string             `json:"from"`
+	To       string             `json:"to"`
+	MaxCount int                `json:"max_count"`
+	Days     []ActivityDayCount `json:"days"`
+}
+
+// ActivityHeatmapResponse 热力图响应
+type ActivityHeatmapResponse struct {
+	Success bool                 `json:"success"`
+	Message string               `json:"message"`
+	Data    *ActivityHeatmapData `json:"data,omitempty"`
+}
+
+// ActivityPeriodSummary 一段时间内的活动汇总
+type ActivityPeriodSummary struct {
+	Count           int      `json:"count"`
+	AutoCount       int      `json:"auto_count"`
+	ManualCount     int      `json:"manual_count"`
+	TotalDuration   int      `json:"total_duration"` // 总时长（分钟）
+	ActiveDays      int      `json:"active_days"`    // 有记录的天数
+	AvgIntervalDays *float64 `json:"avg_interval_days"`
+	MinIntervalDays *float64 `json:"min_interval_days"`
+	MaxIntervalDays *float64 `json:"max_interval_days"`
+}
+
+// ActivityMonthlyReport 月度活动报告
+type ActivityMonthlyReport struct {
+	UserID             int                   `json:"user_id"`
+	Username           string                `json:"username"`
+	Month              string                `json:"month"`      // YYYY-MM
+	PrevMonth          string                `json:"prev_month"` // YYYY-MM
+	Current            ActivityPeriodSummary `json:"current"`
+	Previous           ActivityPeriodSummary `json:"previous"`
+	CountDelta         int                   `json:"count_delta"`
+	CountChangePercent *float64              `json:"count_change_percent"` // 上月为0时为 null
+	DurationDelta      int                   `json:"duration_delta"`
+	Heatmap            []ActivityDayCount    `json:"heatmap"`
+	Calendar           [][]ActivityDayCount  `json:"-"` // 按周（周一开始）排列的热力图，空白日期的 Date 为空
+	GeneratedAt        string                `json:"generated_at"`
+}
+
+// ActivityReportSubscription 月报邮件订阅
+type ActivityReportSubscription struct {
+	UserID        int    `json:"user_id"`
+	Email         string `json:"email"`
+	Enabled       bool   `json:"enabled"`
+	LastSentMonth string `json:"last_sent_month"` // 最近一次发送的月份（YYYY-MM）
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+}
+
+// ActivityReportSubscriptionRequest 订阅月报请求
+type ActivityReportSubscriptionRequest struct {
+	Email   string `json:"email"`
+	Enabled *bool  `json:"enabled"` // 不传时默认为 true
+}
+
+// ActivityReportSubscriptionResponse 订阅月报响应
+type ActivityReportSubscriptionResponse struct {
+	Success      bool                        `json:"success"`
+	Message      string                      `json:"message"`
+	Subscription *ActivityReportSubscription `json:"subscription,omitempty"`
+}
+
+// ActivityDateRange 日期范围（含首尾）
+type ActivityDateRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Days int    `json:"days"`
+}
+
+// ActivityMetricComparison 单项指标在两个范围的取值及变化（B 相对 A），无法计算时为 null
+type ActivityMetricComparison struct {
+	A       *float64 `json:"a"`
+	B       *float64 `json:"b"`
+	Delta   *float64 `json:"delta"`
+	Percent *float64 `json:"percent"` // A 为0时为 null
+}
+
+// ActivityTagComparison 某个标签（all / auto / manual）的范围对比
+type ActivityTagComparison struct {
+	Tag             string                   `json:"tag"`
+	Count           ActivityMetricComparison `json:"count"`
+	FrequencyPerDay ActivityMetricComparison `json:"frequency_per_day"`
+	AvgIntervalDays ActivityMetricComparison `json:"avg_interval_days"`
+	AvgDuration     ActivityMetricComparison `json:"avg_duration"`   // 平均时长（分钟）
+	TotalDuration   ActivityMetricComparison `json:"total_duration"` // 总时长（分钟）
+}
+
+// ActivityComparison 两个日期范围的活动对比
+type ActivityComparison struct {
+	A    ActivityDateRange       `json:"a"`
+	B    ActivityDateRange       `json:"b"`
+	Tags []ActivityTagComparison `json:"tags"`
+}
+
+// ActivityComparisonResponse 范围对比响应
+type ActivityComparisonResponse struct {
+	Success bool                `json:"success"`
+	Message string              `json:"message"`
+	Data    *ActivityComparison `json:"data,omitempty"`
+}
@@ -0,0 +1,104 @@
+package models
+
+// 统计分享可访问的资源
+const (
+	ActivityShareResourceStats   = "stats"
+	ActivityShareResourceSeries  = "series"
+	ActivityShareResourceHeatmap = "heatmap"
+)
+
+// 统计分享访问结果（访问日志的 status）
+const (
+	ActivityShareAccessOK        = "ok"
+	ActivityShareAccessRevoked   = "revoked"   // 分享已撤销
+	ActivityShareAccessExpired   = "expired"   // 分享已过期
+	ActivityShareAccessForbidden = "forbidden" // 非被授权用户访问
+	ActivityShareAccessInvalid   = "invalid"   // 不支持的资源
+)
+
+// ActivityShareGrant 活动统计只读分享：授权给指定用户，或生成可撤销的公开 token
+type ActivityShareGrant struct {
+	ID              int    `json:"id"`
+	OwnerID         int    `json:"owner_id"`
+	OwnerUsername   string `json:"owner_username"`
+	GranteeID       int    `json:"grantee_id,omitempty"` // 为0表示公开 token 分享
+	GranteeUsername string `json:"grantee_username,omitempty"`
+	Token           string `json:"token,omitempty"` // 仅公开分享有 token，且只对拥有者返回
+	HideRemarks     bool   `json:"hide_remarks"`    // 隐藏记录备注
+	CreatedAt       string `json:"created_at"`
+	ExpiresAt       string `json:"expires_at,omitempty"`
+	RevokedAt       string `json:"revoked_at,omitempty"`
+	AccessCount     int    `json:"access_count"` // 成功访问次数
+	DeniedCount     int    `json:"denied_count"` // 被拒绝的访问次数（已撤销、已过期等）
+	LastAccessAt    string `json:"last_access_at,omitempty"`
+}
+
+// Active 分享是否仍然有效（未撤销且未过期），now 为东八区时间字符串（与 ExpiresAt 格式一致）
+func (g *ActivityShareGrant) Active(now string) bool {
+	return g.RevokedAt == "" && (g.ExpiresAt == "" || g.ExpiresAt > now)
+}
+
+// CreateActivityShareRequest 创建统计分享请求
+type CreateActivityShareRequest struct {
+	GranteeUsername string `json:"grantee_username"` // 为空时生成公开 token
+	HideRemarks     bool   `json:"hide_remarks"`
+	ExpiresDays     int    `json:"expires_days"` // 有效天数，0 表示不过期
+}
+
+// ActivityShareResponse 创建统计分享响应
+type ActivityShareResponse struct {
+	Success  bool                `json:"success"`
+	Message  string              `json:"message"`
+	Grant    *ActivityShareGrant `json:"grant,omitempty"`
+	ShareURL string              `json:"share_url,omitempty"` // 公开分享的访问地址前缀
+}
+
+// ActivityShareListResponse 统计分享列表响应
+type ActivityShareListResponse struct {
+	Success bool                 `json:"success"`
+	Message string               `json:"message"`
+	List    []ActivityShareGrant `json:"list"`
+}
+
+// ActivityShareAccessLog 统计分享访问日志
+type ActivityShareAccessLog struct {
+	ID             int    `json:"id"`
+	GrantID        int    `json:"grant_id"`
+	ViewerID       int    `json:"viewer_id,omitempty"` // 公开 token 访问时为0
+	ViewerUsername string `json:"viewer_username,omitempty"`
+	Resource       string `json:"resource"` // stats / series / heatmap
+	Status         string `json:"status"`   // ok / revoked / expired / forbidden / invalid
+	IP             string `json:"ip"`
+	UserAgent      string `json:"user_agent"`
+	AccessedAt     string `json:"accessed_at"`
+}
+
+// ActivityShareLogResponse 访问日志响应
+type ActivityShareLogResponse struct {
+	Success bool                     `json:"success"`
+	Message string                   `json:"message"`
+	List    []ActivityShareAccessLog `json:"list"`
+}
+
+// ActivitySeriesData 日期范围内的活动记录序列（按时间升序）
+type ActivitySeriesData struct {
+	From string           `json:"from"`
+	To   string           `json:"to"`
+	List []HealthActivity `json:"list"`
+}
+
+// ActivitySeriesResponse 活动记录序列响应
+type ActivitySeriesResponse struct {
+	Success bool                `json:"success"`
+	Message string              `json:"message"`
+	Data    *ActivitySeriesData `json:"data,omitempty"`
+}
+
+// SharedActivityResponse 通过分享访问统计数据的响应
+type SharedActivityResponse struct {
+	Success       bool        `json:"success"`
+	Message       string      `json:"message"`
+	OwnerUsername string      `json:"owner_username"`
+	HideRemarks   bool        `json:"hide_remarks"`
+	Data          interface{} `json:"data"`
+}
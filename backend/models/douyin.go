@@ -7,29 +7,102 @@ type DouyinParsingRequest struct {
 
 // 抖音解析响应
 type DouyinParsingResponse struct {
-	Success bool   `json:"success"`
+	Success bool                `json:"success"`
+	Message string              `json:"message"`
+	Data    string              `json:"data,omitempty"`
+	AwemeID string              `json:"aweme_id,omitempty"` // 解析出的作品ID
+	JobID   int                 `json:"job_id,omitempty"`   // 已加入下载队列时返回任务ID，用于查询进度
+	Status  string              `json:"status,omitempty"`   // 任务状态
+	BatchID int                 `json:"batch_id,omitempty"` // 一次提交多个链接时返回批次ID，用于查询各链接进度
+	Items   []DouyinParsingItem `json:"items,omitempty"`
+}
+
+// 单个链接的解析结果
+type DouyinParsingItem struct {
+	URL     string `json:"url"`
+	AwemeID string `json:"aweme_id,omitempty"`
+	Status  string `json:"status"` // 任务状态；文件已存在、无需下载时为 succeeded
 	Message string `json:"message"`
-	Data    string `json:"data,omitempty"`
+	JobID   int    `json:"job_id,omitempty"`
+}
+
+// 抖音批量解析批次
+type DouyinBatch struct {
+	ID        int                 `json:"id"`
+	CreatedAt string              `json:"created_at"`
+	Done      bool                `json:"done"`    // 所有链接都已结束
+	Summary   map[string]int      `json:"summary"` // 各状态的链接数
+	Items     []DouyinParsingItem `json:"items"`
+}
+
+// 抖音批量解析批次响应
+type DouyinBatchResponse struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Batch   *DouyinBatch `json:"batch,omitempty"`
 }
 
 // 抖音文件信息
 type DouyinFile struct {
 	ID           int    `json:"id"`
 	UserID       int    `json:"user_id"`
-	URL          string `json:"url,omitempty"` // 解析的URL
+	URL          string `json:"url,omitempty"`      // 解析的URL
+	AwemeID      string `json:"aweme_id,omitempty"` // 作品ID
 	FileName     string `json:"file_name"`
 	FileSize     int64  `json:"file_size"`
 	FileSizeStr  string `json:"file_size_str"` // 格式化后的文件大小
 	ModifiedTime string `json:"modified_time"`
 	Path         string `json:"path"`
 	CreatedAt    string `json:"created_at"`
+
+	// 视频元数据（来自下载器输出的附属文件和 MP4 容器，缺失时为空）
+	Author      string  `json:"author,omitempty"`      // 作者昵称
+	Description string  `json:"description,omitempty"` // 作品描述
+	CreateTime  string  `json:"create_time,omitempty"` // 作品发布时间
+	Duration    float64 `json:"duration,omitempty"`    // 时长（秒）
+	Width       int     `json:"width,omitempty"`
+	Height      int     `json:"height,omitempty"`
+	CoverPath   string  `json:"cover_path,omitempty"` // 本地封面图片
+	CoverURL    string  `json:"cover_url,omitempty"`  // 远程封面地址
 }
 
-// 抖音文件列表响应
+// 抖音文件列表响应（group_by=url 时返回 groups，分页按分组计数）
 type DouyinFileListResponse struct {
-	Success bool         `json:"success"`
-	Message string       `json:"message"`
-	List    []DouyinFile `json:"list,omitempty"`
+	Success  bool              `json:"success"`
+	Message  string            `json:"message"`
+	List     []DouyinFile      `json:"list,omitempty"`
+	Groups   []DouyinFileGroup `json:"groups,omitempty"`
+	Total    int               `json:"total"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"page_size"`
+}
+
+// 抖音文件列表查询条件
+type DouyinFileQuery struct {
+	Keyword  string // 匹配文件名、作者、描述
+	Sort     string // date / size / author / name
+	Desc     bool
+	Page     int
+	PageSize int // 为 0 时不分页
+}
+
+// 按来源URL分组的抖音文件
+type DouyinFileGroup struct {
+	URL          string       `json:"url"`
+	AwemeID      string       `json:"aweme_id,omitempty"`
+	Count        int          `json:"count"`
+	TotalSize    int64        `json:"total_size"`
+	TotalSizeStr string       `json:"total_size_str"`
+	LatestAt     string       `json:"latest_at"`
+	Files        []DouyinFile `json:"files"`
+}
+
+// 视频播放签名URL响应
+type DouyinStreamURLResponse struct {
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	URL       string `json:"url,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
 }
 
 // 下载请求
@@ -38,3 +111,85 @@ type DouyinDownloadRequest struct {
 	Path string `json:"path"`
 }
 
+// 抖音下载任务状态
+const (
+	DouyinJobQueued    = "queued"
+	DouyinJobRunning   = "running"
+	DouyinJobSucceeded = "succeeded"
+	DouyinJobFailed    = "failed"
+	DouyinJobCancelled = "cancelled"
+)
+
+// 抖音下载任务（持久化在数据库中，服务重启后继续执行）
+type DouyinJob struct {
+	ID         int    `json:"id"`
+	UserID     int    `json:"user_id"`
+	URL        string `json:"url"`
+	AwemeID    string `json:"aweme_id,omitempty"`
+	Status     string `json:"status"` // queued / running / succeeded / failed / cancelled
+	Message    string `json:"message"`
+	Log        string `json:"log,omitempty"`
+	LeaderID   int    `json:"leader_id,omitempty"` // 非0 表示复用该任务（同一URL）的下载结果
+	CreatedAt  string `json:"created_at"`
+	StartedAt  string `json:"started_at,omitempty"`
+	FinishedAt string `json:"finished_at,omitempty"`
+
+	Attempts      int    `json:"attempts"`                  // 已执行的下载次数
+	NextAttemptAt string `json:"next_attempt_at,omitempty"` // 自动重试的时间（等待重试时才有）
+}
+
+// 抖音下载任务的一次执行记录
+type DouyinJobAttempt struct {
+	ID         int    `json:"id"`
+	JobID      int    `json:"job_id"`
+	Attempt    int    `json:"attempt"` // 第几次执行，从1开始
+	Downloader string `json:"downloader,omitempty"`
+	ExitCode   int    `json:"exit_code"` // 下载命令的退出码，未启动或被终止时为 -1
+	Success    bool   `json:"success"`
+	Transient  bool   `json:"transient"` // 是否为可自动重试的临时错误（网络、限流、超时等）
+	Message    string `json:"message"`
+	Output     string `json:"output,omitempty"` // 截断后的命令输出
+	DurationMs int64  `json:"duration_ms"`
+	StartedAt  string `json:"started_at"`
+}
+
+// 抖音下载任务响应
+type DouyinJobResponse struct {
+	Success  bool               `json:"success"`
+	Message  string             `json:"message"`
+	Job      *DouyinJob         `json:"job,omitempty"`
+	Attempts []DouyinJobAttempt `json:"attempts,omitempty"`
+}
+
+// 抖音下载任务列表响应
+type DouyinJobListResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	List    []DouyinJob `json:"list"`
+}
+
+// 抖音链接的下载状态（douyin_urls.status）
+const (
+	DouyinURLSucceeded = "succeeded"
+	DouyinURLRetrying  = "retrying" // 已重新排队（临时错误自动重试或手动重试）
+	DouyinURLFailed    = "failed"
+)
+
+// 下载失败的抖音链接
+type DouyinFailedURL struct {
+	ID        int    `json:"id"`
+	URL       string `json:"url"`
+	AwemeID   string `json:"aweme_id,omitempty"`
+	Status    string `json:"status"` // retrying / failed
+	LastError string `json:"last_error"`
+	JobID     int    `json:"job_id"`   // 最近一次的下载任务
+	Attempts  int    `json:"attempts"` // 最近一次任务（跟随其他任务时为被跟随的任务）已执行的次数
+	UpdatedAt string `json:"updated_at"`
+}
+
+// 下载失败的抖音链接列表响应
+type DouyinFailedURLListResponse struct {
+	Success bool              `json:"success"`
+	Message string            `json:"message"`
+	List    []DouyinFailedURL `json:"list"`
+}
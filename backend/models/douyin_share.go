@@ -0,0 +1,42 @@
+package models
+
+// DouyinShare 抖音视频公开分享：持有 token 的人无需登录即可在线观看，可撤销、可设置有效期
+type DouyinShare struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"user_id"`
+	FileID      int    `json:"file_id"`
+	Token       string `json:"token"`
+	FileName    string `json:"file_name"`
+	Author      string `json:"author,omitempty"`
+	Description string `json:"description,omitempty"`
+	ViewCount   int    `json:"view_count"`
+	CreatedAt   string `json:"created_at"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	RevokedAt   string `json:"revoked_at,omitempty"`
+}
+
+// Active 分享是否仍然有效（未撤销且未过期），now 为东八区时间字符串（与 ExpiresAt 格式一致）
+func (s *DouyinShare) Active(now string) bool {
+	return s.RevokedAt == "" && (s.ExpiresAt == "" || s.ExpiresAt > now)
+}
+
+// CreateDouyinShareRequest 创建抖音视频分享请求
+type CreateDouyinShareRequest struct {
+	FileID      int `json:"file_id"`
+	ExpiresDays int `json:"expires_days"` // 有效天数，0 表示不过期
+}
+
+// DouyinShareResponse 创建/撤销抖音视频分享响应
+type DouyinShareResponse struct {
+	Success  bool         `json:"success"`
+	Message  string       `json:"message"`
+	Share    *DouyinShare `json:"share,omitempty"`
+	ShareURL string       `json:"share_url,omitempty"` // 公开观看页面地址
+}
+
+// DouyinShareListResponse 抖音视频分享列表响应
+type DouyinShareListResponse struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message"`
+	List    []DouyinShare `json:"list"`
+}
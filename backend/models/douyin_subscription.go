@@ -0,0 +1,63 @@
+package models
+
+// 创作者订阅状态
+const (
+	DouyinSubscriptionActive = "active"
+	DouyinSubscriptionPaused = "paused"
+)
+
+// 订阅作品记录状态：首次检查时已有的作品只记录、不下载
+const DouyinSubscriptionPostBaseline = "baseline"
+
+// DouyinSubscription 创作者主页订阅，后台定期检查并下载新作品
+type DouyinSubscription struct {
+	ID            int    `json:"id"`
+	UserID        int    `json:"user_id"`
+	ProfileURL    string `json:"profile_url"`
+	Name          string `json:"name"`
+	Status        string `json:"status"` // active / paused
+	LastCheckedAt string `json:"last_checked_at,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	PostCount     int    `json:"post_count"` // 订阅后发现的新作品数
+	CreatedAt     string `json:"created_at"`
+}
+
+// CreateDouyinSubscriptionRequest 创建订阅请求
+type CreateDouyinSubscriptionRequest struct {
+	ProfileURL string `json:"profile_url"`
+	Name       string `json:"name"`
+}
+
+// DouyinSubscriptionResponse 订阅响应
+type DouyinSubscriptionResponse struct {
+	Success      bool                `json:"success"`
+	Message      string              `json:"message"`
+	Subscription *DouyinSubscription `json:"subscription,omitempty"`
+	Added        int                 `json:"added,omitempty"` // 手动检查时新加入队列的作品数
+}
+
+// DouyinSubscriptionListResponse 订阅列表响应
+type DouyinSubscriptionListResponse struct {
+	Success bool                 `json:"success"`
+	Message string               `json:"message"`
+	List    []DouyinSubscription `json:"list"`
+}
+
+// DouyinSubscriptionPost 订阅发现的作品（状态取自对应下载任务）
+type DouyinSubscriptionPost struct {
+	ID        int    `json:"id"`
+	AwemeID   string `json:"aweme_id,omitempty"`
+	URL       string `json:"url"`
+	Title     string `json:"title,omitempty"`
+	JobID     int    `json:"job_id,omitempty"`
+	Status    string `json:"status"` // baseline 或下载任务状态
+	Message   string `json:"message"`
+	CreatedAt string `json:"created_at"`
+}
+
+// DouyinSubscriptionHistoryResponse 订阅历史响应
+type DouyinSubscriptionHistoryResponse struct {
+	Success bool                     `json:"success"`
+	Message string                   `json:"message"`
+	List    []DouyinSubscriptionPost `json:"list"`
+}
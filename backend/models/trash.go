@@ -0,0 +1,35 @@
+package models
+
+// 回收站条目类型
+const (
+	TrashTypeActivity = "activity"
+	TrashTypeFile     = "file"
+	TrashTypeMusic    = "music"
+	TrashTypeLyrics   = "lyrics"
+)
+
+// TrashItem 回收站条目（被删除记录的快照，物理文件保留到过期清理）
+type TrashItem struct {
+	ID        int    `json:"id"`
+	UserID    int    `json:"user_id"`
+	ItemType  string `json:"item_type"` // activity / file / music / lyrics
+	ItemID    int    `json:"item_id"`   // 原记录ID
+	Title     string `json:"title"`
+	DeletedAt string `json:"deleted_at"`
+	ExpiresAt string `json:"expires_at"` // 超过该时间后永久删除（含物理文件）
+}
+
+// TrashListResponse 回收站列表响应
+type TrashListResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	List    []TrashItem `json:"list"`
+}
+
+// TrashRestoreResponse 恢复响应
+type TrashRestoreResponse struct {
+	Success  bool   `json:"success"`
+	Message  string `json:"message"`
+	ItemType string `json:"item_type,omitempty"`
+	ItemID   int    `json:"item_id,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+// TusUpload 断点续传上传（tus 协议），上传完成后转为文件传输记录
+type TusUpload struct {
+	ID        string // UUID，出现在上传地址中
+	UserID    int
+	FileName  string
+	FileType  string
+	Metadata  string // 客户端的 Upload-Metadata 原文，HEAD 时原样返回
+	Length    int64  // 文件总大小
+	Offset    int64  // 已接收的字节数
+	PartPath  string // 未完成时的临时文件
+	FileID    int    // 完成后对应的 file_transfers.id，未完成为0
+	CreatedAt string
+	ExpiresAt string // UTC，过期后未完成的上传会被清理
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"backend/database"
+	"backend/models"
+)
+
+// 对比的标签，"" 表示全部
+var compareTags = []string{"", "auto", "manual"}
+
+// CompareActivityRanges 对比两个日期范围（含首尾）内各标签的次数、频率、平均间隔和时长，变化为 B 相对 A
+func CompareActivityRanges(userID int, aFrom, aTo, bFrom, bTo time.Time) (*models.ActivityComparison, error) {
+	aPoints, err := database.GetUserActivityPointsBetween(userID, aFrom.Format(dateLayout), aTo.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	bPoints, err := database.GetUserActivityPointsBetween(userID, bFrom.Format(dateLayout), bTo.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	a := dateRange(aFrom, aTo)
+	b := dateRange(bFrom, bTo)
+	result := &models.ActivityComparison{A: a, B: b}
+	for _, tag := range compareTags {
+		am := rangeMetrics(aPoints, tag, a.Days)
+		bm := rangeMetrics(bPoints, tag, b.Days)
+
+		name := tag
+		if name == "" {
+			name = "all"
+		}
+		result.Tags = append(result.Tags, models.ActivityTagComparison{
+			Tag:             name,
+			Count:           compareMetric(&am.count, &bm.count),
+			FrequencyPerDay: compareMetric(&am.frequency, &bm.frequency),
+			AvgIntervalDays: compareMetric(am.avgInterval, bm.avgInterval),
+			AvgDuration:     compareMetric(am.avgDuration, bm.avgDuration),
+			TotalDuration:   compareMetric(&am.totalDuration, &bm.totalDuration),
+		})
+	}
+	return result, nil
+}
+
+type activityRangeMetrics struct {
+	count         float64
+	frequency     float64
+	avgInterval   *float64
+	avgDuration   *float64
+	totalDuration float64
+}
+
+func rangeMetrics(points []models.ActivityPoint, tag string, days int) activityRangeMetrics {
+	var filtered []models.ActivityPoint
+	for _, p := range points {
+		if matchActivityTag(p, tag) {
+			filtered = append(filtered, p)
+		}
+	}
+	s := summarizePeriod(filtered)
+
+	m := activityRangeMetrics{
+		count:         float64(s.Count),
+		frequency:     math.Round(float64(s.Count)/float64(days)*100) / 100, // 频率较小，保留两位小数
+		avgInterval:   s.AvgIntervalDays,
+		totalDuration: float64(s.TotalDuration),
+	}
+	if s.Count > 0 {
+		v := roundToOneDecimal(float64(s.TotalDuration) / float64(s.Count))
+		m.avgDuration = &v
+	}
+	return m
+}
+
+func dateRange(from, to time.Time) models.ActivityDateRange {
+	return models.ActivityDateRange{
+		From: from.Format(dateLayout),
+		To:   to.Format(dateLayout),
+		Days: int(to.Sub(from).Hours()/24+0.5) + 1,
+	}
+}
+
+func compareMetric(a, b *float64) models.ActivityMetricComparison {
+	c := models.ActivityMetricComparison{A: a, B: b}
+	if a == nil || b == nil {
+		return c
+	}
+	delta := math.Round((*b-*a)*100) / 100
+	c.Delta = &delta
+	if *a != 0 {
+		percent := roundToOneDecimal((*b - *a) * 100 / *a)
+		c.Percent = &percent
+	}
+	return c
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"time"
+
+	"backend/database"
+	"backend/models"
+)
+
+const dateLayout = "2006-01-02"
+
+// GetActivityHeatmap 获取用户在日期范围内（含首尾）的每日活动热力图，无记录的日期补0
+func GetActivityHeatmap(userID int, from, to time.Time) (*models.ActivityHeatmapData, error) {
+	points, err := database.GetUserActivityPointsBetween(userID, from.Format(dateLayout), to.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	days, maxCount := dailyActivityCounts(points, from, to)
+	return &models.ActivityHeatmapData{
+		From:     from.Format(dateLayout),
+		To:       to.Format(dateLayout),
+		MaxCount: maxCount,
+		Days:     days,
+	}, nil
+}
+
+// dailyActivityCounts 按日汇总记录数和时长，并按当日记录数相对最大值计算热度等级（0-4）
+func dailyActivityCounts(points []models.ActivityPoint, from, to time.Time) ([]models.ActivityDayCount, int) {
+	byDate := make(map[string]*models.ActivityDayCount)
+	for _, p := range points {
+		d, ok := byDate[p.RecordDate]
+		if !ok {
+			d = &models.ActivityDayCount{Date: p.RecordDate}
+			byDate[p.RecordDate] = d
+		}
+		d.Count++
+		d.Duration += p.Duration
+	}
+
+	var days []models.ActivityDayCount
+	maxCount := 0
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format(dateLayout)
+		d := models.ActivityDayCount{Date: key}
+		if c, ok := byDate[key]; ok {
+			d = *c
+		}
+		if d.Count > maxCount {
+			maxCount = d.Count
+		}
+		days = append(days, d)
+	}
+
+	for i := range days {
+		if days[i].Count == 0 {
+			continue
+		}
+		level := (days[i].Count*4 + maxCount - 1) / maxCount
+		if level > 4 {
+			level = 4
+		}
+		days[i].Level = level
+	}
+	return days, maxCount
+}
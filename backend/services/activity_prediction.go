@@ -0,0 +1,122 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+)
+
+const (
+	// 指数加权系数，越大越看重最近的间隔
+	predictionAlpha = 0.3
+	// 置信区间系数（约 80% 区间）
+	predictionZ = 1.28
+	// 样本较少时标准差至少取均值的该比例，避免区间过窄
+	predictionMinStdRatio = 0.5
+	predictionMinSamples  = 3
+)
+
+// parseActivityTime 解析记录日期和时间（东八区）
+func parseActivityTime(date, t string) (time.Time, error) {
+	if len(t) == 5 {
+		t += ":00"
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", date+" "+t, utils.GetShanghaiTZ())
+}
+
+// normalizeActivityTag 规范化记录标签：与查询中的 COALESCE(tag, 'manual') 一致，旧数据的 NULL/空标签视为手动
+func normalizeActivityTag(tag string) string {
+	if tag == "" {
+		return "manual"
+	}
+	return tag
+}
+
+// matchActivityTag 判断记录是否属于指定标签，tag 为空表示全部
+func matchActivityTag(p models.ActivityPoint, tag string) bool {
+	return tag == "" || normalizeActivityTag(p.Tag) == tag
+}
+
+// PredictNextActivity 按标签预测下一次活动时间（all / auto / manual）
+func PredictNextActivity(userID int) (map[string]*models.ActivityPrediction, error) {
+	points, err := database.GetUserActivityPoints(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := utils.Now()
+	return map[string]*models.ActivityPrediction{
+		"all":    predictByTag(points, "", now),
+		"auto":   predictByTag(points, "auto", now),
+		"manual": predictByTag(points, "manual", now),
+	}, nil
+}
+
+// predictByTag 使用指数加权移动平均（EWMA）及其方差估计下一次间隔，不足2条记录返回 nil
+func predictByTag(points []models.ActivityPoint, tag string, now time.Time) *models.ActivityPrediction {
+	var times []time.Time
+	for _, p := range points {
+		if !matchActivityTag(p, tag) {
+			continue
+		}
+		t, err := parseActivityTime(p.RecordDate, p.RecordTime)
+		if err != nil {
+			continue
+		}
+		times = append(times, t)
+	}
+	if len(times) < 2 {
+		return nil
+	}
+
+	var mean, variance float64
+	for i := 1; i < len(times); i++ {
+		interval := times[i].Sub(times[i-1]).Hours() / 24.0
+		if i == 1 {
+			mean = interval
+			continue
+		}
+		diff := interval - mean
+		mean += predictionAlpha * diff
+		variance = (1 - predictionAlpha) * (variance + predictionAlpha*diff*diff)
+	}
+
+	samples := len(times) - 1
+	std := math.Sqrt(variance)
+	if samples < predictionMinSamples && std < mean*predictionMinStdRatio {
+		std = mean * predictionMinStdRatio
+	}
+
+	last := times[len(times)-1]
+	expected := last.Add(daysToDuration(mean))
+	earliest := last.Add(daysToDuration(math.Max(0, mean-predictionZ*std)))
+	latest := last.Add(daysToDuration(mean + predictionZ*std))
+
+	resultTag := tag
+	if resultTag == "" {
+		resultTag = "all"
+	}
+	return &models.ActivityPrediction{
+		Tag:              resultTag,
+		SampleCount:      samples,
+		LastTime:         last.Format("2006-01-02 15:04"),
+		ExpectedAt:       expected.Format("2006-01-02 15:04"),
+		ExpectedDate:     expected.Format("2006-01-02"),
+		EarliestAt:       earliest.Format("2006-01-02 15:04"),
+		LatestAt:         latest.Format("2006-01-02 15:04"),
+		MeanIntervalDays: roundToOneDecimal(mean),
+		StdDevDays:       roundToOneDecimal(std),
+		Overdue:          now.After(expected),
+	}
+}
+
+func daysToDuration(days float64) time.Duration {
+	return time.Duration(days * 24 * float64(time.Hour))
+}
+
+func roundToOneDecimal(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+)
+
+// ErrInvalidReportMonth 月份格式错误（应为 YYYY-MM）
+var ErrInvalidReportMonth = errors.New("invalid report month")
+
+// ParseReportMonth 解析月份（YYYY-MM），返回该月1日0点（东八区）
+func ParseReportMonth(month string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01", month, utils.GetShanghaiTZ())
+	if err != nil {
+		return time.Time{}, ErrInvalidReportMonth
+	}
+	return t, nil
+}
+
+// BuildMonthlyReport 生成用户指定月份的活动报告（含与上月的对比和当月热力图）
+func BuildMonthlyReport(userID int, month string) (*models.ActivityMonthlyReport, error) {
+	start, err := ParseReportMonth(month)
+	if err != nil {
+		return nil, err
+	}
+	end := start.AddDate(0, 1, -1)
+	prevStart := start.AddDate(0, -1, 0)
+
+	// 一次查询上月和本月的记录，再按月份拆分
+	points, err := database.GetUserActivityPointsBetween(userID, prevStart.Format(dateLayout), end.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	currentPrefix := start.Format("2006-01")
+	var current, previous []models.ActivityPoint
+	for _, p := range points {
+		if strings.HasPrefix(p.RecordDate, currentPrefix) {
+			current = append(current, p)
+		} else {
+			previous = append(previous, p)
+		}
+	}
+
+	username, err := database.GetUsername(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ActivityMonthlyReport{
+		UserID:      userID,
+		Username:    username,
+		Month:       currentPrefix,
+		PrevMonth:   prevStart.Format("2006-01"),
+		Current:     summarizePeriod(current),
+		Previous:    summarizePeriod(previous),
+		GeneratedAt: utils.NowString(),
+	}
+	report.CountDelta = report.Current.Count - report.Previous.Count
+	report.DurationDelta = report.Current.TotalDuration - report.Previous.TotalDuration
+	if report.Previous.Count > 0 {
+		v := roundToOneDecimal(float64(report.CountDelta) * 100 / float64(report.Previous.Count))
+		report.CountChangePercent = &v
+	}
+
+	report.Heatmap, _ = dailyActivityCounts(current, start, end)
+	report.Calendar = calendarWeeks(report.Heatmap, start)
+	return report, nil
+}
+
+// summarizePeriod 汇总一段时间内的记录（按时间升序），间隔按相邻两条记录计算
+func summarizePeriod(points []models.ActivityPoint) models.ActivityPeriodSummary {
+	var s models.ActivityPeriodSummary
+	days := make(map[string]bool)
+	var prev time.Time
+	var intervalSum float64
+	intervals := 0
+	minInterval, maxInterval := math.MaxFloat64, 0.0
+
+	for _, p := range points {
+		s.Count++
+		if normalizeActivityTag(p.Tag) == "auto" {
+			s.AutoCount++
+		} else {
+			s.ManualCount++
+		}
+		s.TotalDuration += p.Duration
+		days[p.RecordDate] = true
+
+		t, err := parseActivityTime(p.RecordDate, p.RecordTime)
+		if err != nil {
+			continue
+		}
+		if !prev.IsZero() {
+			interval := t.Sub(prev).Hours() / 24
+			intervalSum += interval
+			intervals++
+			minInterval = math.Min(minInterval, interval)
+			maxInterval = math.Max(maxInterval, interval)
+		}
+		prev = t
+	}
+	s.ActiveDays = len(days)
+
+	if intervals > 0 {
+		avg := roundToOneDecimal(intervalSum / float64(intervals))
+		min := roundToOneDecimal(minInterval)
+		max := roundToOneDecimal(maxInterval)
+		s.AvgIntervalDays, s.MinIntervalDays, s.MaxIntervalDays = &avg, &min, &max
+	}
+	return s
+}
+
+// calendarWeeks 将一个月的每日数据按周（周一开始）排成日历，月初和月末的空白用空 Date 补齐
+func calendarWeeks(days []models.ActivityDayCount, monthStart time.Time) [][]models.ActivityDayCount {
+	offset := (int(monthStart.Weekday()) + 6) % 7
+	cells := make([]models.ActivityDayCount, offset, offset+len(days)+6)
+	cells = append(cells, days...)
+	for len(cells)%7 != 0 {
+		cells = append(cells, models.ActivityDayCount{})
+	}
+
+	var weeks [][]models.ActivityDayCount
+	for i := 0; i < len(cells); i += 7 {
+		weeks = append(weeks, cells[i:i+7])
+	}
+	return weeks
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+)
+
+// ActivityReportRenderer 将月报渲染为 HTML（由 handlers 提供，与页面使用同一模板）
+type ActivityReportRenderer func(report *models.ActivityMonthlyReport) ([]byte, error)
+
+// SendActivityReport 生成并发送指定月份的月报邮件
+func SendActivityReport(render ActivityReportRenderer, userID int, email, month string) error {
+	if !SMTP.Enabled() {
+		return ErrSMTPNotConfigured
+	}
+	report, err := BuildMonthlyReport(userID, month)
+	if err != nil {
+		return err
+	}
+	body, err := render(report)
+	if err != nil {
+		return err
+	}
+	return SendHTMLMail(SMTP, email, "健康活动月报 "+report.Month, body)
+}
+
+// StartActivityReportMailer 启动月报定时发送：启动时检查一次，之后每隔 interval 检查，
+// 为尚未收到上个月月报的订阅发送邮件。未配置 SMTP 时不启动
+func StartActivityReportMailer(render ActivityReportRenderer, interval time.Duration) {
+	if !SMTP.Enabled() {
+		log.Println("未配置 SMTP，月报邮件发送未启动")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			sendDueActivityReports(render)
+			<-ticker.C
+		}
+	}()
+}
+
+func sendDueActivityReports(render ActivityReportRenderer) {
+	now := utils.Now()
+	month := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, now.Location()).Format("2006-01")
+
+	subs, err := database.GetDueActivityReportSubscriptions(month)
+	if err != nil {
+		log.Printf("查询待发送月报失败: %v", err)
+		return
+	}
+	for _, sub := range subs {
+		if err := SendActivityReport(render, sub.UserID, sub.Email, month); err != nil {
+			// 未标记为已发送，下次检查时重试
+			log.Printf("发送月报失败: user_id=%d, month=%s, error=%v", sub.UserID, month, err)
+			continue
+		}
+		if err := database.MarkActivityReportSent(sub.UserID, month); err != nil {
+			log.Printf("记录月报发送状态失败: user_id=%d, error=%v", sub.UserID, err)
+			continue
+		}
+		log.Printf("已发送月报: user_id=%d, month=%s", sub.UserID, month)
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+)
+
+// activityBucket 单个标签（全部/自动/手动）的扫描结果
+type activityBucket struct {
+	count int
+	first *models.ActivityPoint // 最早一条
+	last  *models.ActivityPoint // 最后一条
+	prev  *models.ActivityPoint // 倒数第二条
+}
+
+func (b *activityBucket) add(p *models.ActivityPoint) {
+	b.count++
+	if b.first == nil {
+		b.first = p
+	}
+	b.prev = b.last
+	b.last = p
+}
+
+// tagCounts 自动/手动计数
+type tagCounts struct {
+	auto   int
+	manual int
+}
+
+// activitySummary 一次扫描得到的、与当前时间无关的统计结果（可缓存）
+type activitySummary struct {
+	all, auto, manual activityBucket
+	earliestDate      string
+	byYear            map[string]tagCounts // key: YYYY
+	byMonth           map[string]tagCounts // key: YYYY-MM
+}
+
+// 按用户缓存统计结果，写入后失效；版本号用于避免失效前发起的查询把旧结果写回缓存
+var (
+	activityStatsCache   = make(map[int]*activitySummary)
+	activityStatsVersion = make(map[int]int)
+	activityStatsCacheMu sync.RWMutex
+)
+
+// InvalidateActivityStats 使用户的统计缓存失效（创建/删除/修改记录后调用）
+func InvalidateActivityStats(userID int) {
+	activityStatsCacheMu.Lock()
+	delete(activityStatsCache, userID)
+	activityStatsVersion[userID]++
+	activityStatsCacheMu.Unlock()
+}
+
+// GetActivityStats 获取用户的健康活动统计（一次查询、一次扫描，结果按用户缓存）
+func GetActivityStats(userID int) (*models.ActivityStats, error) {
+	activityStatsCacheMu.RLock()
+	summary, ok := activityStatsCache[userID]
+	version := activityStatsVersion[userID]
+	activityStatsCacheMu.RUnlock()
+
+	if !ok {
+		points, err := database.GetUserActivityPoints(userID)
+		if err != nil {
+			return nil, err
+		}
+		summary = summarizeActivities(points)
+
+		activityStatsCacheMu.Lock()
+		if activityStatsVersion[userID] == version {
+			activityStatsCache[userID] = summary
+		}
+		activityStatsCacheMu.Unlock()
+	}
+
+	return buildActivityStats(summary, utils.Now()), nil
+}
+
+// summarizeActivities 扫描按时间升序排列的记录，汇总各项计数和首尾记录
+func summarizeActivities(points []models.ActivityPoint) *activitySummary {
+	s := &activitySummary{
+		byYear:  make(map[string]tagCounts),
+		byMonth: make(map[string]tagCounts),
+	}
+	for i := range points {
+		p := &points[i]
+		s.all.add(p)
+		if s.earliestDate == "" || p.RecordDate < s.earliestDate {
+			s.earliestDate = p.RecordDate
+		}
+
+		tag := normalizeActivityTag(p.Tag)
+		var bucket *activityBucket
+		switch tag {
+		case "auto":
+			bucket = &s.auto
+		case "manual":
+			bucket = &s.manual
+		default:
+			continue
+		}
+		bucket.add(p)
+
+		if len(p.RecordDate) >= 7 {
+			year, month := p.RecordDate[:4], p.RecordDate[:7]
+			yc, mc := s.byYear[year], s.byMonth[month]
+			if tag == "auto" {
+				yc.auto++
+				mc.auto++
+			} else {
+				yc.manual++
+				mc.manual++
+			}
+			s.byYear[year], s.byMonth[month] = yc, mc
+		}
+	}
+	return s
+}
+
+// buildActivityStats 根据扫描结果和当前时间生成统计数据
+func buildActivityStats(s *activitySummary, now time.Time) *models.ActivityStats {
+	// 记录日期是东八区日期，年/月的归属也按东八区计算
+	local := now.In(utils.GetShanghaiTZ())
+	year := s.byYear[local.Format("2006")]
+	month := s.byMonth[local.Format("2006-01")]
+
+	totalRange := s.all.rangeDays()
+	autoRange := s.auto.rangeDays()
+	manualRange := s.manual.rangeDays()
+	totalCount := s.auto.count + s.manual.count
+
+	return &models.ActivityStats{
+		TotalAuto:              s.auto.count,
+		TotalManual:            s.manual.count,
+		YearAuto:               year.auto,
+		YearManual:             year.manual,
+		MonthAuto:              month.auto,
+		MonthManual:            month.manual,
+		AutoFrequencyPerDay:    frequencyPerDay(s.auto.count, autoRange),
+		ManualFrequencyPerDay:  frequencyPerDay(s.manual.count, manualRange),
+		TotalFrequencyPerDay:   frequencyPerDay(totalCount, totalRange),
+		AutoPeriodDays:         periodDays(s.auto.count, autoRange),
+		ManualPeriodDays:       periodDays(s.manual.count, manualRange),
+		TotalPeriodDays:        periodDays(totalCount, totalRange),
+		EarliestDate:           s.earliestDate,
+		LastTwoInterval:        s.all.lastTwoIntervalDays(),
+		LastTwoAutoInterval:    s.auto.lastTwoIntervalDays(),
+		LastTwoManualInterval:  s.manual.lastTwoIntervalDays(),
+		LastIntervalDays:       s.all.lastTwoIntervalDaysFloat(),
+		LastAutoIntervalDays:   s.auto.lastTwoIntervalDaysFloat(),
+		LastManualIntervalDays: s.manual.lastTwoIntervalDaysFloat(),
+		LastToNowDays:          s.all.lastToNowDays(now),
+	}
+}
+
+// rangeDays 首尾记录之间的天数（小数），无记录或跨度为0时返回1
+func (b *activityBucket) rangeDays() float64 {
+	if b.first == nil || b.last == nil {
+		return 1
+	}
+	minDT, err1 := parseActivityTime(b.first.RecordDate, b.first.RecordTime)
+	maxDT, err2 := parseActivityTime(b.last.RecordDate, b.last.RecordTime)
+	if err1 != nil || err2 != nil {
+		return 1
+	}
+	span := maxDT.Sub(minDT).Hours() / 24.0
+	if span <= 0 {
+		span = 1
+	}
+	return span
+}
+
+// lastTwoIntervalDays 最后两条记录的间隔天数（按日期），不足2条返回-1
+func (b *activityBucket) lastTwoIntervalDays() int {
+	if b.prev == nil || b.last == nil {
+		return -1
+	}
+	t1, err1 := time.Parse("2006-01-02", b.last.RecordDate)
+	t2, err2 := time.Parse("2006-01-02", b.prev.RecordDate)
+	if err1 != nil || err2 != nil {
+		return -1
+	}
+	days := int(t1.Sub(t2).Hours() / 24)
+	if days < 0 {
+		days = -days
+	}
+	return days
+}
+
+// lastTwoIntervalDaysFloat 最后两条记录的间隔天数（小数），不足2条返回 nil
+func (b *activityBucket) lastTwoIntervalDaysFloat() *float64 {
+	if b.prev == nil || b.last == nil {
+		return nil
+	}
+	t1, err1 := parseActivityTime(b.last.RecordDate, b.last.RecordTime)
+	t2, err2 := parseActivityTime(b.prev.RecordDate, b.prev.RecordTime)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	days := t1.Sub(t2).Hours() / 24.0
+	if days < 0 {
+		days = -days
+	}
+	v := roundToOneDecimal(days)
+	return &v
+}
+
+// lastToNowDays 最后一条记录距今的天数（小数），无记录返回 nil
+func (b *activityBucket) lastToNowDays(now time.Time) *float64 {
+	if b.last == nil {
+		return nil
+	}
+	last, err := parseActivityTime(b.last.RecordDate, b.last.RecordTime)
+	if err != nil {
+		return nil
+	}
+	days := now.Sub(last).Hours() / 24.0
+	if days < 0 {
+		return nil
+	}
+	v := roundToOneDecimal(days)
+	return &v
+}
+
+func frequencyPerDay(total int, spanDays float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	if spanDays <= 0 {
+		spanDays = 1
+	}
+	return roundToOneDecimal(float64(total) / spanDays)
+}
+
+func periodDays(total int, spanDays float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	if spanDays <= 0 {
+		spanDays = 1
+	}
+	return roundToOneDecimal(spanDays / float64(total))
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+)
+
+func fp(v float64) *float64 { return &v }
+
+func shanghaiTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.ParseInLocation("2006-01-02 15:04", s, utils.GetShanghaiTZ())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestBuildActivityStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []models.ActivityPoint
+		now    string    // 东八区时间
+		nowUTC time.Time // 非零时优先使用，用于验证跨时区的年/月归属
+		want   models.ActivityStats
+	}{
+		{
+			name: "无记录",
+			now:  "2026-10-10 08:00",
+			want: models.ActivityStats{
+				LastTwoInterval:       -1,
+				LastTwoAutoInterval:   -1,
+				LastTwoManualInterval: -1,
+			},
+		},
+		{
+			name: "单条记录",
+			points: []models.ActivityPoint{
+				{RecordDate: "2026-10-10", RecordTime: "08:00", Tag: "auto"},
+			},
+			now: "2026-10-11 20:00",
+			want: models.ActivityStats{
+				TotalAuto:             1,
+				YearAuto:              1,
+				MonthAuto:             1,
+				AutoFrequencyPerDay:   1,
+				TotalFrequencyPerDay:  1,
+				AutoPeriodDays:        1,
+				TotalPeriodDays:       1,
+				EarliestDate:          "2026-10-10",
+				LastTwoInterval:       -1,
+				LastTwoAutoInterval:   -1,
+				LastTwoManualInterval: -1,
+				LastToNowDays:         fp(1.5),
+			},
+		},
+		{
+			name: "两条记录",
+			points: []models.ActivityPoint{
+				{RecordDate: "2026-10-01", RecordTime: "08:00", Tag: "manual"},
+				{RecordDate: "2026-10-04", RecordTime: "20:00", Tag: "auto"},
+			},
+			now: "2026-10-05 08:00",
+			want: models.ActivityStats{
+				TotalAuto:             1,
+				TotalManual:           1,
+				YearAuto:              1,
+				YearManual:            1,
+				MonthAuto:             1,
+				MonthManual:           1,
+				AutoFrequencyPerDay:   1,
+				ManualFrequencyPerDay: 1,
+				TotalFrequencyPerDay:  0.6, // 2 次 / 3.5 天
+				AutoPeriodDays:        1,
+				ManualPeriodDays:      1,
+				TotalPeriodDays:       1.8, // 1.75 四舍五入
+				EarliestDate:          "2026-10-01",
+				LastTwoInterval:       3,
+				LastTwoAutoInterval:   -1,
+				LastTwoManualInterval: -1,
+				LastIntervalDays:      fp(3.5),
+				LastToNowDays:         fp(0.5),
+			},
+		},
+		{
+			name: "空标签视为手动",
+			points: []models.ActivityPoint{
+				{RecordDate: "2026-10-01", RecordTime: "08:00", Tag: ""},
+				{RecordDate: "2026-10-02", RecordTime: "08:00", Tag: "manual"},
+			},
+			now: "2026-10-03 08:00",
+			want: models.ActivityStats{
+				TotalManual:            2,
+				YearManual:             2,
+				MonthManual:            2,
+				ManualFrequencyPerDay:  2,
+				TotalFrequencyPerDay:   2,
+				ManualPeriodDays:       0.5,
+				TotalPeriodDays:        0.5,
+				EarliestDate:           "2026-10-01",
+				LastTwoInterval:        1,
+				LastTwoAutoInterval:    -1,
+				LastTwoManualInterval:  1,
+				LastIntervalDays:       fp(1),
+				LastManualIntervalDays: fp(1),
+				LastToNowDays:          fp(1),
+			},
+		},
+		{
+			// UTC 仍是 9 月 30 日，东八区已是 10 月 1 日
+			name: "东八区跨月",
+			points: []models.ActivityPoint{
+				{RecordDate: "2025-12-31", RecordTime: "23:00", Tag: "manual"},
+				{RecordDate: "2026-09-30", RecordTime: "23:30", Tag: "auto"},
+				{RecordDate: "2026-10-01", RecordTime: "00:30", Tag: "manual"},
+			},
+			nowUTC: time.Date(2026, 9, 30, 17, 0, 0, 0, time.UTC),
+			want: models.ActivityStats{
+				TotalAuto:              1,
+				TotalManual:            2,
+				YearAuto:               1,
+				YearManual:             1,
+				MonthAuto:              0,
+				MonthManual:            1,
+				AutoFrequencyPerDay:    1,
+				ManualFrequencyPerDay:  0, // 2 次 / 273.06 天
+				TotalFrequencyPerDay:   0,
+				AutoPeriodDays:         1,
+				ManualPeriodDays:       136.5,
+				TotalPeriodDays:        91,
+				EarliestDate:           "2025-12-31",
+				LastTwoInterval:        1,
+				LastTwoAutoInterval:    -1,
+				LastTwoManualInterval:  274,
+				LastIntervalDays:       fp(0), // 1 小时
+				LastManualIntervalDays: fp(273.1),
+				LastToNowDays:          fp(0),
+			},
+		},
+		{
+			name: "小数间隔取一位小数",
+			points: []models.ActivityPoint{
+				{RecordDate: "2026-10-01", RecordTime: "08:00", Tag: "auto"},
+				{RecordDate: "2026-10-02", RecordTime: "12:00", Tag: "auto"},
+				{RecordDate: "2026-10-03", RecordTime: "10:00", Tag: "manual"},
+			},
+			now: "2026-10-03 22:00",
+			want: models.ActivityStats{
+				TotalAuto:             2,
+				TotalManual:           1,
+				YearAuto:              2,
+				YearManual:            1,
+				MonthAuto:             2,
+				MonthManual:           1,
+				AutoFrequencyPerDay:   1.7, // 2 次 / 1.167 天
+				ManualFrequencyPerDay: 1,
+				TotalFrequencyPerDay:  1.4, // 3 次 / 2.083 天
+				AutoPeriodDays:        0.6,
+				ManualPeriodDays:      1,
+				TotalPeriodDays:       0.7,
+				EarliestDate:          "2026-10-01",
+				LastTwoInterval:       1,
+				LastTwoAutoInterval:   1,
+				LastTwoManualInterval: -1,
+				LastIntervalDays:      fp(0.9), // 22 小时
+				LastAutoIntervalDays:  fp(1.2), // 28 小时
+				LastToNowDays:         fp(0.5),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := tt.nowUTC
+			if now.IsZero() {
+				now = shanghaiTime(t, tt.now)
+			}
+			got := buildActivityStats(summarizeActivities(tt.points), now)
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("统计结果不符\n got: %s\nwant: %s", formatStats(got), formatStats(&tt.want))
+			}
+		})
+	}
+}
+
+// formatStats 展开指针字段，便于对比输出
+func formatStats(s *models.ActivityStats) string {
+	deref := func(p *float64) interface{} {
+		if p == nil {
+			return nil
+		}
+		return *p
+	}
+	return fmt.Sprintf("%+v last=%v auto=%v manual=%v now=%v", *s,
+		deref(s.LastIntervalDays), deref(s.LastAutoIntervalDays),
+		deref(s.LastManualIntervalDays), deref(s.LastToNowDays))
+}
+
+func TestGetActivityStatsCache(t *testing.T) {
+	setupTestDB(t)
+	const userID = 1
+	// 缓存是包级状态，清掉其他测试（或 -count 重复运行）留下的结果
+	InvalidateActivityStats(userID)
+	t.Cleanup(func() { InvalidateActivityStats(userID) })
+
+	insert := func(date, tag string) {
+		t.Helper()
+		_, err := database.DB.Exec(
+			"INSERT INTO health_activities (uuid, user_id, record_date, record_time, week_day, duration, tag) VALUES (?, ?, ?, '08:00', '', 10, ?)",
+			utils.NewUUID(), userID, date, tag,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	insert("2026-10-01", "auto")
+	stats, err := GetActivityStats(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalAuto != 1 {
+		t.Fatalf("TotalAuto = %d, want 1", stats.TotalAuto)
+	}
+
+	// 直接写库不会使缓存失效，应仍返回缓存结果
+	insert("2026-10-02", "auto")
+	stats, err = GetActivityStats(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalAuto != 1 {
+		t.Fatalf("缓存未生效: TotalAuto = %d, want 1", stats.TotalAuto)
+	}
+
+	activityStatsCacheMu.RLock()
+	before := activityStatsVersion[userID]
+	activityStatsCacheMu.RUnlock()
+
+	InvalidateActivityStats(userID)
+
+	activityStatsCacheMu.RLock()
+	after := activityStatsVersion[userID]
+	_, cached := activityStatsCache[userID]
+	activityStatsCacheMu.RUnlock()
+	if after != before+1 || cached {
+		t.Fatalf("失效后版本号 = %d（之前 %d），缓存仍存在 = %v", after, before, cached)
+	}
+
+	stats, err = GetActivityStats(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalAuto != 2 {
+		t.Fatalf("失效后未重新计算: TotalAuto = %d, want 2", stats.TotalAuto)
+	}
+}
+
+// 统计、预测、对比、月报对空标签的处理一致（都视为手动）
+func TestActivityTagNormalization(t *testing.T) {
+	points := []models.ActivityPoint{
+		{RecordDate: "2026-10-01", RecordTime: "08:00", Duration: 10, Tag: ""},
+		{RecordDate: "2026-10-02", RecordTime: "08:00", Duration: 10, Tag: "auto"},
+		{RecordDate: "2026-10-03", RecordTime: "08:00", Duration: 10, Tag: "manual"},
+	}
+	now := shanghaiTime(t, "2026-10-04 08:00")
+
+	if got := buildActivityStats(summarizeActivities(points), now).TotalManual; got != 2 {
+		t.Errorf("统计手动次数 = %d, want 2", got)
+	}
+	if p := predictByTag(points, "manual", now); p == nil || p.SampleCount != 1 {
+		t.Errorf("预测手动样本 = %+v, want 1 个间隔", p)
+	}
+	if got := rangeMetrics(points, "manual", 30).count; got != 2 {
+		t.Errorf("对比手动次数 = %v, want 2", got)
+	}
+	if got := summarizePeriod(points).ManualCount; got != 2 {
+		t.Errorf("月报手动次数 = %d, want 2", got)
+	}
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+)
+
+const (
+	// 没有新任务通知时，worker 轮询数据库的间隔
+	douyinJobPollInterval = 5 * time.Second
+	// 遗留任务目录的清理间隔（同时也是目录被视为过期的时长）
+	douyinJobDirCleanupInterval = time.Hour
+)
+
+// 有新任务时唤醒空闲的 worker
+var douyinJobWake = make(chan struct{}, 1)
+
+// ErrDouyinJobNotCancellable 任务已结束，无法取消
+var ErrDouyinJobNotCancellable = errors.New("任务已结束，无法取消")
+
+// 执行中任务的取消函数；cancelRequested 记录在 worker 登记前就收到的取消请求
+var (
+	runningDouyinJobsMu sync.Mutex
+	runningDouyinJobs   = make(map[int]context.CancelFunc)
+	cancelRequested     = make(map[int]bool)
+)
+
+// StartDouyinWorkers 启动抖音下载任务 worker 池：先把服务停止时中断的任务重新排队，再启动 concurrency 个 worker
+func StartDouyinWorkers(concurrency int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	n, err := database.RequeueInterruptedDouyinJobs()
+	if err != nil {
+		log.Printf("重新排队中断的抖音任务失败: %v", err)
+	} else if n > 0 {
+		log.Printf("已重新排队中断的抖音任务: %d 个", n)
+	}
+
+	for i := 0; i < concurrency; i++ {
+		go douyinWorker()
+	}
+
+	// 定期清理异常退出后遗留的任务目录
+	go func() {
+		ticker := time.NewTicker(douyinJobDirCleanupInterval)
+		defer ticker.Stop()
+		for {
+			CleanupStaleJobDirs(douyinJobDirCleanupInterval)
+			<-ticker.C
+		}
+	}()
+	log.Printf("抖音下载 worker 已启动: %d 个", concurrency)
+}
+
+// 提交任务和结束任务时持有，保证同一URL同时只有一个任务在下载，其余任务都能跟随到它
+var douyinSubmitMu sync.Mutex
+
+// SubmitDouyinJob 提交下载任务；同一用户同一作品（或URL）已有未结束的任务时直接返回该任务，
+// 其他用户的同一作品正在下载时，新任务跟随该任务，下载完成后直接复用其文件
+func SubmitDouyinJob(userID int, url, awemeID string) (*models.DouyinJob, error) {
+	urlKey := DouyinURLKey(url, awemeID)
+
+	douyinSubmitMu.Lock()
+	defer douyinSubmitMu.Unlock()
+
+	if job, err := database.GetActiveDouyinJob(userID, urlKey); err == nil {
+		return job, nil
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	leaderID := 0
+	if leader, err := database.GetActiveDouyinLeaderJob(urlKey); err == nil {
+		leaderID = leader.ID
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	job, err := database.CreateDouyinJob(userID, url, awemeID, urlKey, leaderID)
+	if err != nil {
+		return nil, err
+	}
+	database.AppendDouyinJobLog(job.ID, "任务已创建: "+url)
+	if leaderID != 0 {
+		database.AppendDouyinJobLog(job.ID, fmt.Sprintf("同一链接正在下载，等待任务 #%d 完成", leaderID))
+		return job, nil
+	}
+
+	wakeDouyinWorker()
+	return job, nil
+}
+
+func wakeDouyinWorker() {
+	select {
+	case douyinJobWake <- struct{}{}:
+	default:
+	}
+}
+
+// CancelDouyinJob 取消用户的任务：排队中的直接标记为已取消，执行中的结束下载进程，由 worker 记录结果
+func CancelDouyinJob(jobID, userID int) error {
+	cancelled, err := database.CancelQueuedDouyinJob(jobID, userID)
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		// 排队中（或等待重试）的任务可能有跟随者，改由其中最早的任务自己下载
+		douyinSubmitMu.Lock()
+		defer douyinSubmitMu.Unlock()
+		handleCancelledDouyinJob(jobID)
+		return nil
+	}
+
+	job, err := database.GetDouyinJob(jobID, userID)
+	if err != nil {
+		return err
+	}
+	if job.Status != models.DouyinJobRunning {
+		return ErrDouyinJobNotCancellable
+	}
+
+	runningDouyinJobsMu.Lock()
+	defer runningDouyinJobsMu.Unlock()
+	if cancel, ok := runningDouyinJobs[jobID]; ok {
+		cancel()
+	} else {
+		// 任务刚被 worker 领取、尚未登记
+		cancelRequested[jobID] = true
+	}
+	return nil
+}
+
+// trackDouyinJob 登记执行中任务的取消函数，返回带超时的 context 和注销函数
+func trackDouyinJob(jobID int) (context.Context, func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), DownloadTimeout)
+
+	runningDouyinJobsMu.Lock()
+	runningDouyinJobs[jobID] = cancel
+	if cancelRequested[jobID] {
+		delete(cancelRequested, jobID)
+		cancel()
+	}
+	runningDouyinJobsMu.Unlock()
+
+	return ctx, func() {
+		runningDouyinJobsMu.Lock()
+		delete(runningDouyinJobs, jobID)
+		runningDouyinJobsMu.Unlock()
+		cancel()
+	}
+}
+
+func douyinWorker() {
+	for {
+		job, err := database.ClaimNextDouyinJob()
+		if err == sql.ErrNoRows {
+			select {
+			case <-douyinJobWake:
+			case <-time.After(douyinJobPollInterval):
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("获取抖音任务失败: %v", err)
+			time.Sleep(douyinJobPollInterval)
+			continue
+		}
+		runDouyinJob(job)
+	}
+}
+
+// runDouyinJob 执行一次下载并记录执行结果；临时错误在重试次数内重新排队，否则结束任务
+func runDouyinJob(job *models.DouyinJob) {
+	log.Printf("开始执行抖音任务: job_id=%d, user_id=%d, url=%s, attempt=%d", job.ID, job.UserID, job.URL, job.Attempts)
+	database.AppendDouyinJobLog(job.ID, fmt.Sprintf("开始下载（第 %d 次）", job.Attempts))
+
+	ctx, done := trackDouyinJob(job.ID)
+	defer done()
+
+	attempt := &models.DouyinJobAttempt{
+		JobID:     job.ID,
+		Attempt:   job.Attempts,
+		ExitCode:  -1,
+		StartedAt: utils.NowUTCString(),
+	}
+	start := time.Now()
+	status, message, paths := downloadDouyinJob(ctx, job, attempt)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	attempt.Success = status == models.DouyinJobSucceeded
+	attempt.Message = message
+	if err := database.SaveDouyinJobAttempt(attempt); err != nil {
+		log.Printf("记录抖音任务执行失败: job_id=%d, error=%v", job.ID, err)
+	}
+
+	if status == models.DouyinJobFailed && attempt.Transient && job.Attempts <= DouyinRetryMax {
+		scheduleDouyinRetry(job, message)
+		return
+	}
+	finishDouyinJob(job, status, message, paths)
+}
+
+// downloadDouyinJob 在任务独立的输出目录中执行下载，成功后只把该目录中的文件归属到任务的URL和用户；
+// 下载器、退出码、截断后的输出以及是否为临时错误写入 attempt
+func downloadDouyinJob(ctx context.Context, job *models.DouyinJob, attempt *models.DouyinJobAttempt) (string, string, []string) {
+	// 清除中断的上次执行留下的文件
+	jobDir := DouyinJobDir(job.ID)
+	os.RemoveAll(jobDir)
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		return models.DouyinJobFailed, "创建任务目录失败: " + err.Error(), nil
+	}
+	defer os.RemoveAll(jobDir)
+
+	name, result := DownloadDouyinURL(ctx, job.URL, jobDir)
+	attempt.Downloader = name
+	attempt.ExitCode = result.ExitCode
+	attempt.Output = trimOutput(result.Output, douyinAttemptOutputLimit)
+	if name != "" {
+		database.AppendDouyinJobLog(job.ID, "下载器: "+name)
+	}
+	if result.Output != "" {
+		database.AppendDouyinJobLog(job.ID, result.Output)
+	}
+	switch ctx.Err() {
+	case context.Canceled:
+		return models.DouyinJobCancelled, "已取消", nil
+	case context.DeadlineExceeded:
+		attempt.Transient = true
+		return models.DouyinJobFailed, "下载超时（超过 " + DownloadTimeout.String() + "）", nil
+	}
+	if !result.Success {
+		attempt.Transient = isTransientDownloadFailure(result, false)
+		return models.DouyinJobFailed, result.Message, nil
+	}
+
+	paths, err := CollectJobFiles(job.UserID, job.URL, job.AwemeID, jobDir)
+	if err != nil {
+		return models.DouyinJobFailed, "保存文件失败: " + err.Error(), nil
+	}
+	if len(paths) == 0 {
+		return models.DouyinJobFailed, "下载器未输出视频文件", nil
+	}
+	for _, path := range paths {
+		database.AppendDouyinJobLog(job.ID, "已保存: "+path)
+	}
+	if err := database.SaveDouyinURL(job.UserID, job.URL, job.AwemeID); err != nil {
+		log.Printf("保存URL失败: %v", err)
+	}
+	return models.DouyinJobSucceeded, "下载完成", paths
+}
+
+// handleCancelledDouyinJob 任务被用户取消时，跟随的任务不受影响，改由其中最早的任务自己下载；调用方需持有 douyinSubmitMu
+func handleCancelledDouyinJob(jobID int) {
+	if err := database.MarkDouyinURLCancelled(jobID); err != nil {
+		log.Printf("记录URL取消状态失败: job_id=%d, error=%v", jobID, err)
+	}
+	newLeaderID, err := database.PromoteDouyinJobFollowers(jobID)
+	if err != nil {
+		log.Printf("转移跟随任务失败: job_id=%d, error=%v", jobID, err)
+	} else if newLeaderID != 0 {
+		database.AppendDouyinJobLog(newLeaderID, fmt.Sprintf("任务 #%d 已取消，改为自行下载", jobID))
+		wakeDouyinWorker()
+	}
+}
+
+// finishDouyinJob 记录任务结果，并把结果同步给跟随该任务的其他用户的任务
+func finishDouyinJob(job *models.DouyinJob, status, message string, paths []string) {
+	douyinSubmitMu.Lock()
+	defer douyinSubmitMu.Unlock()
+
+	database.AppendDouyinJobLog(job.ID, message)
+	if err := database.FinishDouyinJob(job.ID, status, message); err != nil {
+		log.Printf("更新抖音任务状态失败: job_id=%d, error=%v", job.ID, err)
+	}
+	log.Printf("抖音任务结束: job_id=%d, status=%s, message=%s", job.ID, status, message)
+	if status == models.DouyinJobFailed {
+		if err := database.SaveDouyinURLFailure(job.UserID, job.URL, job.AwemeID, models.DouyinURLFailed, message, job.ID); err != nil {
+			log.Printf("记录URL失败状态失败: %v", err)
+		}
+	}
+
+	if status == models.DouyinJobCancelled {
+		handleCancelledDouyinJob(job.ID)
+		return
+	}
+
+	followers, err := database.GetDouyinJobFollowers(job.ID)
+	if err != nil {
+		log.Printf("获取跟随任务失败: job_id=%d, error=%v", job.ID, err)
+		return
+	}
+	for _, f := range followers {
+		followerStatus, followerMessage := status, message
+		if status == models.DouyinJobSucceeded {
+			if err := database.CreateFileRecordForUser(f.UserID, f.URL, f.AwemeID, paths); err != nil {
+				followerStatus, followerMessage = models.DouyinJobFailed, "创建文件记录失败: "+err.Error()
+			} else {
+				if err := database.SaveDouyinURL(f.UserID, f.URL, f.AwemeID); err != nil {
+					log.Printf("保存URL失败: %v", err)
+				}
+				followerMessage = fmt.Sprintf("下载完成（复用任务 #%d 的文件）", job.ID)
+			}
+		}
+		database.AppendDouyinJobLog(f.ID, followerMessage)
+		if err := database.FinishDouyinJob(f.ID, followerStatus, followerMessage); err != nil {
+			log.Printf("更新抖音任务状态失败: job_id=%d, error=%v", f.ID, err)
+		}
+		if followerStatus == models.DouyinJobFailed {
+			if err := database.SaveDouyinURLFailure(f.UserID, f.URL, f.AwemeID, models.DouyinURLFailed, followerMessage, f.ID); err != nil {
+				log.Printf("记录URL失败状态失败: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"backend/models"
+	"backend/utils"
+)
+
+// 附属元数据 JSON 中各字段的候选键（兼容 yt-dlp 的 .info.json、抖音接口字段和命令模板下载器自定义的 .json）
+var (
+	metaAuthorKeys   = []string{"author", "nickname", "uploader", "creator", "channel"}
+	metaDescKeys     = []string{"desc", "description", "title"}
+	metaCreateKeys   = []string{"create_time", "timestamp"}
+	metaCoverURLKeys = []string{"cover", "cover_url", "thumbnail"}
+)
+
+// 封面图片的候选文件名后缀（f2 为 _cover，yt-dlp --write-thumbnail 与视频同名）
+var (
+	coverSuffixes = []string{"_cover", ""}
+	coverExts     = []string{".jpeg", ".jpg", ".png", ".webp"}
+)
+
+// readSidecarMetadata 读取下载目录中与视频同名的附属文件（.info.json / .json / _desc.txt），
+// 并以视频所在目录名（f2 的 {nickname}）作为作者的默认值
+func readSidecarMetadata(videoPath, rel string, file *models.DouyinFile) {
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+
+	for _, name := range []string{base + ".info.json", base + ".json"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		var meta map[string]interface{}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			log.Printf("解析元数据文件失败: %s, %v", name, err)
+			continue
+		}
+		applyJSONMetadata(meta, file)
+		break
+	}
+
+	if file.Description == "" {
+		if data, err := os.ReadFile(base + "_desc.txt"); err == nil {
+			file.Description = strings.TrimSpace(string(data))
+		}
+	}
+	if file.Author == "" {
+		if dir := filepath.Dir(filepath.FromSlash(rel)); dir != "." {
+			file.Author = filepath.Base(dir)
+		}
+	}
+}
+
+func applyJSONMetadata(meta map[string]interface{}, file *models.DouyinFile) {
+	file.Author = metaString(meta, metaAuthorKeys)
+	file.Description = metaString(meta, metaDescKeys)
+	file.CoverURL = metaString(meta, metaCoverURLKeys)
+	if !strings.HasPrefix(file.CoverURL, "http") {
+		file.CoverURL = ""
+	}
+
+	for _, key := range metaCreateKeys {
+		if ts, ok := meta[key].(float64); ok && ts > 0 {
+			file.CreateTime = time.Unix(int64(ts), 0).In(utils.GetShanghaiTZ()).Format("2006-01-02 15:04:05")
+			break
+		}
+	}
+	if file.CreateTime == "" {
+		// yt-dlp 的 upload_date 为 YYYYMMDD
+		if d, err := time.Parse("20060102", metaString(meta, []string{"upload_date"})); err == nil {
+			file.CreateTime = d.Format("2006-01-02")
+		}
+	}
+
+	if v, ok := meta["duration"].(float64); ok && v > 0 {
+		file.Duration = v
+	}
+	if v, ok := meta["width"].(float64); ok {
+		file.Width = int(v)
+	}
+	if v, ok := meta["height"].(float64); ok {
+		file.Height = int(v)
+	}
+}
+
+func metaString(meta map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		if v, ok := meta[key].(string); ok && strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// moveCoverIntoLibrary 把与视频同名的封面图片移到视频在下载根目录中的位置旁，返回封面路径（没有封面时为空）
+func moveCoverIntoLibrary(videoPath, target string) string {
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	for _, suffix := range coverSuffixes {
+		for _, ext := range coverExts {
+			src := base + suffix + ext
+			info, err := os.Stat(src)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			coverTarget := strings.TrimSuffix(target, filepath.Ext(target)) + "_cover" + ext
+			cover, err := moveIntoLibrary(src, coverTarget, info.Size())
+			if err != nil {
+				log.Printf("保存封面失败: %s, %v", src, err)
+				return ""
+			}
+			return cover
+		}
+	}
+	return ""
+}
+
+// applyMP4Info 用容器中的时长和分辨率覆盖附属文件中的值（容器解析失败时保留附属文件的值）
+func applyMP4Info(path string, file *models.DouyinFile) {
+	if !strings.EqualFold(filepath.Ext(path), ".mp4") && !strings.EqualFold(filepath.Ext(path), ".mov") {
+		return
+	}
+	info, err := ParseMP4Info(path)
+	if err != nil {
+		log.Printf("解析视频容器失败: %s, %v", path, err)
+		return
+	}
+	if info.Duration > 0 {
+		file.Duration = math.Round(info.Duration*1000) / 1000
+	}
+	if info.Width > 0 && info.Height > 0 {
+		file.Width, file.Height = info.Width, info.Height
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+)
+
+// PromoteDouyinFile 把用户的抖音视频放入文件传输库（uploadDir 下），之后可使用文件传输的列表、下载和分享功能。
+// 优先创建硬链接（不占用额外空间，删除任一侧都不影响另一侧），跨文件系统等无法链接时复制文件。
+func PromoteDouyinFile(userID, fileID int, uploadDir string) (*models.FileTransfer, bool, error) {
+	record, err := database.GetDouyinFileByID(fileID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return nil, false, err
+	}
+
+	// 与上传文件相同的命名方式：{用户ID}_{原文件名}_{时间戳}{扩展名}
+	ext := filepath.Ext(record.FileName)
+	base := fmt.Sprintf("%d_%s_%s", userID, strings.TrimSuffix(record.FileName, ext), utils.NowTimestamp())
+	target := filepath.Join(uploadDir, base+ext)
+	var linked bool
+	for i := 1; ; i++ {
+		linked, err = linkOrCopyFile(record.Path, target)
+		if !os.IsExist(err) {
+			break
+		}
+		target = filepath.Join(uploadDir, fmt.Sprintf("%s_%d%s", base, i, ext))
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		os.Remove(target)
+		return nil, false, err
+	}
+
+	fileType := strings.ToLower(strings.TrimPrefix(ext, "."))
+	if fileType == "" {
+		fileType = "unknown"
+	}
+	transfer := &models.FileTransfer{
+		UserID:    userID,
+		FileName:  record.FileName,
+		FilePath:  target,
+		FileSize:  info.Size(),
+		FileType:  fileType,
+		CreatedAt: utils.NowUTCString(),
+	}
+	if err := database.SaveFileTransfer(transfer); err != nil {
+		os.Remove(target)
+		return nil, false, err
+	}
+
+	log.Printf("抖音视频已加入文件传输: douyin_file_id=%d, file_id=%d, linked=%v", fileID, transfer.ID, linked)
+	return transfer, linked, nil
+}
+
+// linkOrCopyFile 在 dst 创建 src 的硬链接，失败时复制内容；dst 已存在时返回 os.ErrExist 类错误
+func linkOrCopyFile(src, dst string) (bool, error) {
+	err := os.Link(src, dst)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsExist(err) {
+		return false, err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return false, err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return false, err
+	}
+	return false, nil
+}
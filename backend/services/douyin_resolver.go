@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAwemeIDNotFound 链接中及重定向后都没有作品ID
+var ErrAwemeIDNotFound = errors.New("未能解析出作品ID")
+
+// DouyinHTTPClient 解析分享短链使用的 HTTP 客户端，可替换（如测试时把请求转发到本地服务）
+var DouyinHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// DouyinResolveBudget 一次提交的所有链接解析作品ID的总时长，超时未解析出的链接按URL去重
+var DouyinResolveBudget = 5 * time.Second
+
+const (
+	// 短链解析最多跟随的重定向次数
+	douyinMaxRedirects = 10
+	// 同时解析的短链数
+	douyinResolveConcurrency = 8
+)
+
+// 链接中的作品ID：/video/{id}、/note/{id}、/share/video/{id}，或 modal_id / aweme_id 等查询参数
+var awemeIDPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`/(?:video|note|slides)/(\d{8,})`),
+	regexp.MustCompile(`[?&](?:modal_id|aweme_id|item_ids?|vid)=(\d{8,})`),
+}
+
+// awemeIDFromURL 从链接文本中提取作品ID，没有时返回空字符串
+func awemeIDFromURL(rawURL string) string {
+	for _, re := range awemeIDPatterns {
+		if m := re.FindStringSubmatch(rawURL); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// isDouyinHost 是否为抖音域名（只对这些域名的短链发起请求）
+func isDouyinHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range []string{"douyin.com", "iesdouyin.com"} {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveAwemeIDs 并发解析多个链接的作品ID，所有链接共用 DouyinResolveBudget 的时长；
+// 解析失败或超时的链接返回空字符串（之后按URL去重）
+func ResolveAwemeIDs(ctx context.Context, urls []string) []string {
+	ctx, cancel := context.WithTimeout(ctx, DouyinResolveBudget)
+	defer cancel()
+
+	ids := make([]string, len(urls))
+	sem := make(chan struct{}, douyinResolveConcurrency)
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		// 链接本身包含作品ID时无需请求
+		if id := awemeIDFromURL(u); id != "" {
+			ids[i] = id
+			continue
+		}
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				log.Printf("解析作品ID超时: %s", u)
+				return
+			}
+			id, err := ResolveAwemeID(ctx, u)
+			if err != nil {
+				log.Printf("解析作品ID失败: %s, %v", u, err)
+				return
+			}
+			ids[i] = id
+		}(i, u)
+	}
+	wg.Wait()
+	return ids
+}
+
+// ResolveAwemeID 获取链接对应的作品ID：链接本身包含ID时直接提取，否则跟随短链的重定向，直到地址中出现作品ID。
+// 只跟随抖音域名之间的重定向
+func ResolveAwemeID(ctx context.Context, rawURL string) (string, error) {
+	if id := awemeIDFromURL(rawURL); id != "" {
+		return id, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || !isDouyinHost(u.Hostname()) {
+		return "", ErrAwemeIDNotFound
+	}
+
+	var found string
+	client := *DouyinHTTPClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !isDouyinHost(req.URL.Hostname()) {
+			return errors.New("重定向到非抖音域名: " + req.URL.Hostname())
+		}
+		if id := awemeIDFromURL(req.URL.String()); id != "" {
+			found = id
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= douyinMaxRedirects {
+			return errors.New("重定向次数过多")
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	// 桌面端 UA 会被重定向到需要 JS 的页面，使用移动端 UA 直接拿到作品地址
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if found != "" {
+		return found, nil
+	}
+	if id := awemeIDFromURL(resp.Request.URL.String()); id != "" {
+		return id, nil
+	}
+	return "", ErrAwemeIDNotFound
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubTransport 把所有请求转发到本地测试服务器，并记录请求过的主机名
+type stubTransport struct {
+	target *url.URL
+
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	s.hosts = append(s.hosts, req.URL.Host)
+	s.mu.Unlock()
+
+	out := req.Clone(req.Context())
+	out.URL.Scheme = s.target.Scheme
+	out.URL.Host = s.target.Host
+	out.Host = req.URL.Host
+	return http.DefaultTransport.RoundTrip(out)
+}
+
+func (s *stubTransport) requested(host string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range s.hosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// startDouyinStub 启动模拟抖音短链跳转的本地服务器，并让 DouyinHTTPClient 的请求都发到这里
+func startDouyinStub(t *testing.T) *stubTransport {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host + r.URL.Path {
+		case "v.douyin.com/abc/":
+			http.Redirect(w, r, "https://www.iesdouyin.com/share/video/7300000000000000001/?region=CN", http.StatusFound)
+		case "v.douyin.com/hop/":
+			http.Redirect(w, r, "https://www.douyin.com/jump", http.StatusFound)
+		case "www.douyin.com/jump":
+			http.Redirect(w, r, "/video/7300000000000000002", http.StatusFound)
+		case "v.douyin.com/evil/":
+			http.Redirect(w, r, "https://evil.example.com/video/7300000000000000003", http.StatusFound)
+		case "v.douyin.com/evil2/":
+			http.Redirect(w, r, "https://evil.example.com/landing", http.StatusFound)
+		case "v.douyin.com/slow/":
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	target, _ := url.Parse(srv.URL)
+	stub := &stubTransport{target: target}
+	old := DouyinHTTPClient
+	DouyinHTTPClient = &http.Client{Transport: stub, Timeout: 10 * time.Second}
+	t.Cleanup(func() { DouyinHTTPClient = old })
+	return stub
+}
+
+func TestResolveAwemeID(t *testing.T) {
+	stub := startDouyinStub(t)
+
+	tests := []struct {
+		name    string
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{"链接包含作品ID", "https://www.douyin.com/video/7300000000000000009", "7300000000000000009", false},
+		{"短链跳转到作品页", "https://v.douyin.com/abc/", "7300000000000000001", false},
+		{"多次跳转", "https://v.douyin.com/hop/", "7300000000000000002", false},
+		{"跳转到非抖音域名", "https://v.douyin.com/evil/", "", true},
+		{"跳转到非抖音域名（无作品ID）", "https://v.douyin.com/evil2/", "", true},
+		{"非抖音域名不发起请求", "https://example.com/abc", "", true},
+		{"没有作品ID", "https://v.douyin.com/missing/", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveAwemeID(context.Background(), tt.url)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ResolveAwemeID(%s) = %q, %v; want %q, err=%v", tt.url, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	for _, host := range []string{"evil.example.com", "example.com"} {
+		if stub.requested(host) {
+			t.Errorf("不应请求非抖音域名: %s", host)
+		}
+	}
+}
+
+func TestResolveAwemeIDsBudget(t *testing.T) {
+	startDouyinStub(t)
+	old := DouyinResolveBudget
+	DouyinResolveBudget = 300 * time.Millisecond
+	t.Cleanup(func() { DouyinResolveBudget = old })
+
+	urls := []string{
+		"https://v.douyin.com/abc/",
+		"https://v.douyin.com/slow/",
+		"https://www.douyin.com/video/7300000000000000009",
+		"https://v.douyin.com/slow/?2",
+		"https://v.douyin.com/slow/?3",
+	}
+	start := time.Now()
+	got := ResolveAwemeIDs(context.Background(), urls)
+	elapsed := time.Since(start)
+
+	want := []string{"7300000000000000001", "", "7300000000000000009", "", ""}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: got %q, want %q", urls[i], got[i], want[i])
+		}
+	}
+	// 慢链接共用一个时长上限，而不是逐个等待
+	if elapsed > 2*time.Second {
+		t.Errorf("解析耗时 %v，超出时长上限", elapsed)
+	}
+}
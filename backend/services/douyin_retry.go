@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"backend/database"
+	"backend/models"
+)
+
+// 自动重试：临时错误（网络、限流、超时等）按指数退避重新排队，超过次数后标记为失败
+var (
+	DouyinRetryMax       = 3                // 最多自动重试次数，0 表示不自动重试
+	DouyinRetryBaseDelay = 30 * time.Second // 第一次重试的等待时间，之后每次翻倍
+)
+
+// 单次重试等待时间的上限
+const douyinRetryMaxDelay = time.Hour
+
+// 每次执行记录中保存的输出上限（完整输出见任务日志）
+const douyinAttemptOutputLimit = 8 * 1024
+
+// 下载器输出中表示临时错误的特征
+var transientDownloadPattern = regexp.MustCompile(`(?i)timed? ?out|timeout|connection (reset|refused|aborted)|temporary failure|name resolution|network is unreachable|remote end closed|http error (429|5\d\d)|too many requests|超时|网络`)
+
+// isTransientDownloadFailure 判断失败是否可能在稍后重试时成功
+func isTransientDownloadFailure(result *DownloadResult, timedOut bool) bool {
+	if timedOut {
+		return true
+	}
+	if result == nil || result.Success {
+		return false
+	}
+	return transientDownloadPattern.MatchString(result.Message) || transientDownloadPattern.MatchString(result.Output)
+}
+
+// douyinRetryDelay 第 attempt 次执行失败后等待的时间：DouyinRetryBaseDelay * 2^(attempt-1)，不超过上限
+func douyinRetryDelay(attempt int) time.Duration {
+	delay := DouyinRetryBaseDelay
+	for i := 1; i < attempt && delay < douyinRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > douyinRetryMaxDelay {
+		delay = douyinRetryMaxDelay
+	}
+	return delay
+}
+
+// scheduleDouyinRetry 把临时失败的任务重新排队，跟随该任务的其他任务继续等待
+func scheduleDouyinRetry(job *models.DouyinJob, message string) {
+	douyinSubmitMu.Lock()
+	defer douyinSubmitMu.Unlock()
+
+	delay := douyinRetryDelay(job.Attempts)
+	retryMessage := fmt.Sprintf("%s（%s 后第 %d 次重试）", message, delay, job.Attempts)
+	database.AppendDouyinJobLog(job.ID, retryMessage)
+	if err := database.RetryDouyinJobLater(job.ID, delay, retryMessage); err != nil {
+		log.Printf("重新排队抖音任务失败: job_id=%d, error=%v", job.ID, err)
+		return
+	}
+	log.Printf("抖音任务将自动重试: job_id=%d, attempt=%d, delay=%s, message=%s", job.ID, job.Attempts, delay, message)
+
+	if err := database.SaveDouyinURLFailure(job.UserID, job.URL, job.AwemeID, models.DouyinURLRetrying, message, job.ID); err != nil {
+		log.Printf("记录URL失败状态失败: %v", err)
+	}
+	followers, err := database.GetDouyinJobFollowers(job.ID)
+	if err != nil {
+		log.Printf("获取跟随任务失败: job_id=%d, error=%v", job.ID, err)
+		return
+	}
+	for _, f := range followers {
+		database.AppendDouyinJobLog(f.ID, fmt.Sprintf("任务 #%d 下载失败，等待自动重试", job.ID))
+		if err := database.SaveDouyinURLFailure(f.UserID, f.URL, f.AwemeID, models.DouyinURLRetrying, message, f.ID); err != nil {
+			log.Printf("记录URL失败状态失败: %v", err)
+		}
+	}
+}
+
+// RetryDouyinURL 手动重试用户下载失败的URL：重新提交下载任务；已有等待自动重试的任务时让它立即执行
+func RetryDouyinURL(id, userID int) (*models.DouyinJob, error) {
+	failed, err := database.GetUserFailedDouyinURL(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := SubmitDouyinJob(userID, failed.URL, failed.AwemeID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == models.DouyinJobQueued {
+		target := job.ID
+		if job.LeaderID != 0 {
+			target = job.LeaderID
+		}
+		if err := database.RetryDouyinJobNow(target); err != nil {
+			log.Printf("取消重试等待失败: job_id=%d, error=%v", target, err)
+		}
+		wakeDouyinWorker()
+	}
+	database.AppendDouyinJobLog(job.ID, "手动重试")
+
+	if err := database.SaveDouyinURLFailure(userID, failed.URL, failed.AwemeID, models.DouyinURLRetrying, failed.LastError, job.ID); err != nil {
+		log.Printf("记录URL失败状态失败: %v", err)
+	}
+	return database.GetDouyinJob(job.ID, userID)
+}
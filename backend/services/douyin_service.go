@@ -1,12 +1,15 @@
 package services
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"log"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
-	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,171 +18,276 @@ import (
 	"backend/utils"
 )
 
-// 提取URL
-func ExtractURL(text string) (string, error) {
-	urlRegex := regexp.MustCompile(`(https?://[\w./?=&%-]+)`)
-	matches := urlRegex.FindStringSubmatch(text)
-	if len(matches) == 0 {
-		return "", fmt.Errorf("未解析到正确链接")
-	}
-	return matches[0], nil
-}
+var urlRegex = regexp.MustCompile(`(https?://[\w./?=&%-]+)`)
 
-// Linux解析
-func LinuxParsing(url string) (*models.DouyinParsingResponse, error) {
-	// 构建f2命令
-	f2Cmd := fmt.Sprintf("f2 dy -M one -u %s -n {nickname}_{create}", url)
-	// 使用conda run命令，在douyi_download环境中执行
-	cmdStr := fmt.Sprintf("/root/miniconda3/bin/conda run -n douyi_download %s", f2Cmd)
-	cmd := exec.Command("/bin/bash", "-c", cmdStr)
-
-	// 清除代理环境变量
-	cmd.Env = os.Environ()
-	newEnv := []string{}
-	for _, env := range cmd.Env {
-		if !strings.HasPrefix(env, "http_proxy=") &&
-			!strings.HasPrefix(env, "https_proxy=") &&
-			!strings.HasPrefix(env, "HTTP_PROXY=") &&
-			!strings.HasPrefix(env, "HTTPS_PROXY=") {
-			newEnv = append(newEnv, env)
-		}
-	}
-	newEnv = append(newEnv, "PYTHONIOENCODING=utf-8")
-	cmd.Env = newEnv
-
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
-
-	// 即使退出码不是0，只要输出中包含"当前任务处理完成"就认为成功
-	if strings.Contains(outputStr, "当前任务处理完成") {
-		return &models.DouyinParsingResponse{
-			Success: true,
-			Message: "解析成功",
-			Data:    url,
-		}, nil
-	}
-
-	// 如果输出中没有成功标识，返回错误
-	if err != nil {
-		return nil, fmt.Errorf("执行命令失败: %v, 输出: %s", err, outputStr)
+// ExtractURLs 提取文本中的所有URL，按规范化URL去重并保持出现顺序
+func ExtractURLs(text string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, u := range urlRegex.FindAllString(text, -1) {
+		key := NormalizeDouyinURL(u)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		urls = append(urls, u)
 	}
-
-	return &models.DouyinParsingResponse{
-		Success: false,
-		Message: "执行命令失败，未找到完成标识",
-	}, nil
+	return urls
 }
 
-// Windows解析
-func WindowsParsing(url string) (*models.DouyinParsingResponse, error) {
-	// 直接使用conda命令，假设conda已在PATH中
-	// 构建f2命令，注意引号的处理：在Windows cmd中，外层用单引号或不用引号，内层用双引号
-	f2Cmd := fmt.Sprintf(`f2 dy -M one -u %s -n {nickname}_{create}`, url)
-
-	// 使用conda run命令，这是更现代和可靠的方式
-	cmdStr := fmt.Sprintf(`conda run -n douyi_download %s`, f2Cmd)
-	cmd := exec.Command("cmd.exe", "/c", cmdStr)
+// ParseDouyinLink 处理单个链接：用户或其他用户已下载过同一作品且文件存在时直接复用，否则加入下载队列。
+// awemeID 为事先解析出的作品ID（见 ResolveAwemeIDs），为空时只从链接文本中提取
+func ParseDouyinLink(userID int, url, awemeID string) (*models.DouyinParsingItem, error) {
+	// 短链和完整链接指向同一作品时，按作品ID去重；解析失败时只按URL去重
+	if awemeID == "" {
+		awemeID = awemeIDFromURL(url)
+	}
+	item := &models.DouyinParsingItem{URL: url, AwemeID: awemeID}
 
-	// 清除代理环境变量
-	cmd.Env = os.Environ()
-	newEnv := []string{}
-	for _, env := range cmd.Env {
-		if !strings.HasPrefix(env, "http_proxy=") &&
-			!strings.HasPrefix(env, "https_proxy=") &&
-			!strings.HasPrefix(env, "HTTP_PROXY=") &&
-			!strings.HasPrefix(env, "HTTPS_PROXY=") {
-			newEnv = append(newEnv, env)
+	// 先检查该用户是否已解析过此URL且文件存在
+	urlExists, err := database.URLExists(userID, url, awemeID)
+	if err != nil {
+		log.Printf("检查URL是否存在失败: %v", err)
+	} else if urlExists {
+		// 该用户已解析过，检查文件是否存在
+		filesExist, err := database.CheckURLFilesExist(userID, url, awemeID)
+		if err != nil {
+			log.Printf("检查文件是否存在失败: %v", err)
+		} else if filesExist {
+			log.Printf("用户已解析过此URL且文件存在，直接返回成功: %s, 用户ID: %d", url, userID)
+			item.Status = models.DouyinJobSucceeded
+			item.Message = "解析成功（文件已存在）"
+			return item, nil
 		}
 	}
-	newEnv = append(newEnv, "PYTHONIOENCODING=utf-8")
-	cmd.Env = newEnv
 
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
-
-	// 即使退出码不是0，只要输出中包含"当前任务处理完成"就认为成功
-	if strings.Contains(outputStr, "当前任务处理完成") {
-		return &models.DouyinParsingResponse{
-			Success: true,
-			Message: "解析成功",
-			Data:    url,
-		}, nil
+	// 检查URL是否已被任何用户解析过且文件存在
+	parsedAndExists, paths, err := database.CheckURLParsedAndFileExists(url, awemeID)
+	if err != nil {
+		log.Printf("检查URL是否已解析失败: %v", err)
+	} else if parsedAndExists {
+		// URL已被解析过且文件存在，为当前用户创建URL和文件记录（均保证唯一性）
+		log.Printf("URL已被其他用户解析过且文件存在，直接返回成功: %s, 用户ID: %d", url, userID)
+		if err := database.SaveDouyinURL(userID, url, awemeID); err != nil {
+			log.Printf("保存URL失败: %v", err)
+		}
+		if err := database.CreateFileRecordForUser(userID, url, awemeID, paths); err != nil {
+			log.Printf("为用户创建文件记录失败: %v", err)
+		}
+		item.Status = models.DouyinJobSucceeded
+		item.Message = "解析成功（使用已有文件）"
+		return item, nil
 	}
 
-	// 如果输出中没有成功标识，返回错误
+	// URL未被解析过或文件不存在，加入下载队列，客户端通过任务ID查询进度
+	job, err := SubmitDouyinJob(userID, url, awemeID)
 	if err != nil {
-		return nil, fmt.Errorf("执行命令失败: %v, 输出: %s", err, outputStr)
+		return nil, err
 	}
+	log.Printf("抖音链接已加入下载队列: %s, 用户ID: %d, 任务ID: %d", url, userID, job.ID)
 
-	return &models.DouyinParsingResponse{
-		Success: false,
-		Message: "执行命令失败，未找到完成标识",
-	}, nil
+	item.Status = job.Status
+	item.Message = "已加入下载队列"
+	item.JobID = job.ID
+	return item, nil
 }
 
-// 解析抖音链接
-func ParseDouyinURL(text string) (*models.DouyinParsingResponse, error) {
-	url, err := ExtractURL(text)
-	if err != nil {
-		return nil, err
+// DouyinURLKey 任务去重键：能解析出作品ID时按作品ID，否则按规范化后的URL
+func DouyinURLKey(url, awemeID string) string {
+	if awemeID != "" {
+		return "aweme:" + awemeID
 	}
+	return NormalizeDouyinURL(url)
+}
 
-	if runtime.GOOS == "windows" {
-		return WindowsParsing(url)
-	} else if runtime.GOOS == "linux" {
-		return LinuxParsing(url)
+// NormalizeDouyinURL 规范化URL用于判断是否为同一链接：协议和主机名小写，去掉查询参数（分享追踪参数）、锚点和末尾的斜杠
+func NormalizeDouyinURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(rawURL)
 	}
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + strings.TrimRight(u.EscapedPath(), "/")
+}
+
+const (
+	// 下载文件的根目录：Download/douyin/one/{nickname}/
+	douyinDownloadRoot = "Download/douyin/one"
+	// 每个下载任务独立的输出目录的根目录
+	douyinJobDirRoot = "Download/douyin/jobs"
+)
 
-	return nil, fmt.Errorf("不支持的操作系统")
+// DouyinJobDir 下载任务的输出目录
+func DouyinJobDir(jobID int) string {
+	return filepath.Join(douyinJobDirRoot, strconv.Itoa(jobID))
 }
 
-// 扫描下载目录并保存文件信息到数据库
-// 只保存最近下载的文件（通过文件修改时间判断）
-func ScanAndSaveFiles(userID int, url string) error {
-	// f2默认下载路径：Download/douyin/one/{nickname}/
-	downloadPath := "Download/douyin/one"
+// CollectJobFiles 把任务输出目录中下载的视频移入下载根目录，并只为这些文件创建该用户和URL的记录，返回文件路径
+func CollectJobFiles(userID int, url, awemeID, jobDir string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(jobDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isVideoFile(path) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(jobDir, path)
+		if err != nil {
+			return err
+		}
+		// f2 会在输出目录下再创建 douyin/one/{nickname}/，去掉这一层以保持与原目录结构一致
+		rel = strings.TrimPrefix(filepath.ToSlash(rel), "douyin/one/")
 
-	// 获取当前时间，只处理最近5分钟内修改的文件（新下载的文件）
-	cutoffTime := utils.NowUTC().Add(-5 * time.Minute)
+		var meta models.DouyinFile
+		readSidecarMetadata(path, rel, &meta)
 
-	return filepath.Walk(downloadPath, func(path string, info os.FileInfo, err error) error {
+		target, err := moveIntoLibrary(path, filepath.Join(douyinDownloadRoot, filepath.FromSlash(rel)), info.Size())
 		if err != nil {
-			return nil // 忽略错误，继续扫描
+			return err
 		}
+		applyMP4Info(target, &meta)
+		meta.CoverPath = moveCoverIntoLibrary(path, target)
 
-		// 只处理视频文件
-		if !info.IsDir() && isVideoFile(path) {
-			// 只处理最近修改的文件（新下载的文件）
-			if info.ModTime().Before(cutoffTime) {
-				return nil // 文件太旧，跳过
-			}
+		targetInfo, err := os.Stat(target)
+		if err != nil {
+			return err
+		}
+		file := models.DouyinFile{
+			UserID:       userID,
+			URL:          url,
+			AwemeID:      awemeID,
+			FileName:     targetInfo.Name(),
+			FileSize:     targetInfo.Size(),
+			FileSizeStr:  formatFileSize(targetInfo.Size()),
+			ModifiedTime: targetInfo.ModTime().Format("2006-01-02 15:04:05"),
+			Path:         target,
+			CreatedAt:    utils.NowUTCString(), // 存储 UTC 时间
+			Author:       meta.Author,
+			Description:  meta.Description,
+			CreateTime:   meta.CreateTime,
+			Duration:     meta.Duration,
+			Width:        meta.Width,
+			Height:       meta.Height,
+			CoverPath:    meta.CoverPath,
+			CoverURL:     meta.CoverURL,
+		}
+		if err := database.SaveDouyinFile(&file); err != nil {
+			return err
+		}
+		paths = append(paths, target)
+		return nil
+	})
+	return paths, err
+}
 
-			// 检查该用户是否已有此文件记录（且URL匹配）
-			exists, err := database.FileExistsWithURL(userID, path, url)
+// moveIntoLibrary 把下载的文件移动到目标路径；目标已存在且内容完全相同时视为同一视频，直接复用已有文件，
+// 否则在文件名后追加序号。用硬链接占用目标文件名（已存在时失败），多个任务同时入库也不会互相覆盖
+func moveIntoLibrary(src, target string, size int64) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(target)
+	base := strings.TrimSuffix(target, ext)
+	for i := 1; ; {
+		err := os.Link(src, target)
+		if err == nil {
+			os.Remove(src)
+			return target, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+
+		existing, err := os.Stat(target)
+		if os.IsNotExist(err) {
+			continue // 已有文件刚被删除，重试同一个文件名
+		}
+		if err != nil {
+			return "", err
+		}
+		if existing.Size() == size {
+			same, err := sameFileContent(src, target)
 			if err != nil {
-				return nil // 忽略错误
+				return "", err
 			}
-			if exists {
-				return nil // 该用户已存在此文件记录，跳过
+			if same {
+				os.Remove(src)
+				return target, nil
 			}
+		}
+		target = fmt.Sprintf("%s_%d%s", base, i, ext)
+		i++
+	}
+}
 
-			// 保存文件信息（包含URL）
-			file := models.DouyinFile{
-				UserID:       userID,
-				URL:          url,
-				FileName:     info.Name(),
-				FileSize:     info.Size(),
-				FileSizeStr:  formatFileSize(info.Size()),
-				ModifiedTime: info.ModTime().Format("2006-01-02 15:04:05"),
-				Path:         path,
-				CreatedAt:    utils.NowUTCString(), // 存储 UTC 时间
-			}
+// sameFileContent 逐字节比较两个文件的内容是否相同
+func sameFileContent(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
 
-			return database.SaveDouyinFile(&file)
+	bufA := make([]byte, 64*1024)
+	bufB := make([]byte, 64*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
 		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+		if endA || endB {
+			return endA && endB, nil
+		}
+	}
+}
 
-		return nil
-	})
+// CleanupStaleJobDirs 删除不属于执行中任务、且超过 maxAge 未修改的任务输出目录
+func CleanupStaleJobDirs(maxAge time.Duration) {
+	entries, err := os.ReadDir(douyinJobDirRoot)
+	if err != nil {
+		return
+	}
+	running, err := database.GetRunningDouyinJobIDs()
+	if err != nil {
+		log.Printf("查询执行中的抖音任务失败: %v", err)
+		return
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if id, err := strconv.Atoi(entry.Name()); err == nil && running[id] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		dir := filepath.Join(douyinJobDirRoot, entry.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("清理任务目录失败: %s, error=%v", dir, err)
+			continue
+		}
+		log.Printf("已清理过期任务目录: %s", dir)
+	}
 }
 
 // 判断是否为视频文件
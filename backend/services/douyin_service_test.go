@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestMoveIntoLibrary(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string // 目标路径上已有的文件内容
+		download string
+		wantName string
+	}{
+		{"目标不存在", "", "video-a", "video.mp4"},
+		{"内容相同复用已有文件", "video-a", "video-a", "video.mp4"},
+		{"大小相同内容不同", "video-a", "video-b", "video_1.mp4"},
+		{"大小不同", "video-a", "video-long", "video_1.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			target := filepath.Join(dir, "lib", "video.mp4")
+			if tt.existing != "" {
+				os.MkdirAll(filepath.Dir(target), 0755)
+				if err := os.WriteFile(target, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			src := filepath.Join(dir, "download.mp4")
+			if err := os.WriteFile(src, []byte(tt.download), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := moveIntoLibrary(src, target, int64(len(tt.download)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filepath.Base(got) != tt.wantName {
+				t.Errorf("目标文件 = %s, want %s", filepath.Base(got), tt.wantName)
+			}
+			content, err := os.ReadFile(got)
+			if err != nil || string(content) != tt.download {
+				t.Errorf("目标文件内容 = %q, %v, want %q", content, err, tt.download)
+			}
+			if tt.existing != "" && got != target {
+				if old, _ := os.ReadFile(target); string(old) != tt.existing {
+					t.Errorf("已有文件被覆盖: %q", old)
+				}
+			}
+			// 无论移动还是复用，下载的临时文件都不应残留
+			if _, err := os.Stat(src); err == nil {
+				t.Errorf("临时文件未清理: %s", src)
+			}
+		})
+	}
+}
+
+// 多个任务同时入库同名但内容不同的文件，每个文件都应得到自己的文件名
+func TestMoveIntoLibraryConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "lib", "video.mp4")
+
+	const n = 16
+	var wg sync.WaitGroup
+	got := make([]string, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		src := filepath.Join(dir, fmt.Sprintf("download-%d.mp4", i))
+		content := fmt.Sprintf("video-%d", i)
+		if err := os.WriteFile(src, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i], errs[i] = moveIntoLibrary(src, target, int64(len(content)))
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if seen[got[i]] {
+			t.Errorf("多个文件入库到同一路径: %s", got[i])
+		}
+		seen[got[i]] = true
+		if content, _ := os.ReadFile(got[i]); string(content) != fmt.Sprintf("video-%d", i) {
+			t.Errorf("%s 内容 = %q, want video-%d", got[i], content, i)
+		}
+	}
+}
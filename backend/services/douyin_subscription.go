@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+)
+
+const (
+	// 调度器检查是否有到期订阅的间隔
+	douyinSubscriptionTick = time.Minute
+	// 单次列出主页作品的超时时间
+	douyinSubscriptionListTimeout = 2 * time.Minute
+)
+
+// ErrProfileListerNotConfigured 未配置作品列表实现
+var ErrProfileListerNotConfigured = errors.New("未配置作品列表实现")
+
+// 同一时间只检查一个订阅，避免定时检查和手动检查重复提交同一作品
+var douyinSubscriptionCheckMu sync.Mutex
+
+// StartDouyinSubscriptionScheduler 启动订阅调度：每分钟找出距上次检查超过 interval 的未暂停订阅并检查
+func StartDouyinSubscriptionScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(douyinSubscriptionTick)
+		defer ticker.Stop()
+		for {
+			checkDueDouyinSubscriptions(interval)
+			<-ticker.C
+		}
+	}()
+	log.Printf("创作者订阅调度已启动，检查间隔: %s", interval)
+}
+
+func checkDueDouyinSubscriptions(interval time.Duration) {
+	before := time.Now().UTC().Add(-interval).Format("2006-01-02 15:04:05")
+	subs, err := database.GetDueDouyinSubscriptions(before)
+	if err != nil {
+		log.Printf("查询待检查的订阅失败: %v", err)
+		return
+	}
+	for i := range subs {
+		added, err := CheckDouyinSubscription(&subs[i])
+		if err != nil {
+			log.Printf("检查订阅失败: subscription_id=%d, error=%v", subs[i].ID, err)
+		} else if added > 0 {
+			log.Printf("订阅发现新作品: subscription_id=%d, 数量=%d", subs[i].ID, added)
+		}
+	}
+}
+
+// CheckDouyinSubscription 列出主页作品，把未记录过的作品加入下载队列，返回加入的数量。
+// 首次成功列出作品时已有的作品只记录为 baseline，不下载
+func CheckDouyinSubscription(sub *models.DouyinSubscription) (int, error) {
+	lister := currentProfileLister()
+	if lister == nil {
+		return 0, ErrProfileListerNotConfigured
+	}
+
+	douyinSubscriptionCheckMu.Lock()
+	defer douyinSubscriptionCheckMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), douyinSubscriptionListTimeout)
+	defer cancel()
+	posts, err := lister.ListPosts(ctx, sub.ProfileURL)
+	if err != nil {
+		database.MarkDouyinSubscriptionChecked(sub.ID, err.Error())
+		return 0, err
+	}
+
+	// 以是否记录过作品判断首次检查：列表失败或为空时不算完成 baseline，避免之后把旧作品当作新作品下载
+	recorded, err := database.HasDouyinSubscriptionPosts(sub.ID)
+	if err != nil {
+		return 0, err
+	}
+	firstCheck := !recorded
+	added := 0
+	// 列表新的在前，按发布顺序从旧到新加入队列
+	for i := len(posts) - 1; i >= 0; i-- {
+		post := posts[i]
+		key := post.Key()
+		exists, err := database.DouyinSubscriptionPostExists(sub.ID, key)
+		if err != nil {
+			return added, err
+		}
+		if exists {
+			continue
+		}
+
+		record := models.DouyinSubscriptionPost{AwemeID: post.AwemeID, URL: post.URL, Title: post.Title}
+		if firstCheck {
+			record.Status = models.DouyinSubscriptionPostBaseline
+			record.Message = "订阅前已发布，未下载"
+		} else {
+			item, err := ParseDouyinLink(sub.UserID, post.URL, post.AwemeID)
+			if err != nil {
+				// 不记录该作品，下次检查时重试
+				log.Printf("订阅作品加入队列失败: subscription_id=%d, url=%s, error=%v", sub.ID, post.URL, err)
+				continue
+			}
+			record.JobID = item.JobID
+			record.Status = item.Status
+			record.Message = item.Message
+			added++
+		}
+		if err := database.AddDouyinSubscriptionPost(sub.ID, key, record); err != nil {
+			return added, err
+		}
+	}
+
+	if err := database.MarkDouyinSubscriptionChecked(sub.ID, ""); err != nil {
+		return added, err
+	}
+	sub.LastCheckedAt = utils.NowString()
+	sub.LastError = ""
+	return added, nil
+}
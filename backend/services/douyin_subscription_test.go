@@ -0,0 +1,169 @@
+package services
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"backend/database"
+	"backend/models"
+)
+
+func creatorPost(id string) CreatorPost {
+	return CreatorPost{AwemeID: id, URL: "https://www.douyin.com/video/" + id, Title: "作品 " + id}
+}
+
+// runQueuedDouyinJobs 依次执行队列中的所有任务（代替后台 worker）
+func runQueuedDouyinJobs(t *testing.T) {
+	t.Helper()
+	for {
+		job, err := database.ClaimNextDouyinJob()
+		if err == sql.ErrNoRows {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		runDouyinJob(job)
+	}
+}
+
+func douyinJobAwemeIDs(t *testing.T) []string {
+	t.Helper()
+	rows, err := database.DB.Query("SELECT aweme_id FROM douyin_jobs ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// subscriptionHistory 订阅历史中各作品的状态
+func subscriptionHistory(t *testing.T, subID int) map[string]string {
+	t.Helper()
+	posts, err := database.GetDouyinSubscriptionPosts(subID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := make(map[string]string)
+	for _, p := range posts {
+		history[p.AwemeID] = p.Status
+	}
+	return history
+}
+
+func TestDouyinSubscriptionScheduler(t *testing.T) {
+	setupTestDB(t)
+	t.Chdir(t.TempDir()) // 下载目录使用相对路径
+
+	lister := &FakeProfileLister{}
+	SetProfileLister(lister)
+	t.Cleanup(func() { SetProfileLister(nil) })
+	RegisterDownloader(&FakeDownloader{})
+	SetDownloaderRules("fake", nil)
+
+	const profile = "https://www.douyin.com/user/creator"
+	sub, err := database.CreateDouyinSubscription(1, profile, "creator")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 列表失败：记录错误，但不算完成首次检查
+	checkDueDouyinSubscriptions(0)
+	if got, err := database.GetDouyinSubscription(sub.ID, 1); err != nil || got.LastError == "" {
+		t.Fatalf("列表失败后应记录错误: %+v, %v", got, err)
+	}
+	// 主页暂时为空，同样不算完成首次检查
+	lister.SetPosts(profile, nil)
+	checkDueDouyinSubscriptions(0)
+	if got := subscriptionHistory(t, sub.ID); len(got) != 0 {
+		t.Fatalf("空列表不应记录作品: %v", got)
+	}
+
+	// 首次成功列出作品：已有作品只记录为 baseline，不下载
+	lister.SetPosts(profile, []CreatorPost{creatorPost("7300000000000000002"), creatorPost("7300000000000000001")})
+	checkDueDouyinSubscriptions(0)
+
+	if ids := douyinJobAwemeIDs(t); len(ids) != 0 {
+		t.Fatalf("首次检查不应创建下载任务: %v", ids)
+	}
+	want := map[string]string{
+		"7300000000000000001": models.DouyinSubscriptionPostBaseline,
+		"7300000000000000002": models.DouyinSubscriptionPostBaseline,
+	}
+	if got := subscriptionHistory(t, sub.ID); !reflect.DeepEqual(got, want) {
+		t.Fatalf("首次检查历史 = %v, want %v", got, want)
+	}
+
+	// 主页出现两个新作品：只有新作品加入队列，按发布顺序从旧到新
+	lister.SetPosts(profile, []CreatorPost{
+		creatorPost("7300000000000000004"), creatorPost("7300000000000000003"),
+		creatorPost("7300000000000000002"), creatorPost("7300000000000000001"),
+	})
+	checkDueDouyinSubscriptions(0)
+
+	wantJobs := []string{"7300000000000000003", "7300000000000000004"}
+	if got := douyinJobAwemeIDs(t); !reflect.DeepEqual(got, wantJobs) {
+		t.Fatalf("下载任务 = %v, want %v", got, wantJobs)
+	}
+
+	// 使用假下载器执行任务，历史中的状态取自任务状态
+	runQueuedDouyinJobs(t)
+	want["7300000000000000003"] = models.DouyinJobSucceeded
+	want["7300000000000000004"] = models.DouyinJobSucceeded
+	if got := subscriptionHistory(t, sub.ID); !reflect.DeepEqual(got, want) {
+		t.Fatalf("下载后历史 = %v, want %v", got, want)
+	}
+
+	// 作品列表没有变化时不重复加入
+	checkDueDouyinSubscriptions(0)
+	if got := douyinJobAwemeIDs(t); !reflect.DeepEqual(got, wantJobs) {
+		t.Fatalf("重复检查后下载任务 = %v, want %v", got, wantJobs)
+	}
+
+	// 暂停后不再检查
+	if ok, err := database.SetDouyinSubscriptionStatus(sub.ID, 1, models.DouyinSubscriptionPaused); !ok || err != nil {
+		t.Fatalf("暂停订阅失败: %v, %v", ok, err)
+	}
+	lister.SetPosts(profile, []CreatorPost{
+		creatorPost("7300000000000000005"), creatorPost("7300000000000000004"), creatorPost("7300000000000000003"),
+	})
+	checkDueDouyinSubscriptions(0)
+	if got := douyinJobAwemeIDs(t); !reflect.DeepEqual(got, wantJobs) {
+		t.Fatalf("暂停期间下载任务 = %v, want %v", got, wantJobs)
+	}
+	if got := subscriptionHistory(t, sub.ID); !reflect.DeepEqual(got, want) {
+		t.Fatalf("暂停期间历史 = %v, want %v", got, want)
+	}
+
+	// 恢复后重新检查，暂停期间发布的作品加入队列
+	if ok, err := database.SetDouyinSubscriptionStatus(sub.ID, 1, models.DouyinSubscriptionActive); !ok || err != nil {
+		t.Fatalf("恢复订阅失败: %v, %v", ok, err)
+	}
+	checkDueDouyinSubscriptions(0)
+	wantJobs = append(wantJobs, "7300000000000000005")
+	if got := douyinJobAwemeIDs(t); !reflect.DeepEqual(got, wantJobs) {
+		t.Fatalf("恢复后下载任务 = %v, want %v", got, wantJobs)
+	}
+	runQueuedDouyinJobs(t)
+	want["7300000000000000005"] = models.DouyinJobSucceeded
+	if got := subscriptionHistory(t, sub.ID); !reflect.DeepEqual(got, want) {
+		t.Fatalf("恢复后历史 = %v, want %v", got, want)
+	}
+
+	var files int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM douyin_files WHERE user_id = 1").Scan(&files); err != nil {
+		t.Fatal(err)
+	}
+	if files != 3 {
+		t.Errorf("下载的文件数 = %d, want 3", files)
+	}
+}
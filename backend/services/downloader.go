@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DownloadRequest 下载请求
+type DownloadRequest struct {
+	URL       string
+	OutputDir string // 下载器只能把文件写到该目录下（每个任务独立）
+}
+
+// DownloadResult 下载结果，Output 为命令的完整输出
+type DownloadResult struct {
+	Success  bool
+	Message  string
+	Output   string
+	ExitCode int // 下载命令的退出码，未启动或被信号终止时为 -1
+}
+
+// Downloader 视频下载后端，各实现自行判断下载是否成功；ctx 取消或超时时必须结束下载进程
+type Downloader interface {
+	Name() string
+	Download(ctx context.Context, req DownloadRequest) *DownloadResult
+}
+
+// DownloadTimeout 单个下载任务的最长执行时间
+var DownloadTimeout = 10 * time.Minute
+
+// 下载命令输出最多保留的字节数（保留开头和末尾，成功标识通常在末尾）
+const downloadOutputLimit = 64 * 1024
+
+// 已注册的下载器和选择规则
+var (
+	downloaders        = make(map[string]Downloader)
+	defaultDownloader  = "f2"
+	hostDownloaders    = make(map[string]string) // 域名 -> 下载器名称
+	downloaderConfigMu sync.RWMutex
+)
+
+// RegisterDownloader 注册（或替换）下载器
+func RegisterDownloader(d Downloader) {
+	downloaderConfigMu.Lock()
+	downloaders[d.Name()] = d
+	downloaderConfigMu.Unlock()
+}
+
+// SetDownloaderRules 设置默认下载器和按域名选择的规则（域名同时匹配其子域名）
+func SetDownloaderRules(defaultName string, hosts map[string]string) {
+	downloaderConfigMu.Lock()
+	defer downloaderConfigMu.Unlock()
+	if defaultName != "" {
+		defaultDownloader = defaultName
+	}
+	hostDownloaders = make(map[string]string)
+	for host, name := range hosts {
+		hostDownloaders[strings.ToLower(host)] = name
+	}
+}
+
+// DownloaderFor 根据URL的域名选择下载器，未配置该域名时使用默认下载器
+func DownloaderFor(rawURL string) (Downloader, error) {
+	downloaderConfigMu.RLock()
+	defer downloaderConfigMu.RUnlock()
+
+	name := defaultDownloader
+	if u, err := url.Parse(rawURL); err == nil {
+		host := strings.ToLower(u.Hostname())
+		for pattern, n := range hostDownloaders {
+			if host == pattern || strings.HasSuffix(host, "."+pattern) {
+				name = n
+				break
+			}
+		}
+	}
+
+	d, ok := downloaders[name]
+	if !ok {
+		return nil, fmt.Errorf("下载器未配置: %s", name)
+	}
+	return d, nil
+}
+
+// ConfigureDownloaders 从环境变量注册下载器并设置选择规则：
+//
+//	DOUYIN_DOWNLOADER          默认下载器（f2 / ytdlp / command / fake，默认 f2）
+//	DOUYIN_FAKE_DOWNLOADER     为 1 时注册测试用的 fake 下载器，生产环境不要开启
+//	DOUYIN_DOWNLOADER_HOSTS    按域名选择，如 "douyin.com=f2,tiktok.com=ytdlp"
+//	F2_CONDA / F2_CONDA_ENV    f2 所在的 conda 可执行文件和环境名
+//	YTDLP_BIN                  yt-dlp 可执行文件
+//	DOUYIN_COMMAND_TEMPLATE    通用命令模板，{url} 和 {output} 会被替换
+//	DOUYIN_COMMAND_SUCCESS     通用命令的成功标识（正则），为空时以退出码0为成功
+func ConfigureDownloaders() error {
+	conda := os.Getenv("F2_CONDA")
+	if conda == "" {
+		conda = "/root/miniconda3/bin/conda"
+		if runtime.GOOS == "windows" {
+			conda = "conda"
+		}
+	}
+	condaEnv := os.Getenv("F2_CONDA_ENV")
+	if condaEnv == "" {
+		condaEnv = "douyi_download"
+	}
+	RegisterDownloader(&F2Downloader{Conda: conda, Env: condaEnv})
+
+	ytdlp := os.Getenv("YTDLP_BIN")
+	if ytdlp == "" {
+		ytdlp = "yt-dlp"
+	}
+	RegisterDownloader(&YtDlpDownloader{Binary: ytdlp})
+
+	if tmpl := os.Getenv("DOUYIN_COMMAND_TEMPLATE"); tmpl != "" {
+		d, err := NewCommandDownloader(tmpl, os.Getenv("DOUYIN_COMMAND_SUCCESS"))
+		if err != nil {
+			return err
+		}
+		RegisterDownloader(d)
+	}
+
+	if os.Getenv("DOUYIN_FAKE_DOWNLOADER") == "1" {
+		RegisterDownloader(&FakeDownloader{})
+	}
+
+	hosts := make(map[string]string)
+	for _, rule := range strings.Split(os.Getenv("DOUYIN_DOWNLOADER_HOSTS"), ",") {
+		host, name, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if ok && host != "" && name != "" {
+			hosts[strings.TrimSpace(host)] = strings.TrimSpace(name)
+		}
+	}
+	SetDownloaderRules(os.Getenv("DOUYIN_DOWNLOADER"), hosts)
+
+	downloaderConfigMu.RLock()
+	defer downloaderConfigMu.RUnlock()
+	if _, ok := downloaders[defaultDownloader]; !ok {
+		return fmt.Errorf("默认下载器未配置: %s", defaultDownloader)
+	}
+	for host, name := range hostDownloaders {
+		if _, ok := downloaders[name]; !ok {
+			return fmt.Errorf("域名 %s 的下载器未配置: %s", host, name)
+		}
+	}
+	return nil
+}
+
+// DownloadDouyinURL 选择下载器并下载到 outputDir
+func DownloadDouyinURL(ctx context.Context, rawURL, outputDir string) (string, *DownloadResult) {
+	d, err := DownloaderFor(rawURL)
+	if err != nil {
+		return "", &DownloadResult{Message: err.Error(), ExitCode: -1}
+	}
+	return d.Name(), d.Download(ctx, DownloadRequest{URL: rawURL, OutputDir: outputDir})
+}
+
+// downloadCommand 创建下载命令：ctx 结束时杀掉整个进程组，进程退出后最多再等待输出管道5秒
+func downloadCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
+}
+
+// downloadCommandEnv 下载命令的环境变量：清除代理，输出使用 UTF-8
+func downloadCommandEnv() []string {
+	newEnv := []string{}
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, "http_proxy=") &&
+			!strings.HasPrefix(env, "https_proxy=") &&
+			!strings.HasPrefix(env, "HTTP_PROXY=") &&
+			!strings.HasPrefix(env, "HTTPS_PROXY=") {
+			newEnv = append(newEnv, env)
+		}
+	}
+	return append(newEnv, "PYTHONIOENCODING=utf-8")
+}
+
+// runDownloadCommand 执行下载命令（清除代理环境变量），返回合并后的输出（超出上限时截断中间部分）、退出码和错误
+func runDownloadCommand(cmd *exec.Cmd) (string, int, error) {
+	cmd.Env = downloadCommandEnv()
+
+	output := &cappedBuffer{limit: downloadOutputLimit}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	return output.String(), exitCode, err
+}
+
+// trimOutput 截断过长的输出，保留开头和末尾
+func trimOutput(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	b := &cappedBuffer{limit: limit}
+	b.Write([]byte(s))
+	return b.String()
+}
+
+// cappedBuffer 有上限的输出缓冲：保留开头 1/4 和末尾 3/4，丢弃中间部分
+type cappedBuffer struct {
+	mu      sync.Mutex
+	limit   int
+	head    []byte
+	tail    []byte
+	dropped int64
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(p)
+	if room := b.limit/4 - len(b.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		b.head = append(b.head, p[:room]...)
+		p = p[room:]
+	}
+
+	tailLimit := b.limit - b.limit/4
+	b.tail = append(b.tail, p...)
+	if over := len(b.tail) - tailLimit; over > 0 {
+		b.dropped += int64(over)
+		b.tail = append(b.tail[:0], b.tail[over:]...)
+	}
+	return n, nil
+}
+
+func (b *cappedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.dropped == 0 {
+		return string(b.head) + string(b.tail)
+	}
+	return fmt.Sprintf("%s\n...（省略 %d 字节）...\n%s", b.head, b.dropped, b.tail)
+}
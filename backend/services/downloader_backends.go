@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// F2Downloader 通过 conda 环境中的 f2 下载
+type F2Downloader struct {
+	Conda string // conda 可执行文件
+	Env   string // conda 环境名
+}
+
+func (d *F2Downloader) Name() string { return "f2" }
+
+// Download f2 的退出码不可靠，即使退出码不是0，只要输出中包含"当前任务处理完成"就认为成功
+func (d *F2Downloader) Download(ctx context.Context, req DownloadRequest) *DownloadResult {
+	args := []string{"run", "-n", d.Env, "f2", "dy", "-M", "one", "-u", req.URL, "-n", "{nickname}_{create}", "-p", req.OutputDir}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		// Windows 下 conda 是批处理脚本，需要通过 cmd.exe 执行
+		cmd = downloadCommand(ctx, "cmd.exe", append([]string{"/c", d.Conda}, args...)...)
+	} else {
+		cmd = downloadCommand(ctx, d.Conda, args...)
+	}
+
+	output, exitCode, err := runDownloadCommand(cmd)
+	if strings.Contains(output, "当前任务处理完成") {
+		return &DownloadResult{Success: true, Message: "解析成功", Output: output, ExitCode: exitCode}
+	}
+	if err != nil {
+		return &DownloadResult{Message: fmt.Sprintf("执行命令失败: %v", err), Output: output, ExitCode: exitCode}
+	}
+	return &DownloadResult{Message: "执行命令失败，未找到完成标识", Output: output, ExitCode: exitCode}
+}
+
+// YtDlpDownloader 通过 yt-dlp 下载
+type YtDlpDownloader struct {
+	Binary string
+}
+
+func (d *YtDlpDownloader) Name() string { return "ytdlp" }
+
+// Download 退出码为0且输出中没有 ERROR 行时认为成功
+func (d *YtDlpDownloader) Download(ctx context.Context, req DownloadRequest) *DownloadResult {
+	template := filepath.Join(req.OutputDir, "%(uploader)s", "%(uploader)s_%(id)s.%(ext)s")
+	// 附带输出 .info.json 和封面，用于记录作品元数据
+	cmd := downloadCommand(ctx, d.Binary, "--no-playlist", "--no-progress", "--write-info-json", "--write-thumbnail", "-o", template, req.URL)
+
+	output, exitCode, err := runDownloadCommand(cmd)
+	if err != nil {
+		return &DownloadResult{Message: fmt.Sprintf("执行命令失败: %v", err), Output: output, ExitCode: exitCode}
+	}
+	if strings.Contains(output, "ERROR:") {
+		return &DownloadResult{Message: "下载失败，请查看日志", Output: output, ExitCode: exitCode}
+	}
+	return &DownloadResult{Success: true, Message: "解析成功", Output: output, ExitCode: exitCode}
+}
+
+// CommandDownloader 通用命令模板下载器，模板按空白拆分为参数（不经过 shell），
+// 其中 {url} 替换为视频链接，{output} 替换为任务的输出目录
+type CommandDownloader struct {
+	Args    []string
+	Success *regexp.Regexp // 为 nil 时以退出码0为成功
+}
+
+// NewCommandDownloader 解析命令模板和成功标识正则
+func NewCommandDownloader(template, successPattern string) (*CommandDownloader, error) {
+	args := strings.Fields(template)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("命令模板为空")
+	}
+	d := &CommandDownloader{Args: args}
+	if successPattern != "" {
+		re, err := regexp.Compile(successPattern)
+		if err != nil {
+			return nil, fmt.Errorf("成功标识正则无效: %v", err)
+		}
+		d.Success = re
+	}
+	return d, nil
+}
+
+func (d *CommandDownloader) Name() string { return "command" }
+
+func (d *CommandDownloader) Download(ctx context.Context, req DownloadRequest) *DownloadResult {
+	replacer := strings.NewReplacer("{url}", req.URL, "{output}", req.OutputDir)
+	args := make([]string, len(d.Args))
+	for i, arg := range d.Args {
+		args[i] = replacer.Replace(arg)
+	}
+
+	output, exitCode, err := runDownloadCommand(downloadCommand(ctx, args[0], args[1:]...))
+	if d.Success != nil {
+		if d.Success.MatchString(output) {
+			return &DownloadResult{Success: true, Message: "解析成功", Output: output, ExitCode: exitCode}
+		}
+		if err != nil {
+			return &DownloadResult{Message: fmt.Sprintf("执行命令失败: %v", err), Output: output, ExitCode: exitCode}
+		}
+		return &DownloadResult{Message: "执行命令失败，未找到完成标识", Output: output, ExitCode: exitCode}
+	}
+	if err != nil {
+		return &DownloadResult{Message: fmt.Sprintf("执行命令失败: %v", err), Output: output, ExitCode: exitCode}
+	}
+	return &DownloadResult{Success: true, Message: "解析成功", Output: output, ExitCode: exitCode}
+}
+
+// FakeDownloader 测试用下载器：不访问网络，为每个URL写入一个小的占位视频文件；
+// URL 中包含 "fail" 时返回失败，包含 "flaky" 时返回可自动重试的临时错误
+type FakeDownloader struct{}
+
+func (d *FakeDownloader) Name() string { return "fake" }
+
+func (d *FakeDownloader) Download(ctx context.Context, req DownloadRequest) *DownloadResult {
+	if strings.Contains(req.URL, "fail") {
+		return &DownloadResult{Message: "模拟下载失败", Output: "fake: download failed\n", ExitCode: 1}
+	}
+	if strings.Contains(req.URL, "flaky") {
+		return &DownloadResult{Message: "模拟网络错误", Output: "fake: HTTP Error 503: Service Unavailable\n", ExitCode: 1}
+	}
+
+	sum := sha1.Sum([]byte(req.URL))
+	dir := filepath.Join(req.OutputDir, "fake")
+	path := filepath.Join(dir, "fake_"+hex.EncodeToString(sum[:6])+".mp4")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return &DownloadResult{Message: err.Error(), ExitCode: -1}
+	}
+	if err := os.WriteFile(path, []byte("fake video for "+req.URL), 0644); err != nil {
+		return &DownloadResult{Message: err.Error(), ExitCode: -1}
+	}
+	return &DownloadResult{
+		Success: true,
+		Message: "解析成功",
+		Output:  fmt.Sprintf("fake: saved %s\n当前任务处理完成\n", path),
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ErrSMTPNotConfigured 未配置 SMTP 中继
+var ErrSMTPNotConfigured = errors.New("smtp not configured")
+
+// SMTPConfig SMTP 中继配置
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // 为空时不认证
+	Password string
+	From     string
+}
+
+// SMTP 当前使用的 SMTP 配置（由 main 从环境变量加载）
+var SMTP SMTPConfig
+
+// LoadSMTPConfig 从环境变量加载 SMTP 配置：SMTP_HOST、SMTP_PORT（默认25）、SMTP_USERNAME、SMTP_PASSWORD、SMTP_FROM
+func LoadSMTPConfig() SMTPConfig {
+	port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if port <= 0 {
+		port = 25
+	}
+	cfg := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.Username
+	}
+	return cfg
+}
+
+// Enabled 是否已配置 SMTP 中继
+func (c SMTPConfig) Enabled() bool {
+	return c.Host != "" && c.From != ""
+}
+
+// SendHTMLMail 通过 SMTP 中继发送 HTML 邮件（服务器支持时自动使用 STARTTLS）
+func SendHTMLMail(cfg SMTPConfig, to, subject string, body []byte) error {
+	if !cfg.Enabled() {
+		return ErrSMTPNotConfigured
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString(body)
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	return smtp.SendMail(addr, auth, cfg.From, []string{to}, msg.Bytes())
+}
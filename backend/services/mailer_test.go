@@ -0,0 +1,187 @@
+package services_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"backend/handlers"
+	"backend/models"
+	"backend/services"
+)
+
+// smtpSession 本地假 SMTP 服务器收到的一次投递
+type smtpSession struct {
+	from string
+	to   []string
+	data []byte
+	err  error
+}
+
+// startFakeSMTP 启动只接受一次连接的最小 SMTP 服务器（不支持 STARTTLS 和认证）
+func startFakeSMTP(t *testing.T) (host string, port int, done <-chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan smtpSession, 1)
+	go func() {
+		var s smtpSession
+		defer func() { ch <- s }()
+
+		conn, err := ln.Accept()
+		if err != nil {
+			s.err = err
+			return
+		}
+		defer conn.Close()
+		r := textproto.NewReader(bufio.NewReader(conn))
+		w := textproto.NewWriter(bufio.NewWriter(conn))
+
+		w.PrintfLine("220 localhost fake SMTP")
+		for {
+			line, err := r.ReadLine()
+			if err != nil {
+				s.err = err
+				return
+			}
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				w.PrintfLine("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				s.from = line[len("MAIL FROM:"):]
+				w.PrintfLine("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				s.to = append(s.to, line[len("RCPT TO:"):])
+				w.PrintfLine("250 OK")
+			case cmd == "DATA":
+				w.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				if s.data, err = r.ReadDotBytes(); err != nil {
+					s.err = err
+					return
+				}
+				w.PrintfLine("250 OK")
+			case cmd == "QUIT":
+				w.PrintfLine("221 Bye")
+				return
+			default:
+				w.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, ch
+}
+
+func TestSendHTMLMail(t *testing.T) {
+	host, port, done := startFakeSMTP(t)
+	cfg := services.SMTPConfig{Host: host, Port: port, From: "report@example.com"}
+
+	report := &models.ActivityMonthlyReport{
+		Username:  "alice",
+		Month:     "2026-09",
+		PrevMonth: "2026-08",
+		Current:   models.ActivityPeriodSummary{Count: 12, AutoCount: 5, ManualCount: 7, TotalDuration: 180, ActiveDays: 10},
+		Previous:  models.ActivityPeriodSummary{Count: 8, AutoCount: 3, ManualCount: 5, TotalDuration: 120, ActiveDays: 7},
+	}
+	body, err := handlers.RenderActivityReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject := "健康活动月报 " + report.Month
+
+	if err := services.SendHTMLMail(cfg, "alice@example.com", subject, body); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	s := <-done
+	if s.err != nil {
+		t.Fatalf("假 SMTP 服务器出错: %v", s.err)
+	}
+
+	// 信封
+	if s.from != "<report@example.com>" {
+		t.Errorf("MAIL FROM = %q", s.from)
+	}
+	if len(s.to) != 1 || s.to[0] != "<alice@example.com>" {
+		t.Errorf("RCPT TO = %q", s.to)
+	}
+
+	// MIME 头
+	msg, err := mail.ReadMessage(bytes.NewReader(s.data))
+	if err != nil {
+		t.Fatalf("解析邮件失败: %v", err)
+	}
+	wantHeaders := map[string]string{
+		"From":                      "report@example.com",
+		"To":                        "alice@example.com",
+		"Mime-Version":              "1.0",
+		"Content-Type":              "text/html; charset=UTF-8",
+		"Content-Transfer-Encoding": "base64",
+	}
+	for k, want := range wantHeaders {
+		if got := msg.Header.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("Date 头无效: %v", err)
+	}
+
+	// 主题使用 RFC 2047 编码
+	rawSubject := msg.Header.Get("Subject")
+	if !strings.HasPrefix(rawSubject, "=?UTF-8?b?") {
+		t.Errorf("Subject 未编码: %q", rawSubject)
+	}
+	decoded, err := new(mime.WordDecoder).DecodeHeader(rawSubject)
+	if err != nil || decoded != subject {
+		t.Errorf("Subject 解码 = %q, %v, want %q", decoded, err, subject)
+	}
+
+	// 正文 base64 每行不超过 76 个字符，解码后与渲染结果一致
+	raw, err := io.ReadAll(msg.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range strings.Split(strings.TrimRight(string(raw), "\n"), "\n") {
+		if len(line) > 76 {
+			t.Errorf("正文第 %d 行长度 %d 超过 76", i+1, len(line))
+		}
+	}
+	got, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(raw), "\n", ""))
+	if err != nil {
+		t.Fatalf("正文 base64 解码失败: %v", err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Errorf("正文解码后与渲染的月报不一致")
+	}
+}
+
+func TestSendHTMLMailNotConfigured(t *testing.T) {
+	tests := []services.SMTPConfig{
+		{Port: 25, From: "report@example.com"},
+		{Host: "127.0.0.1", Port: 25},
+		{},
+	}
+	for _, cfg := range tests {
+		t.Run(fmt.Sprintf("host=%q,from=%q", cfg.Host, cfg.From), func(t *testing.T) {
+			err := services.SendHTMLMail(cfg, "alice@example.com", "subject", []byte("<p>hi</p>"))
+			if !errors.Is(err, services.ErrSMTPNotConfigured) {
+				t.Errorf("err = %v, want ErrSMTPNotConfigured", err)
+			}
+		})
+	}
+}
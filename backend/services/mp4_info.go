@@ -0,0 +1,177 @@
+package services
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// MP4Info MP4 容器中记录的时长和视频分辨率
+type MP4Info struct {
+	Duration float64 // 秒
+	Width    int
+	Height   int
+}
+
+// moov 盒子的大小上限，超出时视为文件损坏
+const maxMoovSize = 64 << 20
+
+var errMoovNotFound = errors.New("未找到 moov 盒子")
+
+// ParseMP4Info 解析 MP4 的 moov 盒子：时长取自 mvhd，分辨率取自第一个有宽高的轨道的 tkhd（按旋转矩阵调整宽高）
+func ParseMP4Info(path string) (*MP4Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	moov, err := readTopLevelBox(f, "moov")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &MP4Info{}
+	walkBoxes(moov, func(typ string, body []byte) {
+		switch typ {
+		case "mvhd":
+			info.Duration = parseMvhdDuration(body)
+		case "trak":
+			walkBoxes(body, func(typ string, body []byte) {
+				if typ != "tkhd" || info.Width > 0 {
+					return
+				}
+				info.Width, info.Height = parseTkhdSize(body)
+			})
+		}
+	})
+	return info, nil
+}
+
+// readTopLevelBox 顺序跳过顶层盒子，读取指定类型盒子的内容（不含头部）
+func readTopLevelBox(f *os.File, want string) ([]byte, error) {
+	header := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(f, header[:8]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, errMoovNotFound
+			}
+			return nil, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		typ := string(header[4:8])
+		headerSize := int64(8)
+
+		switch size {
+		case 1: // 64位大小
+			if _, err := io.ReadFull(f, header[8:16]); err != nil {
+				return nil, errMoovNotFound
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		case 0: // 延伸到文件末尾
+			pos, err := f.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			stat, err := f.Stat()
+			if err != nil {
+				return nil, err
+			}
+			size = stat.Size() - pos + headerSize
+		}
+		if size < headerSize {
+			return nil, fmt.Errorf("无效的盒子大小: %s", typ)
+		}
+
+		bodySize := size - headerSize
+		if typ == want {
+			if bodySize > maxMoovSize {
+				return nil, fmt.Errorf("%s 盒子过大: %d", typ, bodySize)
+			}
+			body := make([]byte, bodySize)
+			if _, err := io.ReadFull(f, body); err != nil {
+				return nil, err
+			}
+			return body, nil
+		}
+		if _, err := f.Seek(bodySize, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// walkBoxes 遍历 data 中连续的子盒子，遇到无效大小时停止
+func walkBoxes(data []byte, fn func(typ string, body []byte)) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		typ := string(data[4:8])
+		headerSize := uint64(8)
+		switch size {
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		case 0:
+			size = uint64(len(data))
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			return
+		}
+		fn(typ, data[headerSize:size])
+		data = data[size:]
+	}
+}
+
+// parseMvhdDuration 从 mvhd 计算时长（秒）
+func parseMvhdDuration(body []byte) float64 {
+	if len(body) < 1 {
+		return 0
+	}
+	var timescale, duration uint64
+	if body[0] == 1 {
+		if len(body) < 32 {
+			return 0
+		}
+		timescale = uint64(binary.BigEndian.Uint32(body[20:24]))
+		duration = binary.BigEndian.Uint64(body[24:32])
+	} else {
+		if len(body) < 20 {
+			return 0
+		}
+		timescale = uint64(binary.BigEndian.Uint32(body[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(body[16:20]))
+	}
+	if timescale == 0 {
+		return 0
+	}
+	return float64(duration) / float64(timescale)
+}
+
+// parseTkhdSize 从 tkhd 读取显示宽高（16.16 定点数），旋转 90/270 度时交换宽高；音频轨道返回 0
+func parseTkhdSize(body []byte) (int, int) {
+	if len(body) < 1 {
+		return 0, 0
+	}
+	// version 1 的创建/修改时间和时长各多 4 字节
+	matrix, size := 40, 76
+	if body[0] == 1 {
+		matrix, size = 52, 88
+	}
+	if len(body) < size+8 {
+		return 0, 0
+	}
+	width := int(binary.BigEndian.Uint32(body[size:size+4]) >> 16)
+	height := int(binary.BigEndian.Uint32(body[size+4:size+8]) >> 16)
+
+	a := int32(binary.BigEndian.Uint32(body[matrix : matrix+4]))
+	b := int32(binary.BigEndian.Uint32(body[matrix+4 : matrix+8]))
+	if a == 0 && b != 0 {
+		width, height = height, width
+	}
+	return width, height
+}
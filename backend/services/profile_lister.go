@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// CreatorPost 创作者主页中的一个作品
+type CreatorPost struct {
+	AwemeID string
+	URL     string
+	Title   string
+}
+
+// Key 作品的去重键：优先作品ID，没有时用规范化的URL
+func (p CreatorPost) Key() string {
+	if p.AwemeID != "" {
+		return p.AwemeID
+	}
+	return NormalizeDouyinURL(p.URL)
+}
+
+// ProfileLister 列出创作者主页最近的作品（新的在前）；测试时可替换为 FakeProfileLister，无需访问网络
+type ProfileLister interface {
+	ListPosts(ctx context.Context, profileURL string) ([]CreatorPost, error)
+}
+
+// 每次检查最多列出的作品数
+const profileListLimit = 30
+
+var (
+	profileLister   ProfileLister
+	profileListerMu sync.RWMutex
+)
+
+// SetProfileLister 设置创作者主页的作品列表实现
+func SetProfileLister(l ProfileLister) {
+	profileListerMu.Lock()
+	profileLister = l
+	profileListerMu.Unlock()
+}
+
+func currentProfileLister() ProfileLister {
+	profileListerMu.RLock()
+	defer profileListerMu.RUnlock()
+	return profileLister
+}
+
+// ConfigureProfileLister 从环境变量选择作品列表实现：
+//
+//	DOUYIN_PROFILE_LISTER    ytdlp（默认）/ command
+//	DOUYIN_PROFILE_COMMAND   command 的命令模板，{url} 替换为主页地址，每行输出一个作品链接
+func ConfigureProfileLister() error {
+	switch name := os.Getenv("DOUYIN_PROFILE_LISTER"); name {
+	case "", "ytdlp":
+		ytdlp := os.Getenv("YTDLP_BIN")
+		if ytdlp == "" {
+			ytdlp = "yt-dlp"
+		}
+		SetProfileLister(&YtDlpProfileLister{Binary: ytdlp})
+	case "command":
+		args := strings.Fields(os.Getenv("DOUYIN_PROFILE_COMMAND"))
+		if len(args) == 0 {
+			return fmt.Errorf("DOUYIN_PROFILE_COMMAND 为空")
+		}
+		SetProfileLister(&CommandProfileLister{Args: args})
+	default:
+		return fmt.Errorf("未知的作品列表实现: %s", name)
+	}
+	return nil
+}
+
+// YtDlpProfileLister 使用 yt-dlp 的 --flat-playlist 只列出作品，不下载
+type YtDlpProfileLister struct {
+	Binary string
+}
+
+func (l *YtDlpProfileLister) ListPosts(ctx context.Context, profileURL string) ([]CreatorPost, error) {
+	cmd := downloadCommand(ctx, l.Binary,
+		"--flat-playlist", "--playlist-end", fmt.Sprint(profileListLimit),
+		"--print", "%(id)s\t%(url)s\t%(title)s", profileURL)
+	output, err := runListCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []CreatorPost
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), "\t", 3)
+		if len(fields) < 2 || fields[0] == "" || fields[0] == "NA" {
+			continue
+		}
+		post := CreatorPost{AwemeID: fields[0], URL: fields[1]}
+		if !strings.HasPrefix(post.URL, "http") {
+			post.URL = "https://www.douyin.com/video/" + post.AwemeID
+		}
+		if len(fields) == 3 && fields[2] != "NA" {
+			post.Title = fields[2]
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+// CommandProfileLister 通用命令模板（不经过 shell），{url} 替换为主页地址；输出中每行的第一个链接为一个作品
+type CommandProfileLister struct {
+	Args []string
+}
+
+func (l *CommandProfileLister) ListPosts(ctx context.Context, profileURL string) ([]CreatorPost, error) {
+	args := make([]string, len(l.Args))
+	for i, arg := range l.Args {
+		args[i] = strings.ReplaceAll(arg, "{url}", profileURL)
+	}
+	output, err := runListCommand(downloadCommand(ctx, args[0], args[1:]...))
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []CreatorPost
+	for _, line := range strings.Split(output, "\n") {
+		link := urlRegex.FindString(line)
+		if link == "" {
+			continue
+		}
+		posts = append(posts, CreatorPost{AwemeID: awemeIDFromURL(link), URL: link})
+		if len(posts) >= profileListLimit {
+			break
+		}
+	}
+	return posts, nil
+}
+
+// FakeProfileLister 返回预设的作品列表，用于测试
+type FakeProfileLister struct {
+	mu    sync.Mutex
+	Posts map[string][]CreatorPost // 主页地址 -> 作品（新的在前）
+}
+
+// SetPosts 设置主页的作品列表
+func (l *FakeProfileLister) SetPosts(profileURL string, posts []CreatorPost) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.Posts == nil {
+		l.Posts = make(map[string][]CreatorPost)
+	}
+	l.Posts[profileURL] = posts
+}
+
+func (l *FakeProfileLister) ListPosts(ctx context.Context, profileURL string) ([]CreatorPost, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	posts, ok := l.Posts[profileURL]
+	if !ok {
+		return nil, fmt.Errorf("主页不存在: %s", profileURL)
+	}
+	return append([]CreatorPost(nil), posts...), nil
+}
+
+// runListCommand 执行列表命令，只返回标准输出；失败时错误中附带标准错误的末尾
+func runListCommand(cmd *exec.Cmd) (string, error) {
+	cmd.Env = downloadCommandEnv()
+
+	var stdout bytes.Buffer
+	stderr := &cappedBuffer{limit: 4096}
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
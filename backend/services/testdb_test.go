@@ -0,0 +1,19 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"backend/database"
+)
+
+// setupTestDB 在临时目录中初始化一个全新的数据库，测试结束后关闭
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	if err := database.InitDB(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("初始化测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Close()
+	})
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"backend/database"
+)
+
+// TrashRetention 回收站保留时长，超过后永久删除（含物理文件）
+var TrashRetention = 30 * 24 * time.Hour
+
+// StartTrashPurger 设置保留时长并启动后台清理：启动时执行一次，之后每隔 interval 执行
+func StartTrashPurger(retention, interval time.Duration) {
+	if retention > 0 {
+		TrashRetention = retention
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			purgeExpiredTrash()
+			<-ticker.C
+		}
+	}()
+}
+
+func purgeExpiredTrash() {
+	count, err := database.PurgeExpiredTrash(TrashRetention)
+	if err != nil {
+		log.Printf("清理过期回收站条目失败: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("已清理过期回收站条目: %d 条", count)
+	}
+}
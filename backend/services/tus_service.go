@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+)
+
+// 断点续传上传（tus）配置
+var (
+	TusUploadTTL       = 24 * time.Hour // 上传在最后一次接收数据后保留的时长，过期未完成的会被清理
+	TusMaxSize   int64 = 20 << 30       // 单个文件的最大大小
+)
+
+// 每个上传同一时间只允许一个请求写入
+var (
+	tusLocksMu sync.Mutex
+	tusLocks   = make(map[string]*tusLock)
+)
+
+type tusLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// LockTusUpload 锁定上传，等待正在写入同一上传的请求结束；返回解锁函数
+func LockTusUpload(id string) func() {
+	tusLocksMu.Lock()
+	l := tusLocks[id]
+	if l == nil {
+		l = &tusLock{}
+		tusLocks[id] = l
+	}
+	l.refs++
+	tusLocksMu.Unlock()
+
+	l.mu.Lock()
+	return func() { releaseTusLock(id, l) }
+}
+
+// tryLockTusUpload 不等待的 LockTusUpload，上传正在写入时返回 false
+func tryLockTusUpload(id string) (func(), bool) {
+	tusLocksMu.Lock()
+	defer tusLocksMu.Unlock()
+	if tusLocks[id] != nil {
+		return nil, false
+	}
+	l := &tusLock{refs: 1}
+	l.mu.Lock()
+	tusLocks[id] = l
+	return func() { releaseTusLock(id, l) }, true
+}
+
+func releaseTusLock(id string, l *tusLock) {
+	l.mu.Unlock()
+	tusLocksMu.Lock()
+	l.refs--
+	if l.refs == 0 {
+		delete(tusLocks, id)
+	}
+	tusLocksMu.Unlock()
+}
+
+// FinishTusUpload 上传完成：把临时文件移动到 uploadDir，按普通上传的方式命名并创建文件传输记录；调用方需持有上传的锁
+func FinishTusUpload(u *models.TusUpload, uploadDir string) (*models.FileTransfer, error) {
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return nil, err
+	}
+
+	// 与上传文件相同的命名方式；同一秒内完成的同名文件追加序号，不覆盖已有文件
+	ext := filepath.Ext(u.FileName)
+	base := fmt.Sprintf("%d_%s_%s", u.UserID, strings.TrimSuffix(u.FileName, ext), utils.NowTimestamp())
+	target := filepath.Join(uploadDir, base+ext)
+	var err error
+	for i := 1; ; i++ {
+		_, err = linkOrCopyFile(u.PartPath, target)
+		if !os.IsExist(err) {
+			break
+		}
+		target = filepath.Join(uploadDir, fmt.Sprintf("%s_%d%s", base, i, ext))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &models.FileTransfer{
+		UserID:    u.UserID,
+		FileName:  u.FileName,
+		FilePath:  target,
+		FileSize:  u.Length,
+		FileType:  u.FileType,
+		CreatedAt: utils.NowUTCString(),
+	}
+	// 文件传输记录和上传完成状态在同一事务中写入，失败时都不会保存；
+	// 临时文件保留到提交成功后再删除，客户端重试时可以再次完成
+	if err := database.CompleteTusUpload(u.ID, transfer); err != nil {
+		os.Remove(target)
+		return nil, err
+	}
+	if err := os.Remove(u.PartPath); err != nil {
+		log.Printf("删除上传临时文件失败: upload_id=%s, error=%v", u.ID, err)
+	}
+
+	log.Printf("断点续传上传完成: upload_id=%s, user_id=%d, file_id=%d, size=%d", u.ID, u.UserID, transfer.ID, u.Length)
+	return transfer, nil
+}
+
+// StartTusUploadCleaner 定期清理过期的上传：删除未完成上传的临时文件和记录
+func StartTusUploadCleaner(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			cleanupExpiredTusUploads()
+			<-ticker.C
+		}
+	}()
+}
+
+func cleanupExpiredTusUploads() {
+	uploads, err := database.GetExpiredTusUploads()
+	if err != nil {
+		log.Printf("查询过期的断点续传上传失败: %v", err)
+		return
+	}
+
+	count := 0
+	for _, u := range uploads {
+		unlock, ok := tryLockTusUpload(u.ID)
+		if !ok {
+			continue // 正在接收数据
+		}
+		deleted, err := database.DeleteExpiredTusUpload(u.ID)
+		if err != nil {
+			log.Printf("删除断点续传上传记录失败: upload_id=%s, error=%v", u.ID, err)
+		}
+		if deleted && u.PartPath != "" {
+			if err := os.Remove(u.PartPath); err != nil && !os.IsNotExist(err) {
+				log.Printf("删除上传临时文件失败: %s, %v", u.PartPath, err)
+			}
+			count++
+		}
+		unlock()
+	}
+	if count > 0 {
+		log.Printf("已清理过期的未完成上传: %d 个", count)
+	}
+}
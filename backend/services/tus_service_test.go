@@ -0,0 +1,129 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backend/database"
+	"backend/models"
+)
+
+// newTestTusUpload 创建已接收全部数据、等待完成的上传
+func newTestTusUpload(t *testing.T, dir, id string) *models.TusUpload {
+	t.Helper()
+	data := []byte("hello tus")
+	u := &models.TusUpload{
+		ID:       id,
+		UserID:   1,
+		FileName: "hello.txt",
+		FileType: "txt",
+		Length:   int64(len(data)),
+		Offset:   int64(len(data)),
+		PartPath: filepath.Join(dir, id+".part"),
+	}
+	if err := os.WriteFile(u.PartPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CreateTusUpload(u, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func countFileTransfers(t *testing.T) int {
+	t.Helper()
+	var n int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM file_transfers").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestFinishTusUpload(t *testing.T) {
+	setupTestDB(t)
+	dir := t.TempDir()
+	uploadDir := filepath.Join(dir, "uploads")
+
+	u := newTestTusUpload(t, dir, "upload-ok")
+	transfer, err := FinishTusUpload(u, uploadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(transfer.FilePath); err != nil || string(content) != "hello tus" {
+		t.Errorf("完成后的文件 = %q, %v", content, err)
+	}
+	if _, err := os.Stat(u.PartPath); !os.IsNotExist(err) {
+		t.Errorf("临时文件应已移走: %v", err)
+	}
+	saved, err := database.GetTusUpload(u.ID, u.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.FileID != transfer.ID || saved.PartPath != "" {
+		t.Errorf("上传记录 file_id=%d part_path=%q, want file_id=%d", saved.FileID, saved.PartPath, transfer.ID)
+	}
+	if n := countFileTransfers(t); n != 1 {
+		t.Errorf("文件传输记录数 = %d, want 1", n)
+	}
+}
+
+func TestFinishTusUploadRollback(t *testing.T) {
+	setupTestDB(t)
+	dir := t.TempDir()
+	uploadDir := filepath.Join(dir, "uploads")
+
+	// 上传记录在完成前被删除，标记完成失败：不应留下文件传输记录，临时文件放回原处
+	u := newTestTusUpload(t, dir, "upload-gone")
+	if err := database.DeleteTusUpload(u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FinishTusUpload(u, uploadDir); err == nil {
+		t.Fatal("上传记录不存在时应返回错误")
+	}
+	if n := countFileTransfers(t); n != 0 {
+		t.Errorf("失败后文件传输记录数 = %d, want 0", n)
+	}
+	if _, err := os.Stat(u.PartPath); err != nil {
+		t.Errorf("临时文件应放回原处: %v", err)
+	}
+	if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+		t.Errorf("上传目录不应留下文件: %v", entries)
+	}
+
+	// 重新创建记录后重试可以正常完成
+	if err := database.CreateTusUpload(u, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FinishTusUpload(u, uploadDir); err != nil {
+		t.Fatalf("重试完成失败: %v", err)
+	}
+	if n := countFileTransfers(t); n != 1 {
+		t.Errorf("重试后文件传输记录数 = %d, want 1", n)
+	}
+}
+
+// 同一秒内完成的同名上传不互相覆盖
+func TestFinishTusUploadSameName(t *testing.T) {
+	setupTestDB(t)
+	dir := t.TempDir()
+	uploadDir := filepath.Join(dir, "uploads")
+
+	first, err := FinishTusUpload(newTestTusUpload(t, dir, "upload-1"), uploadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := FinishTusUpload(newTestTusUpload(t, dir, "upload-2"), uploadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.FilePath == second.FilePath {
+		t.Fatalf("两个上传完成到同一路径: %s", first.FilePath)
+	}
+	for _, f := range []*models.FileTransfer{first, second} {
+		if _, err := os.Stat(f.FilePath); err != nil {
+			t.Errorf("文件不存在: %v", err)
+		}
+	}
+}
@@ -48,6 +48,16 @@ func NowUTCString() string {
 	return NowUTC().Format("2006-01-02 15:04:05")
 }
 
+// WeekDay 获取日期（YYYY-MM-DD）对应的星期几，格式错误返回空字符串
+func WeekDay(dateStr string) string {
+	t, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		return ""
+	}
+	weekdays := []string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+	return weekdays[t.Weekday()]
+}
+
 // UTCToShanghai 将 UTC 时间字符串转换为上海时间字符串
 func UTCToShanghai(utcTimeStr string) string {
 	if utcTimeStr == "" {
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// NewUUID 生成随机 UUID（v4）
+func NewUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// NormalizeUUID 校验并统一为小写 UUID，格式不正确返回空字符串
+func NormalizeUUID(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if !uuidRegex.MatchString(s) {
+		return ""
+	}
+	return s
+}